@@ -3,6 +3,7 @@ module github.com/hkparker/go-i2p
 go 1.16
 
 require (
+	filippo.io/edwards25519 v1.0.0
 	github.com/sirupsen/logrus v1.8.1
 	github.com/stretchr/testify v1.7.0
 	golang.org/x/crypto v0.0.0-20210513164829-c07d793c2f9a
@@ -5,6 +5,7 @@ package base32
 
 import (
 	b32 "encoding/base32"
+	"strings"
 )
 
 var I2PEncoding *b32.Encoding = b32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567")
@@ -16,3 +17,11 @@ var I2PEncoding *b32.Encoding = b32.NewEncoding("abcdefghijklmnopqrstuvwxyz23456
 func EncodeToString(data []byte) string {
 	return I2PEncoding.EncodeToString(data)
 }
+
+//
+// decode string using i2p base32 encoding, padding is optional
+// returns error if data is malformed
+//
+func DecodeFromString(str string) (d []byte, err error) {
+	return I2PEncoding.WithPadding(b32.NoPadding).DecodeString(strings.TrimRight(str, "="))
+}
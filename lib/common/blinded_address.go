@@ -0,0 +1,274 @@
+package common
+
+/*
+I2P Blinded Base32 Address
+https://geti2p.net/spec/b32encrypted
+Accurate for version 0.9.40
+
++----+----+----+----+----+----+----+----+
+|flag|  sigtype  |       public key     ~
++----+----+----+----+----+----+----+----+
+~                                       ~
++----+----+----+----+----+----+----+----+
+
+flag :: 1 byte
+        bit 0: 0 for one-byte sigtypes, 1 for two-byte sigtypes
+        bit 1: 0 for no secret, 1 if secret is required
+        bit 2: 0 for no per-client auth, 1 if client private key is required
+        bits 7-3: Unused, set to 0
+
+sigtype :: The sig type of the unblinded public key, then the sig type of the
+           blinded public key
+           length -> 1 byte each, or 2 bytes each if bit 0 of the flag is set
+
+public key :: The unblinded public key
+              length -> As inferred from the sigtype
+
+The first three bytes are XORed with the CRC-32 checksum of the remaining data.
+The result is base32 encoded without padding, and ".b32.i2p" is appended, giving
+an address of at least 56 characters.
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/hkparker/go-i2p/lib/common/base32"
+	"github.com/hkparker/go-i2p/lib/crypto"
+	"golang.org/x/crypto/hkdf"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+)
+
+// Flags in a blinded base32 address
+const (
+	BLINDED_ADDRESS_FLAG_TWO_BYTE_SIGTYPES = 1 << 0
+	BLINDED_ADDRESS_FLAG_SECRET_REQUIRED   = 1 << 1
+	BLINDED_ADDRESS_FLAG_CLIENT_AUTH       = 1 << 2
+)
+
+// Sizes and limits of blinded base32 addresses
+const (
+	BLINDED_ADDRESS_CHECKSUM_SIZE = 3
+	BLINDED_ADDRESS_MIN_SIZE      = 3 + KEYCERT_SIGN_ED25519_SIZE
+	BLINDED_ADDRESS_SUFFIX        = ".b32.i2p"
+)
+
+// Parameters used to derive the daily blinding factor
+const (
+	BLINDING_SALT_PREFIX = "I2PGenerateAlpha"
+	BLINDING_INFO        = "i2pblinding1"
+	BLINDING_SEED_SIZE   = 64
+	BLINDING_DATE_FORMAT = "20060102"
+)
+
+//
+// The unblinded key and options carried by a blinded base32 address, which together with the
+// current date and optional secret are enough to find and decrypt the EncryptedLeaseSet of a
+// Destination.
+//
+type BlindedAddress struct {
+	PublicKey             crypto.Ed25519PublicKey
+	SigningKeyType        int
+	BlindedSigningKeyType int
+	SecretRequired        bool
+	ClientAuth            bool
+}
+
+//
+// Return the BlindedAddress of this Destination, which must use an Ed25519 or RedDSA
+// SigningPublicKey.  The flags record whether a secret or per-client authorization is
+// needed to decrypt the Destination's EncryptedLeaseSets.
+//
+func (destination Destination) BlindedAddress(secret_required, client_auth bool) (address BlindedAddress, err error) {
+	signing_key_type, err := KeysAndCert(destination).signingPublicKeyType()
+	if err != nil {
+		return
+	}
+	if signing_key_type != KEYCERT_SIGN_ED25519 && signing_key_type != KEYCERT_SIGN_REDDSA_ED25519 {
+		log.WithFields(log.Fields{
+			"at":               "(Destination) BlindedAddress",
+			"signing_key_type": signing_key_type,
+			"reason":           "only Ed25519 and RedDSA keys can be blinded",
+		}).Error("error building blinded address")
+		err = errors.New("error building blinded address: unsupported signing key type")
+		return
+	}
+	signing_public_key, err := destination.SigningPublicKey()
+	if err != nil {
+		return
+	}
+	copy(address.PublicKey[:], signing_public_key.Bytes())
+	address.SigningKeyType = signing_key_type
+	address.BlindedSigningKeyType = KEYCERT_SIGN_REDDSA_ED25519
+	address.SecretRequired = secret_required
+	address.ClientAuth = client_auth
+	return
+}
+
+//
+// Generate the I2P blinded base32 address for this Destination.
+//
+func (destination Destination) BlindedBase32Address(secret_required, client_auth bool) (str string, err error) {
+	address, err := destination.BlindedAddress(secret_required, client_auth)
+	if err != nil {
+		return
+	}
+	str = address.Base32Address()
+	return
+}
+
+//
+// Encode the BlindedAddress as a ".b32.i2p" address.
+//
+func (address BlindedAddress) Base32Address() string {
+	flags := 0
+	if address.SecretRequired {
+		flags |= BLINDED_ADDRESS_FLAG_SECRET_REQUIRED
+	}
+	if address.ClientAuth {
+		flags |= BLINDED_ADDRESS_FLAG_CLIENT_AUTH
+	}
+	var data []byte
+	if address.SigningKeyType > 255 || address.BlindedSigningKeyType > 255 {
+		flags |= BLINDED_ADDRESS_FLAG_TWO_BYTE_SIGTYPES
+		data = make([]byte, 5)
+		binary.BigEndian.PutUint16(data[1:3], uint16(address.SigningKeyType))
+		binary.BigEndian.PutUint16(data[3:5], uint16(address.BlindedSigningKeyType))
+	} else {
+		data = []byte{0x00, byte(address.SigningKeyType), byte(address.BlindedSigningKeyType)}
+	}
+	data[0] = byte(flags)
+	data = append(data, address.PublicKey[:]...)
+	checksum := make([]byte, 4)
+	binary.LittleEndian.PutUint32(checksum, crc32.ChecksumIEEE(data[BLINDED_ADDRESS_CHECKSUM_SIZE:]))
+	for i := 0; i < BLINDED_ADDRESS_CHECKSUM_SIZE; i++ {
+		data[i] ^= checksum[i]
+	}
+	return strings.Trim(base32.EncodeToString(data), "=") + BLINDED_ADDRESS_SUFFIX
+}
+
+//
+// Decode a blinded ".b32.i2p" address into a BlindedAddress, returning an error if the address
+// is malformed, fails its checksum, or does not contain an Ed25519 or RedDSA key.
+//
+func DecodeBlindedBase32Address(str string) (address BlindedAddress, err error) {
+	str = strings.TrimSuffix(strings.ToLower(str), BLINDED_ADDRESS_SUFFIX)
+	data, err := base32.DecodeFromString(str)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"at":     "DecodeBlindedBase32Address",
+			"reason": err.Error(),
+		}).Error("error parsing blinded address")
+		err = errors.New("error parsing blinded address: invalid base32")
+		return
+	}
+	data_len := len(data)
+	if data_len < BLINDED_ADDRESS_MIN_SIZE {
+		log.WithFields(log.Fields{
+			"at":           "DecodeBlindedBase32Address",
+			"data_len":     data_len,
+			"required_len": BLINDED_ADDRESS_MIN_SIZE,
+			"reason":       "not enough data",
+		}).Error("error parsing blinded address")
+		err = errors.New("error parsing blinded address: not enough data")
+		return
+	}
+	checksum := make([]byte, 4)
+	binary.LittleEndian.PutUint32(checksum, crc32.ChecksumIEEE(data[BLINDED_ADDRESS_CHECKSUM_SIZE:]))
+	for i := 0; i < BLINDED_ADDRESS_CHECKSUM_SIZE; i++ {
+		data[i] ^= checksum[i]
+	}
+	flags := int(data[0])
+	key_start := 3
+	if flags&BLINDED_ADDRESS_FLAG_TWO_BYTE_SIGTYPES != 0 {
+		key_start = 5
+		address.SigningKeyType = Integer(data[1:3])
+		address.BlindedSigningKeyType = Integer(data[3:5])
+	} else {
+		address.SigningKeyType = Integer(data[1:2])
+		address.BlindedSigningKeyType = Integer(data[2:3])
+	}
+	if (address.SigningKeyType != KEYCERT_SIGN_ED25519 && address.SigningKeyType != KEYCERT_SIGN_REDDSA_ED25519) ||
+		address.BlindedSigningKeyType != KEYCERT_SIGN_REDDSA_ED25519 {
+		log.WithFields(log.Fields{
+			"at":                       "DecodeBlindedBase32Address",
+			"signing_key_type":         address.SigningKeyType,
+			"blinded_signing_key_type": address.BlindedSigningKeyType,
+			"reason":                   "unsupported signing key type or bad checksum",
+		}).Error("error parsing blinded address")
+		err = errors.New("error parsing blinded address: unsupported signing key type or bad checksum")
+		return
+	}
+	if data_len != key_start+KEYCERT_SIGN_ED25519_SIZE {
+		log.WithFields(log.Fields{
+			"at":           "DecodeBlindedBase32Address",
+			"data_len":     data_len,
+			"required_len": key_start + KEYCERT_SIGN_ED25519_SIZE,
+			"reason":       "incorrect key size",
+		}).Error("error parsing blinded address")
+		err = errors.New("error parsing blinded address: incorrect key size")
+		return
+	}
+	copy(address.PublicKey[:], data[key_start:])
+	address.SecretRequired = flags&BLINDED_ADDRESS_FLAG_SECRET_REQUIRED != 0
+	address.ClientAuth = flags&BLINDED_ADDRESS_FLAG_CLIENT_AUTH != 0
+	return
+}
+
+//
+// Derive the blinding factor alpha for the key in this BlindedAddress on the UTC date of the
+// provided time.  The secret is empty if none is required.  The owner of the Destination blinds
+// its private key with the same alpha to sign EncryptedLeaseSets.
+//
+func (address BlindedAddress) BlindingFactor(date time.Time, secret string) (alpha []byte, err error) {
+	key_data := address.keyData()
+	salt := crypto.SHA256(append([]byte(BLINDING_SALT_PREFIX), key_data...))
+	ikm := []byte(date.UTC().Format(BLINDING_DATE_FORMAT) + secret)
+	seed := make([]byte, BLINDING_SEED_SIZE)
+	_, err = io.ReadFull(hkdf.New(sha256.New, ikm, salt[:], []byte(BLINDING_INFO)), seed)
+	if err != nil {
+		return
+	}
+	alpha, err = crypto.Ed25519BlindingFactor(seed)
+	return
+}
+
+//
+// Return the blinded public key for the UTC date of the provided time, which signs the
+// Destination's EncryptedLeaseSets on that day and is used to look them up in the netdb.
+//
+func (address BlindedAddress) BlindedPublicKey(date time.Time, secret string) (blinded crypto.Ed25519PublicKey, err error) {
+	alpha, err := address.BlindingFactor(date, secret)
+	if err != nil {
+		return
+	}
+	blinded, err = address.PublicKey.Blind(alpha)
+	return
+}
+
+//
+// The public key followed by both two byte signing key types, as hashed for the blinding
+// factor and credential.
+//
+func (address BlindedAddress) keyData() []byte {
+	key_data := make([]byte, KEYCERT_SIGN_ED25519_SIZE+4)
+	copy(key_data, address.PublicKey[:])
+	binary.BigEndian.PutUint16(key_data[32:34], uint16(address.SigningKeyType))
+	binary.BigEndian.PutUint16(key_data[34:36], uint16(address.BlindedSigningKeyType))
+	return key_data
+}
+
+//
+// Return the subcredential binding the unblinded key to a blinded key, which is mixed into
+// every key derived for EncryptedLeaseSet encryption.
+//
+func (address BlindedAddress) subcredential(blinded crypto.Ed25519PublicKey) []byte {
+	credential := crypto.SHA256(append([]byte("credential"), address.keyData()...))
+	subcredential_input := append([]byte("subcredential"), credential[:]...)
+	subcredential := crypto.SHA256(append(subcredential_input, blinded[:]...))
+	return subcredential[:]
+}
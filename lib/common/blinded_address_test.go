@@ -0,0 +1,171 @@
+package common
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"filippo.io/edwards25519"
+	"github.com/hkparker/go-i2p/lib/common/base32"
+	"github.com/hkparker/go-i2p/lib/crypto"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/hkdf"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlindedBase32AddressRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, _, err := buildEd25519Destination()
+	if !assert.Nil(err) {
+		return
+	}
+	str, err := destination.BlindedBase32Address(true, false)
+	assert.Nil(err)
+	assert.Equal(56+len(".b32.i2p"), len(str))
+	assert.True(strings.HasSuffix(str, ".b32.i2p"))
+
+	address, err := DecodeBlindedBase32Address(str)
+	assert.Nil(err)
+	signing_public_key, _ := destination.SigningPublicKey()
+	assert.Equal(signing_public_key.Bytes(), address.PublicKey[:])
+	assert.Equal(KEYCERT_SIGN_ED25519, address.SigningKeyType)
+	assert.Equal(KEYCERT_SIGN_REDDSA_ED25519, address.BlindedSigningKeyType)
+	assert.True(address.SecretRequired)
+	assert.False(address.ClientAuth)
+	assert.Equal(str, address.Base32Address())
+}
+
+func TestBlindedBase32AddressDiffersFromBase32Address(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, _, _ := buildEd25519Destination()
+	str, err := destination.BlindedBase32Address(false, false)
+	assert.Nil(err)
+	assert.NotEqual(destination.Base32Address(), str)
+	assert.True(len(str) > len(destination.Base32Address()))
+}
+
+func TestDecodeBlindedBase32AddressBadChecksum(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, _, _ := buildEd25519Destination()
+	str, _ := destination.BlindedBase32Address(false, false)
+	data, _ := base32.DecodeFromString(strings.TrimSuffix(str, ".b32.i2p"))
+	data[20] ^= 0x01
+	forged := strings.Trim(base32.EncodeToString(data), "=") + ".b32.i2p"
+
+	_, err := DecodeBlindedBase32Address(forged)
+	assert.NotNil(err)
+}
+
+func TestDecodeBlindedBase32AddressTooShort(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, _, _ := buildEd25519Destination()
+	_, err := DecodeBlindedBase32Address(destination.Base32Address())
+	if assert.NotNil(err) {
+		assert.Equal("error parsing blinded address: not enough data", err.Error())
+	}
+}
+
+func TestBlindedAddressRequiresEd25519(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, _, err := buildDSADestination()
+	if !assert.Nil(err) {
+		return
+	}
+	_, err = destination.BlindedBase32Address(false, false)
+	if assert.NotNil(err) {
+		assert.Equal("error building blinded address: unsupported signing key type", err.Error())
+	}
+}
+
+func TestBlindedPublicKeyRotatesDaily(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	address, _ := destination.BlindedAddress(false, false)
+	day := time.Date(2019, 5, 1, 0, 30, 0, 0, time.UTC)
+
+	morning, err := address.BlindedPublicKey(day, "")
+	assert.Nil(err)
+	evening, _ := address.BlindedPublicKey(day.Add(23*time.Hour), "")
+	tomorrow, _ := address.BlindedPublicKey(day.Add(24*time.Hour), "")
+	with_secret, _ := address.BlindedPublicKey(day, "secret")
+	assert.Equal(morning, evening)
+	assert.NotEqual(morning, tomorrow)
+	assert.NotEqual(morning, with_secret)
+
+	alpha, _ := address.BlindingFactor(day, "")
+	var private_key crypto.Ed25519PrivateKey
+	copy(private_key[:], ed25519.PrivateKey(signer).Seed())
+	blinded_signer, err := private_key.Blind(alpha)
+	assert.Nil(err)
+	assert.Equal(morning, blinded_signer.Public())
+}
+
+func TestBlindedAddressMatchesSpecification(t *testing.T) {
+	assert := assert.New(t)
+
+	// RFC 8032 section 7.1, test 1
+	public_key, _ := hex.DecodeString("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+	keys_and_cert, err := NewKeysAndCert(KEYCERT_CRYPTO_X25519, make([]byte, 32), KEYCERT_SIGN_ED25519, public_key)
+	if !assert.Nil(err) {
+		return
+	}
+	destination := Destination(keys_and_cert)
+
+	// flags, signing key type 7 and blinded type 11, then the key, with the first three bytes
+	// xored with the little-endian crc32 of the key
+	checksum := crc32.ChecksumIEEE(public_key)
+	for _, options := range []struct {
+		secret_required bool
+		client_auth     bool
+		flags           byte
+	}{
+		{false, false, 0x00},
+		{true, false, 0x02},
+		{false, true, 0x04},
+		{true, true, 0x06},
+	} {
+		data := append([]byte{options.flags, 0x07, 0x0b}, public_key...)
+		data[0] ^= byte(checksum)
+		data[1] ^= byte(checksum >> 8)
+		data[2] ^= byte(checksum >> 16)
+		str, err := destination.BlindedBase32Address(options.secret_required, options.client_auth)
+		assert.Nil(err)
+		assert.Equal(strings.TrimRight(base32.EncodeToString(data), "=")+".b32.i2p", str)
+	}
+
+	// alpha is the HKDF of the UTC date and secret, salted with the hash of the key and both
+	// signing key types, reduced mod L, and the blinded key is A + [alpha]B
+	address, _ := destination.BlindedAddress(false, false)
+	salt := sha256.Sum256(append(append([]byte("I2PGenerateAlpha"), public_key...), 0x00, 0x07, 0x00, 0x0b))
+	for _, day := range []struct {
+		date   time.Time
+		secret string
+		ikm    string
+	}{
+		{time.Date(2019, 5, 14, 0, 0, 0, 0, time.UTC), "", "20190514"},
+		{time.Date(2019, 5, 15, 23, 59, 59, 0, time.UTC), "", "20190515"},
+		{time.Date(2019, 5, 14, 12, 0, 0, 0, time.FixedZone("UTC+14", 14*60*60)), "secret", "20190513secret"},
+	} {
+		seed := make([]byte, 64)
+		io.ReadFull(hkdf.New(sha256.New, []byte(day.ikm), salt[:], []byte("i2pblinding1")), seed)
+		expected_alpha, _ := edwards25519.NewScalar().SetUniformBytes(seed)
+		A, _ := new(edwards25519.Point).SetBytes(public_key)
+		expected_blinded := new(edwards25519.Point).Add(A, new(edwards25519.Point).ScalarBaseMult(expected_alpha))
+
+		alpha, err := address.BlindingFactor(day.date, day.secret)
+		assert.Nil(err)
+		assert.Equal(expected_alpha.Bytes(), alpha)
+		blinded, err := address.BlindedPublicKey(day.date, day.secret)
+		assert.Nil(err)
+		assert.Equal(expected_blinded.Bytes(), blinded[:])
+	}
+}
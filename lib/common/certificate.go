@@ -24,6 +24,14 @@ length :: Integer
 
 payload :: data
            length -> $length bytes
+
+           case NULL -> empty
+           case HASHCASH -> an ASCII hashcash stamp
+           case HIDDEN -> empty
+           case SIGNED -> a 40 byte DSA signature, followed by the 32 byte hash of the
+                          signing Destination if it is not the holder's
+           case MULTIPLE -> a sequence of Certificates
+           case KEY -> see KeyCertificate
 */
 
 import (
@@ -46,6 +54,14 @@ const (
 	CERT_MIN_SIZE = 3
 )
 
+// Sizes of Certificate payloads
+const (
+	CERT_MAX_PAYLOAD_SIZE      = 65535
+	CERT_SIGNED_SIZE           = 40
+	CERT_SIGNED_WITH_HASH_SIZE = 72
+	CERT_KEY_MIN_PAYLOAD_SIZE  = 4
+)
+
 type Certificate []byte
 
 //
@@ -137,3 +153,184 @@ func ReadCertificate(data []byte) (certificate Certificate, remainder []byte, er
 	}
 	return
 }
+
+//
+// Build a Certificate of the provided type around a payload, returning an error if the type is
+// unsupported or the payload is not valid for the type.
+//
+func NewCertificate(cert_type int, payload []byte) (certificate Certificate, err error) {
+	payload_len := len(payload)
+	if payload_len > CERT_MAX_PAYLOAD_SIZE {
+		log.WithFields(log.Fields{
+			"at":          "NewCertificate",
+			"payload_len": payload_len,
+			"max_len":     CERT_MAX_PAYLOAD_SIZE,
+			"reason":      "too much data",
+		}).Error("error building certificate")
+		err = errors.New("error building certificate: payload too large")
+		return
+	}
+	if cert_type < CERT_NULL || cert_type > CERT_KEY {
+		err = unsupportedCertificateType("NewCertificate", cert_type)
+		return
+	}
+	certificate = Certificate{byte(cert_type), byte(payload_len >> 8), byte(payload_len)}
+	certificate = append(certificate, payload...)
+	err = certificate.Validate()
+	if err != nil {
+		certificate = nil
+	}
+	return
+}
+
+//
+// Build a NULL Certificate, the Certificate of a KeysAndCert holding an ElGamal PublicKey
+// and a DSA SHA1 SigningPublicKey.
+//
+func NewNullCertificate() Certificate {
+	return Certificate{CERT_NULL, 0x00, 0x00}
+}
+
+//
+// Check that the Certificate is complete, is of a type defined by the specification, and has a
+// payload that is valid for its type.  A MULTIPLE Certificate is valid only if every
+// Certificate it contains is valid.
+//
+func (certificate Certificate) Validate() (err error) {
+	cert_type, err := certificate.Type()
+	if err != nil {
+		return
+	}
+	data, err := certificate.Data()
+	if err != nil {
+		return
+	}
+	valid := true
+	switch cert_type {
+	case CERT_NULL, CERT_HIDDEN:
+		valid = len(data) == 0
+	case CERT_HASHCASH:
+		valid = len(data) != 0
+	case CERT_SIGNED:
+		valid = len(data) == CERT_SIGNED_SIZE || len(data) == CERT_SIGNED_WITH_HASH_SIZE
+	case CERT_MULTIPLE:
+		_, err = certificate.Certificates()
+		return
+	case CERT_KEY:
+		valid = len(data) >= CERT_KEY_MIN_PAYLOAD_SIZE
+	default:
+		err = unsupportedCertificateType("(Certificate) Validate", cert_type)
+		return
+	}
+	if !valid {
+		log.WithFields(log.Fields{
+			"at":          "(Certificate) Validate",
+			"cert_type":   cert_type,
+			"payload_len": len(data),
+			"reason":      "payload size does not match certificate type",
+		}).Error("invalid certificate")
+		err = errors.New("error parsing certificate: payload size does not match certificate type")
+	}
+	return
+}
+
+//
+// Return the hashcash stamp in a HASHCASH Certificate.
+//
+func (certificate Certificate) Hashcash() (stamp string, err error) {
+	data, err := certificate.payloadOfType(CERT_HASHCASH)
+	stamp = string(data)
+	return
+}
+
+//
+// Return the signature in a SIGNED Certificate, and the hash of the signing Destination if it
+// is included.
+//
+func (certificate Certificate) SignedBy() (signature []byte, signer_hash []byte, err error) {
+	data, err := certificate.payloadOfType(CERT_SIGNED)
+	if err != nil {
+		return
+	}
+	signature = data[:CERT_SIGNED_SIZE]
+	if len(data) == CERT_SIGNED_WITH_HASH_SIZE {
+		signer_hash = data[CERT_SIGNED_SIZE:]
+	}
+	return
+}
+
+//
+// Return the Certificates contained in a MULTIPLE Certificate, returning an error if any of
+// them is incomplete or invalid.
+//
+func (certificate Certificate) Certificates() (certificates []Certificate, err error) {
+	cert_type, err := certificate.Type()
+	if err != nil {
+		return
+	}
+	if cert_type != CERT_MULTIPLE {
+		err = wrongCertificateType("(Certificate) Certificates", cert_type)
+		return
+	}
+	remainder, err := certificate.Data()
+	if err != nil {
+		return
+	}
+	for len(remainder) > 0 {
+		var inner Certificate
+		inner, remainder, err = ReadCertificate(remainder)
+		if err == nil {
+			err = inner.Validate()
+		}
+		if err != nil {
+			certificates = nil
+			return
+		}
+		certificates = append(certificates, inner)
+	}
+	return
+}
+
+//
+// Return the payload of a valid Certificate of the expected type.
+//
+func (certificate Certificate) payloadOfType(expected_type int) (data []byte, err error) {
+	cert_type, err := certificate.Type()
+	if err != nil {
+		return
+	}
+	if cert_type != expected_type {
+		err = wrongCertificateType("(Certificate) payloadOfType", cert_type)
+		return
+	}
+	err = certificate.Validate()
+	if err != nil {
+		return
+	}
+	data, err = certificate.Data()
+	return
+}
+
+//
+// Log and return an error for a Certificate Type that is not defined by the specification.
+//
+func unsupportedCertificateType(at string, cert_type int) error {
+	log.WithFields(log.Fields{
+		"at":        at,
+		"cert_type": cert_type,
+		"reason":    "unsupported certificate type",
+	}).Error("invalid certificate")
+	return errors.New("error parsing certificate: unsupported certificate type")
+}
+
+//
+// Log and return an error for a Certificate that is not of the type a caller requires.
+//
+func wrongCertificateType(at string, cert_type int) error {
+	log.WithFields(log.Fields{
+		"at":        at,
+		"cert_type": cert_type,
+		"reason":    "wrong certificate type",
+	}).Error("invalid certificate")
+	return errors.New("error parsing certificate: wrong certificate type")
+}
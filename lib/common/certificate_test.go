@@ -146,3 +146,93 @@ func TestReadCertificateWithInvalidLength(t *testing.T) {
 		assert.Equal("error parsing certificate length: certificate is too short", err.Error(), "correct error message should be returned")
 	}
 }
+
+func TestNewNullCertificate(t *testing.T) {
+	assert := assert.New(t)
+
+	certificate := NewNullCertificate()
+	assert.Equal(Certificate{0x00, 0x00, 0x00}, certificate)
+	assert.Nil(certificate.Validate())
+	built, err := NewCertificate(CERT_NULL, nil)
+	assert.Nil(err)
+	assert.Equal(certificate, built)
+}
+
+func TestNewCertificateRejectsInvalidPayloads(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewCertificate(CERT_NULL, []byte{0x01})
+	if assert.NotNil(err) {
+		assert.Equal("error parsing certificate: payload size does not match certificate type", err.Error())
+	}
+	_, err = NewCertificate(CERT_SIGNED, make([]byte, 41))
+	assert.NotNil(err)
+	_, err = NewCertificate(CERT_KEY, []byte{0x00, 0x07})
+	assert.NotNil(err)
+	_, err = NewCertificate(6, nil)
+	if assert.NotNil(err) {
+		assert.Equal("error parsing certificate: unsupported certificate type", err.Error())
+	}
+	_, err = NewCertificate(CERT_HASHCASH, make([]byte, CERT_MAX_PAYLOAD_SIZE+1))
+	if assert.NotNil(err) {
+		assert.Equal("error building certificate: payload too large", err.Error())
+	}
+}
+
+func TestHashcashCertificate(t *testing.T) {
+	assert := assert.New(t)
+
+	certificate, err := NewCertificate(CERT_HASHCASH, []byte("1:20:060408:example"))
+	if !assert.Nil(err) {
+		return
+	}
+	stamp, err := certificate.Hashcash()
+	assert.Nil(err)
+	assert.Equal("1:20:060408:example", stamp)
+	_, _, err = certificate.SignedBy()
+	if assert.NotNil(err) {
+		assert.Equal("error parsing certificate: wrong certificate type", err.Error())
+	}
+}
+
+func TestSignedCertificate(t *testing.T) {
+	assert := assert.New(t)
+
+	certificate, err := NewCertificate(CERT_SIGNED, make([]byte, CERT_SIGNED_SIZE))
+	if !assert.Nil(err) {
+		return
+	}
+	signature, signer_hash, err := certificate.SignedBy()
+	assert.Nil(err)
+	assert.Equal(CERT_SIGNED_SIZE, len(signature))
+	assert.Nil(signer_hash)
+
+	payload := make([]byte, CERT_SIGNED_WITH_HASH_SIZE)
+	payload[CERT_SIGNED_SIZE] = 0xff
+	certificate, _ = NewCertificate(CERT_SIGNED, payload)
+	_, signer_hash, err = certificate.SignedBy()
+	assert.Nil(err)
+	assert.Equal(32, len(signer_hash))
+	assert.Equal(byte(0xff), signer_hash[0])
+}
+
+func TestMultipleCertificate(t *testing.T) {
+	assert := assert.New(t)
+
+	hidden, _ := NewCertificate(CERT_HIDDEN, nil)
+	hashcash, _ := NewCertificate(CERT_HASHCASH, []byte("stamp"))
+	certificate, err := NewCertificate(CERT_MULTIPLE, append(hidden, hashcash...))
+	if !assert.Nil(err) {
+		return
+	}
+	certificates, err := certificate.Certificates()
+	assert.Nil(err)
+	assert.Equal([]Certificate{hidden, hashcash}, certificates)
+
+	_, err = NewCertificate(CERT_MULTIPLE, append(hidden, 0x01, 0x00, 0x05))
+	assert.NotNil(err)
+	_, err = NewCertificate(CERT_MULTIPLE, []byte{0x09, 0x00, 0x00})
+	if assert.NotNil(err) {
+		assert.Equal("error parsing certificate: unsupported certificate type", err.Error())
+	}
+}
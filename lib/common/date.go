@@ -7,6 +7,7 @@ Accurate for version 0.9.24
 */
 
 import (
+	"encoding/binary"
 	"time"
 )
 
@@ -22,3 +23,19 @@ func (date Date) Time() (date_time time.Time) {
 	date_time = time.Unix(0, int64(seconds*1000000))
 	return
 }
+
+//
+// Create a Date from a Go time.Time, truncated to millisecond precision.
+//
+func DateFromTime(date_time time.Time) (date Date) {
+	binary.BigEndian.PutUint64(date[:], uint64(date_time.UnixNano()/1000000))
+	return
+}
+
+//
+// Create a Date from a 4 byte big-endian integer representing the number of seconds since
+// the beginning of unix time, as used in LeaseSet2 structures.
+//
+func dateFromSeconds(seconds []byte) (date Date) {
+	return DateFromTime(time.Unix(int64(Integer(seconds)), 0))
+}
@@ -3,6 +3,7 @@ package common
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestTimeFromMiliseconds(t *testing.T) {
@@ -13,3 +14,11 @@ func TestTimeFromMiliseconds(t *testing.T) {
 
 	assert.Equal(int64(86400), go_time.Unix(), "Date.Time() did not parse time in milliseconds")
 }
+
+func TestDateFromTime(t *testing.T) {
+	assert := assert.New(t)
+
+	date := DateFromTime(time.Unix(86400, 0))
+
+	assert.Equal(Date{0x00, 0x00, 0x00, 0x00, 0x05, 0x26, 0x5c, 0x00}, date, "DateFromTime() did not encode time in milliseconds")
+}
@@ -0,0 +1,883 @@
+package common
+
+/*
+I2P EncryptedLeaseSet
+https://geti2p.net/spec/common-structures#encryptedleaseset
+Accurate for version 0.9.39
+
++----+----+----+----+----+----+----+----+
+| sigtype |                             |
++----+----+                             +
+|        blinded_public_key             |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+|     published     | expires |  flags  |
++----+----+----+----+----+----+----+----+
+| offline_signature (optional)          |
++                                       +
+|                                       |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+|  len    |                             |
++----+----+                             +
+|         encrypted_data                |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+| signature                             |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+
+sigtype :: A two byte signature type of the public key to follow
+           length -> 2 bytes
+
+blinded_public_key :: Signing public key, blinded with the daily blinding factor
+                      length -> As implied by the sig type, 32 bytes for RedDSA
+
+published :: 4 byte date
+             length -> 4 bytes
+             Seconds since the epoch, rolls over in 2106.
+
+expires :: 2 byte time
+           length -> 2 bytes
+           Offset from published timestamp in seconds, 18.2 hours max
+
+flags :: 2 bytes
+         Bit 0: If 0, no offline keys; if 1, offline keys
+         Bit 1: If 0, a standard published leaseset.
+                If 1, an unpublished leaseset.
+         Bits 15-2: set to 0 for compatibility with future uses
+
+offline_signature :: OfflineSignature
+                     length -> varies
+                     Optional, only present if bit 0 is set in the flags.
+
+len :: Integer
+       length -> 2 bytes
+       Length of encrypted_data to follow
+
+encrypted_data :: Data encrypted
+                  length -> len bytes
+
+signature :: Signature
+             length -> As implied by the sig type of the blinded key, or of the
+                       transient key if offline keys are used.
+             The signature is over the data above, prepended with the single byte
+             containing the DatabaseStore type (5).
+
+The encrypted_data is made of two layers, each the 32 byte salt used to derive a
+ChaCha20 key and IV followed by the ciphertext.  The outer layer decrypts to a flag
+byte, optional per-client authorization data, and the inner layer.  The inner layer
+decrypts to the DatabaseStore type byte of the LeaseSet2 or MetaLeaseSet and its data.
+*/
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/hkparker/go-i2p/lib/crypto"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"time"
+)
+
+// DatabaseStore type of an EncryptedLeaseSet, prepended to the data when signing
+const (
+	ENCRYPTED_LEASE_SET_TYPE = 5
+)
+
+// Sizes of various structures in an I2P EncryptedLeaseSet
+const (
+	ENCRYPTED_LEASE_SET_SIGTYPE_SIZE      = 2
+	ENCRYPTED_LEASE_SET_LENGTH_SIZE       = 2
+	ENCRYPTED_LEASE_SET_SALT_SIZE         = 32
+	ENCRYPTED_LEASE_SET_KEY_SIZE          = 32
+	ENCRYPTED_LEASE_SET_IV_SIZE           = 12
+	ENCRYPTED_LEASE_SET_CLIENT_ID_SIZE    = 8
+	ENCRYPTED_LEASE_SET_COOKIE_SIZE       = 32
+	ENCRYPTED_LEASE_SET_CLIENT_COUNT_SIZE = 2
+	ENCRYPTED_LEASE_SET_MAX_DATA_SIZE     = 65535
+)
+
+// Per-client authorization schemes
+const (
+	ENCRYPTED_LEASE_SET_AUTH_DH  = 0
+	ENCRYPTED_LEASE_SET_AUTH_PSK = 1
+)
+
+// Flags in the decrypted outer layer of an EncryptedLeaseSet
+const (
+	ENCRYPTED_LEASE_SET_FLAG_CLIENT_AUTH = 1 << 0
+	ENCRYPTED_LEASE_SET_AUTH_SCHEME_MASK = 0x0e
+)
+
+// HKDF info strings used for EncryptedLeaseSet key derivation
+const (
+	ENCRYPTED_LEASE_SET_LAYER1_INFO = "ELS2_L1K"
+	ENCRYPTED_LEASE_SET_LAYER2_INFO = "ELS2_L2K"
+	ENCRYPTED_LEASE_SET_DH_INFO     = "ELS2_XCA"
+	ENCRYPTED_LEASE_SET_PSK_INFO    = "ELS2PSKA"
+)
+
+type EncryptedLeaseSet []byte
+
+//
+// Per-client authorization for an EncryptedLeaseSet.  When building, Keys holds the X25519
+// public keys (DH) or pre-shared keys (PSK) of every authorized client.  When decrypting, the
+// first key is the client's own X25519 private key or pre-shared key.
+//
+type EncryptedLeaseSetAuth struct {
+	Scheme int
+	Keys   [][32]byte
+}
+
+//
+// Build an EncryptedLeaseSet publishing the inner LeaseSet2 or MetaLeaseSet of a Destination
+// under its blinded key for the UTC date it is published.  The secret is empty if none is
+// required, and auth is nil if any client knowing the address may decrypt the inner lease set.
+// The Signer must belong to the Destination's private key blinded for the same day, or to the
+// transient key if an OfflineSignature made by the blinded key is provided.
+//
+func NewEncryptedLeaseSet(destination Destination, secret string, inner_type int, inner []byte, published, expires time.Time, flags int, offline_signature OfflineSignature, auth *EncryptedLeaseSetAuth, signer crypto.Signer) (encrypted_lease_set EncryptedLeaseSet, err error) {
+	if inner_type != LEASE_SET2_TYPE && inner_type != META_LEASE_SET_TYPE {
+		log.WithFields(log.Fields{
+			"at":         "NewEncryptedLeaseSet",
+			"inner_type": inner_type,
+			"reason":     "unsupported inner lease set type",
+		}).Error("error building encrypted lease set")
+		err = errors.New("error building encrypted lease set: unsupported inner lease set type")
+		return
+	}
+	address, err := destination.BlindedAddress(secret != "", auth != nil)
+	if err != nil {
+		return
+	}
+	blinded, err := address.BlindedPublicKey(published, secret)
+	if err != nil {
+		return
+	}
+	expires_offset := expires.Unix() - published.Unix()
+	if expires_offset < 0 || expires_offset > LEASE_SET2_MAX_EXPIRES {
+		log.WithFields(log.Fields{
+			"at":             "NewEncryptedLeaseSet",
+			"expires_offset": expires_offset,
+			"max_offset":     LEASE_SET2_MAX_EXPIRES,
+			"reason":         "expiration out of range",
+		}).Error("error building encrypted lease set")
+		err = errors.New("error building encrypted lease set: expiration out of range")
+		return
+	}
+	signing_key_type := address.BlindedSigningKeyType
+	flags &^= LEASE_SET2_FLAG_OFFLINE_KEYS | LEASE_SET2_FLAG_BLINDED
+	if offline_signature != nil {
+		flags |= LEASE_SET2_FLAG_OFFLINE_KEYS
+		offline_signature, _, err = ReadOfflineSignature(offline_signature, signing_key_type)
+		if err != nil {
+			return
+		}
+		signing_key_type, _ = offline_signature.TransientSigningPublicKeyType()
+	}
+	sigtype := make([]byte, ENCRYPTED_LEASE_SET_SIGTYPE_SIZE)
+	binary.BigEndian.PutUint16(sigtype, uint16(address.BlindedSigningKeyType))
+	fields := make([]byte, LEASE_SET2_HEADER_DATA_SIZE)
+	binary.BigEndian.PutUint32(fields[:4], uint32(published.Unix()))
+	binary.BigEndian.PutUint16(fields[4:6], uint16(expires_offset))
+	binary.BigEndian.PutUint16(fields[6:8], uint16(flags))
+	data := make([]byte, 0)
+	data = append(data, sigtype...)
+	data = append(data, blinded[:]...)
+	data = append(data, fields...)
+	data = append(data, offline_signature...)
+
+	subcredential := address.subcredential(blinded)
+	published_bytes := fields[:LEASE_SET2_PUBLISHED_SIZE]
+	encrypted_data, err := encryptLeaseSetLayers(subcredential, published_bytes, inner_type, inner, auth)
+	if err != nil {
+		return
+	}
+	encrypted_data_len := len(encrypted_data)
+	if encrypted_data_len > ENCRYPTED_LEASE_SET_MAX_DATA_SIZE {
+		log.WithFields(log.Fields{
+			"at":       "NewEncryptedLeaseSet",
+			"data_len": encrypted_data_len,
+			"max_len":  ENCRYPTED_LEASE_SET_MAX_DATA_SIZE,
+			"reason":   "encrypted data too large",
+		}).Error("error building encrypted lease set")
+		err = errors.New("error building encrypted lease set: encrypted data too large")
+		return
+	}
+	length := make([]byte, ENCRYPTED_LEASE_SET_LENGTH_SIZE)
+	binary.BigEndian.PutUint16(length, uint16(encrypted_data_len))
+	data = append(data, length...)
+	data = append(data, encrypted_data...)
+
+	signature, err := signer.Sign(append([]byte{ENCRYPTED_LEASE_SET_TYPE}, data...))
+	if err != nil {
+		return
+	}
+	if len(signature) != SignatureSizeByType(signing_key_type) {
+		log.WithFields(log.Fields{
+			"at":             "NewEncryptedLeaseSet",
+			"signature_len":  len(signature),
+			"required_len":   SignatureSizeByType(signing_key_type),
+			"signature_type": signing_key_type,
+			"reason":         "signature size does not match signing key",
+		}).Error("error building encrypted lease set")
+		err = errors.New("error building encrypted lease set: signature size does not match signing key")
+		return
+	}
+	encrypted_lease_set = EncryptedLeaseSet(append(data, signature...))
+	if verify_err := encrypted_lease_set.Verify(); verify_err != nil {
+		encrypted_lease_set = nil
+		if verify_err != crypto.ErrInvalidSignature {
+			err = verify_err
+			return
+		}
+		log.WithFields(log.Fields{
+			"at":     "NewEncryptedLeaseSet",
+			"reason": "signer does not match signing key",
+		}).Error("error building encrypted lease set")
+		err = errors.New("error building encrypted lease set: signer does not match signing key")
+	}
+	return
+}
+
+//
+// Return the Signing Key Type of the blinded key that signed this EncryptedLeaseSet.
+//
+func (encrypted_lease_set EncryptedLeaseSet) BlindedSigningKeyType() (signing_key_type int, err error) {
+	els_len := len(encrypted_lease_set)
+	if els_len < ENCRYPTED_LEASE_SET_SIGTYPE_SIZE {
+		log.WithFields(log.Fields{
+			"at":           "(EncryptedLeaseSet) BlindedSigningKeyType",
+			"data_len":     els_len,
+			"required_len": ENCRYPTED_LEASE_SET_SIGTYPE_SIZE,
+			"reason":       "not enough data",
+		}).Error("error parsing encrypted lease set")
+		err = errors.New("error parsing encrypted lease set: not enough data")
+		return
+	}
+	signing_key_type = Integer(encrypted_lease_set[:ENCRYPTED_LEASE_SET_SIGTYPE_SIZE])
+	return
+}
+
+//
+// Return the blinded SigningPublicKey of this EncryptedLeaseSet, which is used to look it up in
+// the netdb and to verify it.
+//
+func (encrypted_lease_set EncryptedLeaseSet) BlindedSigningPublicKey() (signing_public_key crypto.SigningPublicKey, err error) {
+	end, err := encrypted_lease_set.blindedKeyEnd()
+	if err != nil {
+		return
+	}
+	signing_key_type, _ := encrypted_lease_set.BlindedSigningKeyType()
+	signing_public_key, err = ConstructSigningPublicKeyByType(
+		signing_key_type,
+		encrypted_lease_set[ENCRYPTED_LEASE_SET_SIGTYPE_SIZE:end],
+	)
+	return
+}
+
+//
+// Return the Date the EncryptedLeaseSet was published.
+//
+func (encrypted_lease_set EncryptedLeaseSet) Published() (date Date, err error) {
+	fields, err := encrypted_lease_set.fields()
+	if err != nil {
+		return
+	}
+	date = dateFromSeconds(fields[:4])
+	return
+}
+
+//
+// Return the Date the EncryptedLeaseSet expires.
+//
+func (encrypted_lease_set EncryptedLeaseSet) Expires() (date Date, err error) {
+	fields, err := encrypted_lease_set.fields()
+	if err != nil {
+		return
+	}
+	expires := Integer(fields[:4]) + Integer(fields[4:6])
+	date = DateFromTime(time.Unix(int64(expires), 0))
+	return
+}
+
+//
+// Return the flags set in the EncryptedLeaseSet.
+//
+func (encrypted_lease_set EncryptedLeaseSet) Flags() (flags int, err error) {
+	fields, err := encrypted_lease_set.fields()
+	if err != nil {
+		return
+	}
+	flags = Integer(fields[6:8])
+	return
+}
+
+//
+// Return true if the EncryptedLeaseSet is signed by a transient key certified by an OfflineSignature.
+//
+func (encrypted_lease_set EncryptedLeaseSet) HasOfflineKeys() bool {
+	flags, err := encrypted_lease_set.Flags()
+	return err == nil && flags&LEASE_SET2_FLAG_OFFLINE_KEYS != 0
+}
+
+//
+// Return true if the EncryptedLeaseSet should not be flooded, published or sent in response to a query.
+//
+func (encrypted_lease_set EncryptedLeaseSet) IsUnpublished() bool {
+	flags, err := encrypted_lease_set.Flags()
+	return err == nil && flags&LEASE_SET2_FLAG_UNPUBLISHED != 0
+}
+
+//
+// Return the OfflineSignature in the EncryptedLeaseSet, or nil if offline keys are not used.
+//
+func (encrypted_lease_set EncryptedLeaseSet) OfflineSignature() (offline_signature OfflineSignature, err error) {
+	end, err := encrypted_lease_set.blindedKeyEnd()
+	if err != nil {
+		return
+	}
+	if !encrypted_lease_set.HasOfflineKeys() {
+		return
+	}
+	signing_key_type, _ := encrypted_lease_set.BlindedSigningKeyType()
+	offline_signature, _, err = ReadOfflineSignature(encrypted_lease_set[end+LEASE_SET2_HEADER_DATA_SIZE:], signing_key_type)
+	return
+}
+
+//
+// Return the encrypted data of the EncryptedLeaseSet, including the outer layer salt.
+//
+func (encrypted_lease_set EncryptedLeaseSet) EncryptedData() (encrypted_data []byte, err error) {
+	start, end, err := encrypted_lease_set.encryptedDataBounds()
+	if err != nil {
+		return
+	}
+	encrypted_data = encrypted_lease_set[start:end]
+	return
+}
+
+//
+// Return the Signature of the EncryptedLeaseSet.
+//
+func (encrypted_lease_set EncryptedLeaseSet) Signature() (signature Signature, err error) {
+	_, end, err := encrypted_lease_set.encryptedDataBounds()
+	if err != nil {
+		return
+	}
+	signing_key_type, err := encrypted_lease_set.signingPublicKeyType()
+	if err != nil {
+		return
+	}
+	sig_size := SignatureSizeByType(signing_key_type)
+	els_len := len(encrypted_lease_set)
+	if els_len < end+sig_size {
+		log.WithFields(log.Fields{
+			"at":           "(EncryptedLeaseSet) Signature",
+			"data_len":     els_len,
+			"required_len": end + sig_size,
+			"reason":       "not enough data",
+		}).Error("error parsing encrypted lease set")
+		err = errors.New("error parsing encrypted lease set: not enough data")
+		return
+	}
+	signature = Signature(encrypted_lease_set[end : end+sig_size])
+	return
+}
+
+//
+// Verify the Signature of the EncryptedLeaseSet against its blinded key, or against the transient
+// key certified by the blinded key if offline keys are used.
+//
+func (encrypted_lease_set EncryptedLeaseSet) Verify() (err error) {
+	signature, err := encrypted_lease_set.Signature()
+	if err != nil {
+		return
+	}
+	signing_public_key, err := encrypted_lease_set.BlindedSigningPublicKey()
+	if err != nil {
+		return
+	}
+	if encrypted_lease_set.HasOfflineKeys() {
+		var offline_signature OfflineSignature
+		offline_signature, err = encrypted_lease_set.OfflineSignature()
+		if err != nil {
+			return
+		}
+		err = offline_signature.Verify(signing_public_key)
+		if err != nil {
+			return
+		}
+		transient_expires, _ := offline_signature.Expires()
+		published, _ := encrypted_lease_set.Published()
+		if transient_expires.Time().Before(published.Time()) {
+			log.WithFields(log.Fields{
+				"at":        "(EncryptedLeaseSet) Verify",
+				"expires":   transient_expires.Time(),
+				"published": published.Time(),
+				"reason":    "transient key expired",
+			}).Error("error verifying encrypted lease set")
+			err = errors.New("error verifying encrypted lease set: transient key expired")
+			return
+		}
+		signing_public_key, err = offline_signature.TransientSigningPublicKey()
+		if err != nil {
+			return
+		}
+	}
+	verifier, err := signing_public_key.NewVerifier()
+	if err != nil {
+		return
+	}
+	_, end, _ := encrypted_lease_set.encryptedDataBounds()
+	data := append([]byte{ENCRYPTED_LEASE_SET_TYPE}, encrypted_lease_set[:end]...)
+	err = verifier.Verify(data, signature)
+	return
+}
+
+//
+// Decrypt the EncryptedLeaseSet published by the Destination of the BlindedAddress, returning
+// the DatabaseStore type and data of the inner LeaseSet2 or MetaLeaseSet.  The secret is empty
+// if none is required, and auth holds the client's key if per-client authorization is used.
+//
+func (encrypted_lease_set EncryptedLeaseSet) Decrypt(address BlindedAddress, secret string, auth *EncryptedLeaseSetAuth) (inner_type int, inner []byte, err error) {
+	encrypted_data, err := encrypted_lease_set.EncryptedData()
+	if err != nil {
+		return
+	}
+	published, _ := encrypted_lease_set.Published()
+	blinded, err := address.BlindedPublicKey(published.Time(), secret)
+	if err != nil {
+		return
+	}
+	end, _ := encrypted_lease_set.blindedKeyEnd()
+	if !bytes.Equal(blinded[:], encrypted_lease_set[ENCRYPTED_LEASE_SET_SIGTYPE_SIZE:end]) {
+		log.WithFields(log.Fields{
+			"at":     "(EncryptedLeaseSet) Decrypt",
+			"reason": "blinded key does not match address and secret",
+		}).Error("error decrypting encrypted lease set")
+		err = errors.New("error decrypting encrypted lease set: blinded key does not match address and secret")
+		return
+	}
+	fields, _ := encrypted_lease_set.fields()
+	subcredential := address.subcredential(blinded)
+	published_bytes := fields[:LEASE_SET2_PUBLISHED_SIZE]
+
+	layer1, err := decryptLeaseSetLayer(encrypted_data, subcredential, published_bytes, ENCRYPTED_LEASE_SET_LAYER1_INFO)
+	if err != nil {
+		return
+	}
+	auth_cookie, layer2, err := readLeaseSetClientAuth(layer1, subcredential, published_bytes, auth)
+	if err != nil {
+		return
+	}
+	ikm := append(append([]byte{}, auth_cookie...), subcredential...)
+	plaintext, err := decryptLeaseSetLayer(layer2, ikm, published_bytes, ENCRYPTED_LEASE_SET_LAYER2_INFO)
+	if err != nil {
+		return
+	}
+	if len(plaintext) < 1 {
+		log.WithFields(log.Fields{
+			"at":     "(EncryptedLeaseSet) Decrypt",
+			"reason": "empty inner lease set",
+		}).Error("error decrypting encrypted lease set")
+		err = errors.New("error decrypting encrypted lease set: not enough data")
+		return
+	}
+	inner_type = int(plaintext[0])
+	inner = plaintext[1:]
+	return
+}
+
+//
+// Return the offset of the end of the blinded key, where the published timestamp begins.
+//
+func (encrypted_lease_set EncryptedLeaseSet) blindedKeyEnd() (end int, err error) {
+	signing_key_type, err := encrypted_lease_set.BlindedSigningKeyType()
+	if err != nil {
+		return
+	}
+	key_size := newKeyCertificate(signing_key_type, KEYCERT_CRYPTO_ELG, nil).SigningPublicKeySize()
+	if key_size == 0 {
+		log.WithFields(log.Fields{
+			"at":               "(EncryptedLeaseSet) blindedKeyEnd",
+			"signing_key_type": signing_key_type,
+			"reason":           "unknown signing key type",
+		}).Error("error parsing encrypted lease set")
+		err = errors.New("error parsing encrypted lease set: unknown signing key type")
+		return
+	}
+	end = ENCRYPTED_LEASE_SET_SIGTYPE_SIZE + key_size
+	els_len := len(encrypted_lease_set)
+	if els_len < end {
+		log.WithFields(log.Fields{
+			"at":           "(EncryptedLeaseSet) blindedKeyEnd",
+			"data_len":     els_len,
+			"required_len": end,
+			"reason":       "not enough data",
+		}).Error("error parsing encrypted lease set")
+		err = errors.New("error parsing encrypted lease set: not enough data")
+	}
+	return
+}
+
+//
+// Return the published, expires and flags fields following the blinded key.
+//
+func (encrypted_lease_set EncryptedLeaseSet) fields() (fields []byte, err error) {
+	end, err := encrypted_lease_set.blindedKeyEnd()
+	if err != nil {
+		return
+	}
+	els_len := len(encrypted_lease_set)
+	if els_len < end+LEASE_SET2_HEADER_DATA_SIZE {
+		log.WithFields(log.Fields{
+			"at":           "(EncryptedLeaseSet) fields",
+			"data_len":     els_len,
+			"required_len": end + LEASE_SET2_HEADER_DATA_SIZE,
+			"reason":       "not enough data",
+		}).Error("error parsing encrypted lease set")
+		err = errors.New("error parsing encrypted lease set: not enough data")
+		return
+	}
+	fields = encrypted_lease_set[end : end+LEASE_SET2_HEADER_DATA_SIZE]
+	return
+}
+
+//
+// Return the offsets of the start and end of the encrypted data.
+//
+func (encrypted_lease_set EncryptedLeaseSet) encryptedDataBounds() (start, end int, err error) {
+	_, err = encrypted_lease_set.fields()
+	if err != nil {
+		return
+	}
+	key_end, _ := encrypted_lease_set.blindedKeyEnd()
+	length_start := key_end + LEASE_SET2_HEADER_DATA_SIZE
+	offline_signature, err := encrypted_lease_set.OfflineSignature()
+	if err != nil {
+		return
+	}
+	length_start += len(offline_signature)
+	els_len := len(encrypted_lease_set)
+	if els_len < length_start+ENCRYPTED_LEASE_SET_LENGTH_SIZE {
+		log.WithFields(log.Fields{
+			"at":           "(EncryptedLeaseSet) encryptedDataBounds",
+			"data_len":     els_len,
+			"required_len": length_start + ENCRYPTED_LEASE_SET_LENGTH_SIZE,
+			"reason":       "not enough data",
+		}).Error("error parsing encrypted lease set")
+		err = errors.New("error parsing encrypted lease set: not enough data")
+		return
+	}
+	start = length_start + ENCRYPTED_LEASE_SET_LENGTH_SIZE
+	end = start + Integer(encrypted_lease_set[length_start:start])
+	if els_len < end {
+		log.WithFields(log.Fields{
+			"at":           "(EncryptedLeaseSet) encryptedDataBounds",
+			"data_len":     els_len,
+			"required_len": end,
+			"reason":       "not enough data",
+		}).Error("error parsing encrypted lease set")
+		err = errors.New("error parsing encrypted lease set: not enough data")
+	}
+	return
+}
+
+//
+// Return the Signing Key Type of the key that signed the EncryptedLeaseSet.
+//
+func (encrypted_lease_set EncryptedLeaseSet) signingPublicKeyType() (signing_key_type int, err error) {
+	if encrypted_lease_set.HasOfflineKeys() {
+		var offline_signature OfflineSignature
+		offline_signature, err = encrypted_lease_set.OfflineSignature()
+		if err != nil {
+			return
+		}
+		signing_key_type, err = offline_signature.TransientSigningPublicKeyType()
+		return
+	}
+	signing_key_type, err = encrypted_lease_set.BlindedSigningKeyType()
+	return
+}
+
+//
+// Build both encryption layers around the inner lease set, adding per-client authorization
+// to the outer layer if auth is provided.
+//
+func encryptLeaseSetLayers(subcredential, published []byte, inner_type int, inner []byte, auth *EncryptedLeaseSetAuth) (encrypted_data []byte, err error) {
+	auth_cookie := []byte{}
+	layer1 := []byte{0x00}
+	if auth != nil {
+		auth_cookie = make([]byte, ENCRYPTED_LEASE_SET_COOKIE_SIZE)
+		_, err = io.ReadFull(rand.Reader, auth_cookie)
+		if err != nil {
+			return
+		}
+		layer1, err = buildLeaseSetClientAuth(auth, auth_cookie, subcredential, published)
+		if err != nil {
+			return
+		}
+	}
+	ikm := append(append([]byte{}, auth_cookie...), subcredential...)
+	layer2, err := encryptLeaseSetLayer(append([]byte{byte(inner_type)}, inner...), ikm, published, ENCRYPTED_LEASE_SET_LAYER2_INFO)
+	if err != nil {
+		return
+	}
+	encrypted_data, err = encryptLeaseSetLayer(append(layer1, layer2...), subcredential, published, ENCRYPTED_LEASE_SET_LAYER1_INFO)
+	return
+}
+
+//
+// Encrypt one layer with a random salt, returning the salt followed by the ciphertext.
+//
+func encryptLeaseSetLayer(plaintext, ikm, published []byte, info string) (ciphertext []byte, err error) {
+	salt := make([]byte, ENCRYPTED_LEASE_SET_SALT_SIZE)
+	_, err = io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return
+	}
+	key, iv, _, err := deriveLeaseSetKeys(salt, append(append([]byte{}, ikm...), published...), info, 0)
+	if err != nil {
+		return
+	}
+	ciphertext, err = leaseSetChaCha20(key, iv, plaintext)
+	ciphertext = append(salt, ciphertext...)
+	return
+}
+
+//
+// Decrypt one layer made of a salt followed by the ciphertext.
+//
+func decryptLeaseSetLayer(data, ikm, published []byte, info string) (plaintext []byte, err error) {
+	data_len := len(data)
+	if data_len < ENCRYPTED_LEASE_SET_SALT_SIZE {
+		log.WithFields(log.Fields{
+			"at":           "decryptLeaseSetLayer",
+			"data_len":     data_len,
+			"required_len": ENCRYPTED_LEASE_SET_SALT_SIZE,
+			"reason":       "not enough data",
+		}).Error("error decrypting encrypted lease set")
+		err = errors.New("error decrypting encrypted lease set: not enough data")
+		return
+	}
+	salt := data[:ENCRYPTED_LEASE_SET_SALT_SIZE]
+	key, iv, _, err := deriveLeaseSetKeys(salt, append(append([]byte{}, ikm...), published...), info, 0)
+	if err != nil {
+		return
+	}
+	plaintext, err = leaseSetChaCha20(key, iv, data[ENCRYPTED_LEASE_SET_SALT_SIZE:])
+	return
+}
+
+//
+// Build the flag byte and per-client authorization data of the outer layer, giving each client
+// a copy of the auth cookie encrypted under a key only that client can derive.
+//
+func buildLeaseSetClientAuth(auth *EncryptedLeaseSetAuth, auth_cookie, subcredential, published []byte) (layer1 []byte, err error) {
+	client_count := len(auth.Keys)
+	if client_count == 0 || client_count > 65535 {
+		log.WithFields(log.Fields{
+			"at":           "buildLeaseSetClientAuth",
+			"client_count": client_count,
+			"reason":       "must have between 1 and 65535 clients",
+		}).Error("error building encrypted lease set")
+		err = errors.New("error building encrypted lease set: invalid number of clients")
+		return
+	}
+	salt := make([]byte, ENCRYPTED_LEASE_SET_SALT_SIZE)
+	_, err = io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return
+	}
+	var esk []byte
+	switch auth.Scheme {
+	case ENCRYPTED_LEASE_SET_AUTH_DH:
+		// the ephemeral public key is the salt for each client's key derivation
+		esk = salt
+		salt, err = curve25519.X25519(esk, curve25519.Basepoint)
+		if err != nil {
+			return
+		}
+	case ENCRYPTED_LEASE_SET_AUTH_PSK:
+	default:
+		log.WithFields(log.Fields{
+			"at":     "buildLeaseSetClientAuth",
+			"scheme": auth.Scheme,
+			"reason": "unknown client auth scheme",
+		}).Error("error building encrypted lease set")
+		err = errors.New("error building encrypted lease set: unknown client auth scheme")
+		return
+	}
+	layer1 = []byte{byte(ENCRYPTED_LEASE_SET_FLAG_CLIENT_AUTH | auth.Scheme<<1)}
+	layer1 = append(layer1, salt...)
+	count := make([]byte, ENCRYPTED_LEASE_SET_CLIENT_COUNT_SIZE)
+	binary.BigEndian.PutUint16(count, uint16(client_count))
+	layer1 = append(layer1, count...)
+	for _, key := range auth.Keys {
+		var shared_secret, client_id, client_key, client_iv, client_cookie []byte
+		if esk != nil {
+			shared_secret, err = curve25519.X25519(esk, key[:])
+			if err != nil {
+				return
+			}
+		}
+		client_id, client_key, client_iv, err = leaseSetClientCipher(auth.Scheme, shared_secret, key[:], salt, subcredential, published)
+		if err != nil {
+			return
+		}
+		client_cookie, err = leaseSetChaCha20(client_key, client_iv, auth_cookie)
+		if err != nil {
+			return
+		}
+		layer1 = append(layer1, client_id...)
+		layer1 = append(layer1, client_cookie...)
+	}
+	return
+}
+
+//
+// Read the flag byte and any per-client authorization data at the start of the decrypted outer
+// layer, returning the auth cookie found with the client's key and the inner layer.
+//
+func readLeaseSetClientAuth(layer1, subcredential, published []byte, auth *EncryptedLeaseSetAuth) (auth_cookie, layer2 []byte, err error) {
+	if len(layer1) < 1 {
+		log.WithFields(log.Fields{
+			"at":     "readLeaseSetClientAuth",
+			"reason": "empty outer layer",
+		}).Error("error decrypting encrypted lease set")
+		err = errors.New("error decrypting encrypted lease set: not enough data")
+		return
+	}
+	flags := int(layer1[0])
+	if flags&ENCRYPTED_LEASE_SET_FLAG_CLIENT_AUTH == 0 {
+		layer2 = layer1[1:]
+		return
+	}
+	scheme := (flags & ENCRYPTED_LEASE_SET_AUTH_SCHEME_MASK) >> 1
+	if auth == nil || len(auth.Keys) == 0 || auth.Scheme != scheme {
+		log.WithFields(log.Fields{
+			"at":     "readLeaseSetClientAuth",
+			"scheme": scheme,
+			"reason": "client authorization required",
+		}).Error("error decrypting encrypted lease set")
+		err = errors.New("error decrypting encrypted lease set: client authorization required")
+		return
+	}
+	header_size := 1 + ENCRYPTED_LEASE_SET_SALT_SIZE + ENCRYPTED_LEASE_SET_CLIENT_COUNT_SIZE
+	if len(layer1) < header_size {
+		log.WithFields(log.Fields{
+			"at":           "readLeaseSetClientAuth",
+			"data_len":     len(layer1),
+			"required_len": header_size,
+			"reason":       "not enough data",
+		}).Error("error decrypting encrypted lease set")
+		err = errors.New("error decrypting encrypted lease set: not enough data")
+		return
+	}
+	salt := layer1[1 : 1+ENCRYPTED_LEASE_SET_SALT_SIZE]
+	client_count := Integer(layer1[1+ENCRYPTED_LEASE_SET_SALT_SIZE : header_size])
+	entry_size := ENCRYPTED_LEASE_SET_CLIENT_ID_SIZE + ENCRYPTED_LEASE_SET_COOKIE_SIZE
+	entries_end := header_size + client_count*entry_size
+	if len(layer1) < entries_end {
+		log.WithFields(log.Fields{
+			"at":           "readLeaseSetClientAuth",
+			"data_len":     len(layer1),
+			"required_len": entries_end,
+			"reason":       "not enough data",
+		}).Error("error decrypting encrypted lease set")
+		err = errors.New("error decrypting encrypted lease set: not enough data")
+		return
+	}
+	key := auth.Keys[0][:]
+	var shared_secret []byte
+	if scheme == ENCRYPTED_LEASE_SET_AUTH_DH {
+		// the client's private key derives both the shared secret and its own public key
+		shared_secret, err = curve25519.X25519(key, salt)
+		if err != nil {
+			return
+		}
+		key, err = curve25519.X25519(key, curve25519.Basepoint)
+		if err != nil {
+			return
+		}
+	}
+	client_id, client_key, client_iv, err := leaseSetClientCipher(scheme, shared_secret, key, salt, subcredential, published)
+	if err != nil {
+		return
+	}
+	for i := header_size; i < entries_end; i += entry_size {
+		if !bytes.Equal(layer1[i:i+ENCRYPTED_LEASE_SET_CLIENT_ID_SIZE], client_id) {
+			continue
+		}
+		auth_cookie, err = leaseSetChaCha20(client_key, client_iv, layer1[i+ENCRYPTED_LEASE_SET_CLIENT_ID_SIZE:i+entry_size])
+		layer2 = layer1[entries_end:]
+		return
+	}
+	log.WithFields(log.Fields{
+		"at":     "readLeaseSetClientAuth",
+		"reason": "client not authorized",
+	}).Error("error decrypting encrypted lease set")
+	err = errors.New("error decrypting encrypted lease set: client not authorized")
+	return
+}
+
+//
+// Derive a client's ID and the key and IV encrypting its copy of the auth cookie.  For DH the
+// key is the client's X25519 public key and the shared secret is from the ephemeral and client
+// keys, for PSK the key is the pre-shared key and the shared secret is empty.
+//
+func leaseSetClientCipher(scheme int, shared_secret, key, salt, subcredential, published []byte) (client_id, client_key, client_iv []byte, err error) {
+	info := ENCRYPTED_LEASE_SET_PSK_INFO
+	if scheme == ENCRYPTED_LEASE_SET_AUTH_DH {
+		info = ENCRYPTED_LEASE_SET_DH_INFO
+	}
+	ikm := append([]byte{}, shared_secret...)
+	ikm = append(ikm, key...)
+	ikm = append(ikm, subcredential...)
+	client_key, client_iv, client_id, err = deriveLeaseSetKeys(salt, append(ikm, published...), info, ENCRYPTED_LEASE_SET_CLIENT_ID_SIZE)
+	return
+}
+
+//
+// Derive a ChaCha20 key and IV, followed by extra bytes of output, with HKDF-SHA256.
+//
+func deriveLeaseSetKeys(salt, ikm []byte, info string, extra int) (key, iv, rest []byte, err error) {
+	okm := make([]byte, ENCRYPTED_LEASE_SET_KEY_SIZE+ENCRYPTED_LEASE_SET_IV_SIZE+extra)
+	_, err = io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte(info)), okm)
+	if err != nil {
+		return
+	}
+	key = okm[:ENCRYPTED_LEASE_SET_KEY_SIZE]
+	iv = okm[ENCRYPTED_LEASE_SET_KEY_SIZE : ENCRYPTED_LEASE_SET_KEY_SIZE+ENCRYPTED_LEASE_SET_IV_SIZE]
+	rest = okm[ENCRYPTED_LEASE_SET_KEY_SIZE+ENCRYPTED_LEASE_SET_IV_SIZE:]
+	return
+}
+
+//
+// Encrypt or decrypt data with ChaCha20, starting from block counter 1.
+//
+func leaseSetChaCha20(key, iv, data []byte) (output []byte, err error) {
+	cipher, err := chacha20.NewUnauthenticatedCipher(key, iv)
+	if err != nil {
+		return
+	}
+	cipher.SetCounter(1)
+	output = make([]byte, len(data))
+	cipher.XORKeyStream(output, data)
+	return
+}
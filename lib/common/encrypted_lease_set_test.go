@@ -0,0 +1,296 @@
+package common
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"github.com/hkparker/go-i2p/lib/crypto"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/curve25519"
+	"testing"
+	"time"
+)
+
+func buildInnerLeaseSet2(t *testing.T, destination Destination, signer crypto.Signer, published time.Time) LeaseSet2 {
+	header, err := NewLeaseSet2Header(destination, published, published.Add(10*time.Minute), LEASE_SET2_FLAG_BLINDED, nil)
+	assert.Nil(t, err)
+	lease_set, err := NewLeaseSet2(header, buildMapping(), buildLeaseSet2Keys(), buildLeaseSet2Leases(), signer)
+	assert.Nil(t, err)
+	return lease_set
+}
+
+func buildBlindedSigner(t *testing.T, destination Destination, signer testEd25519Signer, published time.Time, secret string) *crypto.RedDSASigner {
+	address, err := destination.BlindedAddress(secret != "", false)
+	assert.Nil(t, err)
+	alpha, err := address.BlindingFactor(published, secret)
+	assert.Nil(t, err)
+	var private_key crypto.Ed25519PrivateKey
+	copy(private_key[:], ed25519.PrivateKey(signer).Seed())
+	blinded_signer, err := private_key.Blind(alpha)
+	assert.Nil(t, err)
+	return blinded_signer
+}
+
+func TestEncryptedLeaseSetRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, err := buildEd25519Destination()
+	if !assert.Nil(err) {
+		return
+	}
+	published := time.Unix(1500000000, 0)
+	inner := buildInnerLeaseSet2(t, destination, signer, published)
+	blinded_signer := buildBlindedSigner(t, destination, signer, published, "")
+
+	encrypted_lease_set, err := NewEncryptedLeaseSet(destination, "", LEASE_SET2_TYPE, inner, published, published.Add(10*time.Minute), LEASE_SET2_FLAG_UNPUBLISHED, nil, nil, blinded_signer)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Nil(encrypted_lease_set.Verify())
+
+	signing_key_type, err := encrypted_lease_set.BlindedSigningKeyType()
+	assert.Nil(err)
+	assert.Equal(KEYCERT_SIGN_REDDSA_ED25519, signing_key_type)
+	blinded_key, err := encrypted_lease_set.BlindedSigningPublicKey()
+	assert.Nil(err)
+	blinded_public_key := blinded_signer.Public()
+	assert.Equal(blinded_public_key.Bytes(), blinded_key.Bytes())
+	published_date, _ := encrypted_lease_set.Published()
+	assert.Equal(published, published_date.Time())
+	expires, _ := encrypted_lease_set.Expires()
+	assert.Equal(published.Add(10*time.Minute), expires.Time())
+	assert.True(encrypted_lease_set.IsUnpublished())
+	assert.False(encrypted_lease_set.HasOfflineKeys())
+	signature, err := encrypted_lease_set.Signature()
+	assert.Nil(err)
+	assert.Equal(64, len(signature))
+
+	address, _ := destination.BlindedAddress(false, false)
+	inner_type, decrypted, err := encrypted_lease_set.Decrypt(address, "", nil)
+	assert.Nil(err)
+	assert.Equal(LEASE_SET2_TYPE, inner_type)
+	assert.Equal([]byte(inner), decrypted)
+	assert.Nil(LeaseSet2(decrypted).Verify())
+}
+
+func TestEncryptedLeaseSetWithSecret(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	inner := buildInnerLeaseSet2(t, destination, signer, published)
+	blinded_signer := buildBlindedSigner(t, destination, signer, published, "secret")
+
+	encrypted_lease_set, err := NewEncryptedLeaseSet(destination, "secret", LEASE_SET2_TYPE, inner, published, published.Add(10*time.Minute), 0, nil, nil, blinded_signer)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Nil(encrypted_lease_set.Verify())
+
+	address, _ := destination.BlindedAddress(true, false)
+	_, decrypted, err := encrypted_lease_set.Decrypt(address, "secret", nil)
+	assert.Nil(err)
+	assert.Equal([]byte(inner), decrypted)
+
+	_, _, err = encrypted_lease_set.Decrypt(address, "wrong", nil)
+	if assert.NotNil(err) {
+		assert.Equal("error decrypting encrypted lease set: blinded key does not match address and secret", err.Error())
+	}
+}
+
+func TestEncryptedLeaseSetWrongBlindedSigner(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	inner := buildInnerLeaseSet2(t, destination, signer, published)
+	yesterday_signer := buildBlindedSigner(t, destination, signer, published.Add(-24*time.Hour), "")
+
+	encrypted_lease_set, err := NewEncryptedLeaseSet(destination, "", LEASE_SET2_TYPE, inner, published, published.Add(10*time.Minute), 0, nil, nil, yesterday_signer)
+	if assert.NotNil(err) {
+		assert.Equal("error building encrypted lease set: signer does not match signing key", err.Error())
+	}
+	assert.Nil(encrypted_lease_set)
+}
+
+func TestEncryptedLeaseSetForgedData(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	inner := buildInnerLeaseSet2(t, destination, signer, published)
+	blinded_signer := buildBlindedSigner(t, destination, signer, published, "")
+	encrypted_lease_set, _ := NewEncryptedLeaseSet(destination, "", LEASE_SET2_TYPE, inner, published, published.Add(10*time.Minute), 0, nil, nil, blinded_signer)
+
+	encrypted_lease_set[100] ^= 0xff
+	assert.Equal(crypto.ErrInvalidSignature, encrypted_lease_set.Verify())
+}
+
+func TestEncryptedLeaseSetWithDHClientAuth(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	inner := buildInnerLeaseSet2(t, destination, signer, published)
+	blinded_signer := buildBlindedSigner(t, destination, signer, published, "")
+
+	var client_private_keys, client_public_keys [][32]byte
+	for i := 0; i < 3; i++ {
+		var private_key, public_key [32]byte
+		rand.Read(private_key[:])
+		public, _ := curve25519.X25519(private_key[:], curve25519.Basepoint)
+		copy(public_key[:], public)
+		client_private_keys = append(client_private_keys, private_key)
+		client_public_keys = append(client_public_keys, public_key)
+	}
+	auth := &EncryptedLeaseSetAuth{Scheme: ENCRYPTED_LEASE_SET_AUTH_DH, Keys: client_public_keys}
+	encrypted_lease_set, err := NewEncryptedLeaseSet(destination, "", LEASE_SET2_TYPE, inner, published, published.Add(10*time.Minute), 0, nil, auth, blinded_signer)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Nil(encrypted_lease_set.Verify())
+
+	address, _ := destination.BlindedAddress(false, true)
+	for _, private_key := range client_private_keys {
+		client_auth := &EncryptedLeaseSetAuth{Scheme: ENCRYPTED_LEASE_SET_AUTH_DH, Keys: [][32]byte{private_key}}
+		_, decrypted, err := encrypted_lease_set.Decrypt(address, "", client_auth)
+		assert.Nil(err)
+		assert.Equal([]byte(inner), decrypted)
+	}
+
+	var stranger [32]byte
+	rand.Read(stranger[:])
+	_, _, err = encrypted_lease_set.Decrypt(address, "", &EncryptedLeaseSetAuth{Scheme: ENCRYPTED_LEASE_SET_AUTH_DH, Keys: [][32]byte{stranger}})
+	if assert.NotNil(err) {
+		assert.Equal("error decrypting encrypted lease set: client not authorized", err.Error())
+	}
+	_, _, err = encrypted_lease_set.Decrypt(address, "", nil)
+	if assert.NotNil(err) {
+		assert.Equal("error decrypting encrypted lease set: client authorization required", err.Error())
+	}
+}
+
+func TestEncryptedLeaseSetWithPSKClientAuth(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	inner := buildInnerLeaseSet2(t, destination, signer, published)
+	blinded_signer := buildBlindedSigner(t, destination, signer, published, "")
+
+	var psk, other_psk [32]byte
+	rand.Read(psk[:])
+	rand.Read(other_psk[:])
+	auth := &EncryptedLeaseSetAuth{Scheme: ENCRYPTED_LEASE_SET_AUTH_PSK, Keys: [][32]byte{other_psk, psk}}
+	encrypted_lease_set, err := NewEncryptedLeaseSet(destination, "", LEASE_SET2_TYPE, inner, published, published.Add(10*time.Minute), 0, nil, auth, blinded_signer)
+	if !assert.Nil(err) {
+		return
+	}
+
+	address, _ := destination.BlindedAddress(false, true)
+	_, decrypted, err := encrypted_lease_set.Decrypt(address, "", &EncryptedLeaseSetAuth{Scheme: ENCRYPTED_LEASE_SET_AUTH_PSK, Keys: [][32]byte{psk}})
+	assert.Nil(err)
+	assert.Equal([]byte(inner), decrypted)
+
+	var wrong_psk [32]byte
+	_, _, err = encrypted_lease_set.Decrypt(address, "", &EncryptedLeaseSetAuth{Scheme: ENCRYPTED_LEASE_SET_AUTH_PSK, Keys: [][32]byte{wrong_psk}})
+	assert.NotNil(err)
+}
+
+func TestEncryptedLeaseSetWithOfflineKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	inner := buildInnerLeaseSet2(t, destination, signer, published)
+	blinded_signer := buildBlindedSigner(t, destination, signer, published, "")
+	offline_signature, transient_signer, err := buildOfflineSignature(blinded_signer, published.Add(time.Hour))
+	if !assert.Nil(err) {
+		return
+	}
+
+	encrypted_lease_set, err := NewEncryptedLeaseSet(destination, "", LEASE_SET2_TYPE, inner, published, published.Add(10*time.Minute), 0, offline_signature, nil, transient_signer)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.True(encrypted_lease_set.HasOfflineKeys())
+	parsed_offline_signature, err := encrypted_lease_set.OfflineSignature()
+	assert.Nil(err)
+	assert.Equal(offline_signature, parsed_offline_signature)
+	assert.Nil(encrypted_lease_set.Verify())
+
+	address, _ := destination.BlindedAddress(false, false)
+	_, decrypted, err := encrypted_lease_set.Decrypt(address, "", nil)
+	assert.Nil(err)
+	assert.Equal([]byte(inner), decrypted)
+}
+
+func TestEncryptedLeaseSetWithOfflineKeysSignedByBlindedKey(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	inner := buildInnerLeaseSet2(t, destination, signer, published)
+	blinded_signer := buildBlindedSigner(t, destination, signer, published, "")
+	offline_signature, _, err := buildOfflineSignature(blinded_signer, published.Add(time.Hour))
+	if !assert.Nil(err) {
+		return
+	}
+
+	encrypted_lease_set, err := NewEncryptedLeaseSet(destination, "", LEASE_SET2_TYPE, inner, published, published.Add(10*time.Minute), 0, offline_signature, nil, blinded_signer)
+	if assert.NotNil(err) {
+		assert.Equal("error building encrypted lease set: signer does not match signing key", err.Error())
+	}
+	assert.Nil(encrypted_lease_set)
+}
+
+func TestEncryptedLeaseSetTruncated(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	inner := buildInnerLeaseSet2(t, destination, signer, published)
+	blinded_signer := buildBlindedSigner(t, destination, signer, published, "")
+	encrypted_lease_set, _ := NewEncryptedLeaseSet(destination, "", LEASE_SET2_TYPE, inner, published, published.Add(10*time.Minute), 0, nil, nil, blinded_signer)
+
+	truncated := encrypted_lease_set[:len(encrypted_lease_set)-10]
+	err := truncated.Verify()
+	if assert.NotNil(err) {
+		assert.Equal("error parsing encrypted lease set: not enough data", err.Error())
+	}
+	_, err = EncryptedLeaseSet(encrypted_lease_set[:20]).Published()
+	assert.NotNil(err)
+}
+
+func TestNewEncryptedLeaseSetUnsupportedInnerType(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	_, err := NewEncryptedLeaseSet(destination, "", 1, []byte{0x00}, published, published.Add(10*time.Minute), 0, nil, nil, signer)
+	if assert.NotNil(err) {
+		assert.Equal("error building encrypted lease set: unsupported inner lease set type", err.Error())
+	}
+}
+
+func TestEncryptedLeaseSetWithInnerMetaLeaseSet(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	header, _ := NewLeaseSet2Header(destination, published, published.Add(10*time.Minute), LEASE_SET2_FLAG_BLINDED, nil)
+	inner, err := NewMetaLeaseSet(header, nil, buildMetaLeases(), nil, signer)
+	if !assert.Nil(err) {
+		return
+	}
+	blinded_signer := buildBlindedSigner(t, destination, signer, published, "")
+	encrypted_lease_set, err := NewEncryptedLeaseSet(destination, "", META_LEASE_SET_TYPE, inner, published, published.Add(10*time.Minute), 0, nil, nil, blinded_signer)
+	if !assert.Nil(err) {
+		return
+	}
+
+	address, _ := destination.BlindedAddress(false, false)
+	inner_type, decrypted, err := encrypted_lease_set.Decrypt(address, "", nil)
+	assert.Nil(err)
+	assert.Equal(META_LEASE_SET_TYPE, inner_type)
+	assert.Nil(MetaLeaseSet(decrypted).Verify())
+}
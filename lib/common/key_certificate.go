@@ -27,6 +27,7 @@ payload :: data
 */
 
 import (
+	"encoding/binary"
 	"errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/hkparker/go-i2p/lib/crypto"
@@ -43,11 +44,15 @@ const (
 	KEYCERT_SIGN_RSA4096
 	KEYCERT_SIGN_ED25519
 	KEYCERT_SIGN_ED25519PH
+	// 9 and 10 are reserved for GOST
+	KEYCERT_SIGN_REDDSA_ED25519 = 11
 )
 
 // Key Certificate Public Key Types
 const (
 	KEYCERT_CRYPTO_ELG = iota
+	// 1 through 3 are reserved for ECIES on the NIST curves
+	KEYCERT_CRYPTO_X25519 = 4
 )
 
 // SigningPublicKey sizes for Signing Key Types
@@ -61,11 +66,13 @@ const (
 	KEYCERT_SIGN_RSA4096_SIZE   = 512
 	KEYCERT_SIGN_ED25519_SIZE   = 32
 	KEYCERT_SIGN_ED25519PH_SIZE = 32
+	KEYCERT_SIGN_REDDSA_SIZE    = 32
 )
 
 // PublicKey sizes for Public Key Types
 const (
-	KEYCERT_CRYPTO_ELG_SIZE = 256
+	KEYCERT_CRYPTO_ELG_SIZE    = 256
+	KEYCERT_CRYPTO_X25519_SIZE = 32
 )
 
 // Sizes of structures in KeyCertificates
@@ -74,6 +81,12 @@ const (
 	KEYCERT_SPK_SIZE    = 128
 )
 
+// Offset of excess key data in a KeyCertificate, following the
+// certificate header and the two key type fields
+const (
+	KEYCERT_EXCESS_OFFSET = CERT_MIN_SIZE + 4
+)
+
 type KeyCertificate []byte
 
 //
@@ -151,12 +164,21 @@ func (key_certificate KeyCertificate) ConstructPublicKey(data []byte) (public_ke
 		err = errors.New("error constructing public key: not enough data")
 		return
 	}
-	switch key_type {
-	case KEYCERT_CRYPTO_ELG:
-		var elg_key crypto.ElgPublicKey
-		copy(elg_key[:], data[KEYCERT_PUBKEY_SIZE-KEYCERT_CRYPTO_ELG_SIZE:KEYCERT_PUBKEY_SIZE])
-		public_key = elg_key
+	encryption_type, err := crypto.EncryptionTypeByCode(key_type)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"at":       "(KeyCertificate) ConstructPublicKey",
+			"key_type": key_type,
+			"reason":   err.Error(),
+		}).Error("error constructing public key")
+		// A type disabled by the policy is reported as crypto.ErrEncryptionTypeDisabled
+		if err == crypto.ErrUnknownEncryptionType {
+			err = errors.New("error constructing public key: unknown public key type")
+		}
+		return
 	}
+	// Public keys are aligned at the start of the public key space
+	public_key, err = encryption_type.NewPublicKey(data[:encryption_type.PublicKeySize])
 	return
 }
 
@@ -165,7 +187,7 @@ func (key_certificate KeyCertificate) ConstructPublicKey(data []byte) (public_ke
 // it along with any errors encountered constructing the SigningPublicKey.
 //
 func (key_certificate KeyCertificate) ConstructSigningPublicKey(data []byte) (signing_public_key crypto.SigningPublicKey, err error) {
-	signing_key_type, err := key_certificate.PublicKeyType()
+	signing_key_type, err := key_certificate.SigningPublicKeyType()
 	if err != nil {
 		return
 	}
@@ -180,58 +202,209 @@ func (key_certificate KeyCertificate) ConstructSigningPublicKey(data []byte) (si
 		err = errors.New("error constructing signing public key: not enough data")
 		return
 	}
-	switch signing_key_type {
-	case KEYCERT_SIGN_DSA_SHA1:
-		var dsa_key crypto.DSAPublicKey
-		copy(dsa_key[:], data[KEYCERT_SPK_SIZE-KEYCERT_SIGN_DSA_SHA1_SIZE:KEYCERT_SPK_SIZE])
-		signing_public_key = dsa_key
-	case KEYCERT_SIGN_P256:
-		var ec_key crypto.ECP256PublicKey
-		copy(ec_key[:], data[KEYCERT_SPK_SIZE-KEYCERT_SIGN_P256_SIZE:KEYCERT_SPK_SIZE])
-		signing_public_key = ec_key
-	case KEYCERT_SIGN_P384:
-		var ec_key crypto.ECP384PublicKey
-		copy(ec_key[:], data[KEYCERT_SPK_SIZE-KEYCERT_SIGN_P384_SIZE:KEYCERT_SPK_SIZE])
-		signing_public_key = ec_key
-	case KEYCERT_SIGN_P521:
-		var ec_key crypto.ECP521PublicKey
-		extra := KEYCERT_SIGN_P521_SIZE - KEYCERT_SPK_SIZE
-		copy(ec_key[:], data)
-		copy(ec_key[KEYCERT_SPK_SIZE:], key_certificate[4:4+extra])
-		signing_public_key = ec_key
-	case KEYCERT_SIGN_RSA2048:
-		//var rsa_key crypto.RSA2048PublicKey
-		//extra := KEYCERT_SIGN_RSA2048_SIZE - 128
-		//copy(rsa_key[:], data)
-		//copy(rsa_key[128:], key_certificate[4:4+extra])
-		//signing_public_key = rsa_key
-	case KEYCERT_SIGN_RSA3072:
-	case KEYCERT_SIGN_RSA4096:
-	case KEYCERT_SIGN_ED25519:
-	case KEYCERT_SIGN_ED25519PH:
+	signature_type, err := crypto.SignatureTypeByCode(signing_key_type)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"at":               "(KeyCertificate) ConstructSigningPublicKey",
+			"signing_key_type": signing_key_type,
+			"reason":           err.Error(),
+		}).Error("error constructing signing public key")
+		// A type disabled by the policy is reported as crypto.ErrSignatureTypeDisabled
+		if err == crypto.ErrUnknownSignatureType {
+			err = errors.New("error constructing signing public key: unknown signing key type")
+		}
+		return
 	}
+	size := signature_type.PublicKeySize
+	var key_data []byte
+	if size > KEYCERT_SPK_SIZE {
+		// Keys larger than the signing key space continue in the excess
+		// data of the Key Certificate
+		extra := size - KEYCERT_SPK_SIZE
+		cert_len := len(key_certificate)
+		if cert_len < KEYCERT_EXCESS_OFFSET+extra {
+			log.WithFields(log.Fields{
+				"at":           "(KeyCertificate) ConstructSigningPublicKey",
+				"data_len":     cert_len,
+				"required_len": KEYCERT_EXCESS_OFFSET + extra,
+				"reason":       "not enough excess key data",
+			}).Error("error constructing signing public key")
+			err = errors.New("error constructing signing public key: not enough excess key data")
+			return
+		}
+		key_data = append(key_data, data[:KEYCERT_SPK_SIZE]...)
+		key_data = append(key_data, key_certificate[KEYCERT_EXCESS_OFFSET:KEYCERT_EXCESS_OFFSET+extra]...)
+	} else {
+		// Smaller keys are aligned at the end of the signing key space
+		key_data = data[KEYCERT_SPK_SIZE-size : KEYCERT_SPK_SIZE]
+	}
+	signing_public_key, err = signature_type.NewPublicKey(key_data)
 	return
 }
 
+//
+// Given the unpadded bytes of a SigningPublicKey of the specified Signing Key Type, as it is stored
+// outside of a KeysAndCert, build the SigningPublicKey and return it along with any errors encountered
+// constructing the SigningPublicKey.
+//
+func ConstructSigningPublicKeyByType(signing_key_type int, data []byte) (signing_public_key crypto.SigningPublicKey, err error) {
+	key_certificate := newKeyCertificate(signing_key_type, KEYCERT_CRYPTO_ELG, nil)
+	size := key_certificate.SigningPublicKeySize()
+	data_len := len(data)
+	if size == 0 {
+		log.WithFields(log.Fields{
+			"at":               "ConstructSigningPublicKeyByType",
+			"signing_key_type": signing_key_type,
+			"reason":           "unknown signing key type",
+		}).Error("error constructing signing public key")
+		if _, err = crypto.SignatureTypeByCode(signing_key_type); err != crypto.ErrSignatureTypeDisabled {
+			err = errors.New("error constructing signing public key: unknown signing key type")
+		}
+		return
+	}
+	if data_len != size {
+		log.WithFields(log.Fields{
+			"at":           "ConstructSigningPublicKeyByType",
+			"data_len":     data_len,
+			"required_len": size,
+			"reason":       "incorrect key size",
+		}).Error("error constructing signing public key")
+		err = errors.New("error constructing signing public key: incorrect key size")
+		return
+	}
+	spk_data := make([]byte, KEYCERT_SPK_SIZE)
+	if size > KEYCERT_SPK_SIZE {
+		// Keys larger than the signing key space keep their excess data in the certificate
+		copy(spk_data, data[:KEYCERT_SPK_SIZE])
+		key_certificate = newKeyCertificate(signing_key_type, KEYCERT_CRYPTO_ELG, data[KEYCERT_SPK_SIZE:])
+	} else {
+		copy(spk_data[KEYCERT_SPK_SIZE-size:], data)
+	}
+	signing_public_key, err = key_certificate.ConstructSigningPublicKey(spk_data)
+	return
+}
+
+//
+// Return the size of a Signature made by a key of the specified Signing Key Type, or 0 if
+// the type is unknown.
+//
+func SignatureSizeByType(signing_key_type int) int {
+	return newKeyCertificate(signing_key_type, KEYCERT_CRYPTO_ELG, nil).SignatureSize()
+}
+
+//
+// Return the size of a SigningPublicKey corresponding to the Key Certificate's
+// SigningPublicKey type.
+//
+func (key_certificate KeyCertificate) SigningPublicKeySize() (size int) {
+	key_type, err := key_certificate.SigningPublicKeyType()
+	if err != nil {
+		return 0
+	}
+	signature_type, err := crypto.SignatureTypeByCode(key_type)
+	if err != nil {
+		return 0
+	}
+	return signature_type.PublicKeySize
+}
+
+//
+// Return the size of a PublicKey corresponding to the Key Certificate's
+// PublicKey type.
+//
+func (key_certificate KeyCertificate) PublicKeySize() (size int) {
+	key_type, err := key_certificate.PublicKeyType()
+	if err != nil {
+		return 0
+	}
+	encryption_type, err := crypto.EncryptionTypeByCode(key_type)
+	if err != nil {
+		return 0
+	}
+	return encryption_type.PublicKeySize
+}
+
 //
 // Return the size of a Signature corresponding to the Key Certificate's
 // SigningPublicKey type.
 //
 func (key_certificate KeyCertificate) SignatureSize() (size int) {
-	sizes := map[int]int{
-		KEYCERT_SIGN_DSA_SHA1:  40,
-		KEYCERT_SIGN_P256:      64,
-		KEYCERT_SIGN_P384:      96,
-		KEYCERT_SIGN_P521:      132,
-		KEYCERT_SIGN_RSA2048:   256,
-		KEYCERT_SIGN_RSA3072:   384,
-		KEYCERT_SIGN_RSA4096:   512,
-		KEYCERT_SIGN_ED25519:   64,
-		KEYCERT_SIGN_ED25519PH: 64,
-	}
 	key_type, err := key_certificate.SigningPublicKeyType()
 	if err != nil {
 		return 0
 	}
-	return sizes[int(key_type)]
+	signature_type, err := crypto.SignatureTypeByCode(key_type)
+	if err != nil {
+		return 0
+	}
+	return signature_type.SignatureSize
+}
+
+//
+// Build a Key Certificate for the provided key types, holding the excess bytes of a signing public
+// key larger than the signing key space of a KeysAndCert.  An error is returned if either key
+// type is unknown or the excess data is not the size the signing key type requires.
+//
+func NewKeyCertificate(signing_key_type, crypto_key_type int, excess []byte) (key_certificate KeyCertificate, err error) {
+	key_certificate = newKeyCertificate(signing_key_type, crypto_key_type, nil)
+	signing_key_size := key_certificate.SigningPublicKeySize()
+	if signing_key_size == 0 || key_certificate.PublicKeySize() == 0 {
+		log.WithFields(log.Fields{
+			"at":               "NewKeyCertificate",
+			"signing_key_type": signing_key_type,
+			"crypto_key_type":  crypto_key_type,
+			"reason":           "unknown key type",
+		}).Error("error building key certificate")
+		err = keyTypePolicyError(signing_key_type, crypto_key_type)
+		if err == nil {
+			err = errors.New("error building key certificate: unknown key type")
+		}
+		key_certificate = nil
+		return
+	}
+	excess_size := 0
+	if signing_key_size > KEYCERT_SPK_SIZE {
+		excess_size = signing_key_size - KEYCERT_SPK_SIZE
+	}
+	if len(excess) != excess_size {
+		log.WithFields(log.Fields{
+			"at":           "NewKeyCertificate",
+			"excess_len":   len(excess),
+			"required_len": excess_size,
+			"reason":       "incorrect excess key data size",
+		}).Error("error building key certificate")
+		err = errors.New("error building key certificate: incorrect excess key data size")
+		key_certificate = nil
+		return
+	}
+	key_certificate = newKeyCertificate(signing_key_type, crypto_key_type, excess)
+	return
+}
+
+//
+// Return the error of the type registry if the policy disables either key type, so that
+// disabled types can be told apart from unknown ones.
+//
+func keyTypePolicyError(signing_key_type, crypto_key_type int) error {
+	if _, err := crypto.SignatureTypeByCode(signing_key_type); err == crypto.ErrSignatureTypeDisabled {
+		return err
+	}
+	if _, err := crypto.EncryptionTypeByCode(crypto_key_type); err == crypto.ErrEncryptionTypeDisabled {
+		return err
+	}
+	return nil
+}
+
+//
+// Assemble the bytes of a Key Certificate for the specified key types, with any excess
+// key data appended to the payload.
+//
+func newKeyCertificate(signing_key_type, crypto_key_type int, excess []byte) KeyCertificate {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint16(payload[:2], uint16(signing_key_type))
+	binary.BigEndian.PutUint16(payload[2:4], uint16(crypto_key_type))
+	payload = append(payload, excess...)
+	key_certificate := []byte{CERT_KEY, 0x00, 0x00}
+	binary.BigEndian.PutUint16(key_certificate[1:CERT_MIN_SIZE], uint16(len(payload)))
+	return KeyCertificate(append(key_certificate, payload...))
 }
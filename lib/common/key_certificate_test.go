@@ -1,6 +1,7 @@
 package common
 
 import (
+	"github.com/hkparker/go-i2p/lib/crypto"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -125,3 +126,193 @@ func TestConstructSigningPublicKeyWithP521(t *testing.T) {
 	assert.Nil(err, "ConstructSigningPublicKey() with P521 returned err on valid data")
 	assert.Equal(spk.Len(), KEYCERT_SIGN_P521_SIZE, "ConstructSigningPublicKey() with P521 returned incorrect SigningPublicKey length")
 }
+
+func TestNewKeyCertificate(t *testing.T) {
+	assert := assert.New(t)
+
+	key_certificate, err := NewKeyCertificate(KEYCERT_SIGN_ED25519, KEYCERT_CRYPTO_X25519, nil)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal(KeyCertificate{0x05, 0x00, 0x04, 0x00, 0x07, 0x00, 0x04}, key_certificate)
+	assert.Nil(Certificate(key_certificate).Validate())
+
+	key_certificate, err = NewKeyCertificate(KEYCERT_SIGN_P521, KEYCERT_CRYPTO_ELG, make([]byte, 4))
+	assert.Nil(err)
+	assert.Equal(KEYCERT_EXCESS_OFFSET+4, len(key_certificate))
+}
+
+func TestNewKeyCertificateRejectsBadInput(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewKeyCertificate(9, KEYCERT_CRYPTO_ELG, nil)
+	if assert.NotNil(err) {
+		assert.Equal("error building key certificate: unknown key type", err.Error())
+	}
+	_, err = NewKeyCertificate(KEYCERT_SIGN_ED25519, 3, nil)
+	assert.NotNil(err)
+	_, err = NewKeyCertificate(KEYCERT_SIGN_P521, KEYCERT_CRYPTO_ELG, nil)
+	if assert.NotNil(err) {
+		assert.Equal("error building key certificate: incorrect excess key data size", err.Error())
+	}
+}
+
+func TestConstructPublicKeyWithX25519(t *testing.T) {
+	assert := assert.New(t)
+
+	key_certificate, _ := NewKeyCertificate(KEYCERT_SIGN_ED25519, KEYCERT_CRYPTO_X25519, nil)
+	data := make([]byte, KEYCERT_PUBKEY_SIZE)
+	data[0] = 0x01
+	data[KEYCERT_CRYPTO_X25519_SIZE] = 0xff
+	pk, err := key_certificate.ConstructPublicKey(data)
+	assert.Nil(err)
+	assert.Equal(crypto.X25519PublicKey{0x01}, pk)
+}
+
+func TestConstructPublicKeyWithUnknownType(t *testing.T) {
+	assert := assert.New(t)
+
+	key_certificate := KeyCertificate([]byte{0x05, 0x00, 0x04, 0x00, 0x07, 0x00, 0x03})
+	_, err := key_certificate.ConstructPublicKey(make([]byte, KEYCERT_PUBKEY_SIZE))
+	if assert.NotNil(err) {
+		assert.Equal("error constructing public key: unknown public key type", err.Error())
+	}
+}
+
+func TestConstructSigningPublicKeyWithRSA4096(t *testing.T) {
+	assert := assert.New(t)
+
+	excess := make([]byte, KEYCERT_SIGN_RSA4096_SIZE-KEYCERT_SPK_SIZE)
+	excess[len(excess)-1] = 0x02
+	key_certificate, err := NewKeyCertificate(KEYCERT_SIGN_RSA4096, KEYCERT_CRYPTO_ELG, excess)
+	if !assert.Nil(err) {
+		return
+	}
+	data := make([]byte, KEYCERT_SPK_SIZE)
+	data[0] = 0x01
+	spk, err := key_certificate.ConstructSigningPublicKey(data)
+	assert.Nil(err)
+	rsa_key, ok := spk.(crypto.RSA4096PublicKey)
+	if assert.True(ok) {
+		assert.Equal(byte(0x01), rsa_key[0])
+		assert.Equal(byte(0x02), rsa_key[KEYCERT_SIGN_RSA4096_SIZE-1])
+	}
+
+	_, err = KeyCertificate(key_certificate[:len(key_certificate)-1]).ConstructSigningPublicKey(data)
+	assert.NotNil(err)
+}
+
+func TestConstructSigningPublicKeyWithEd25519Variants(t *testing.T) {
+	assert := assert.New(t)
+
+	data := make([]byte, KEYCERT_SPK_SIZE)
+	data[KEYCERT_SPK_SIZE-1] = 0x01
+	key_certificate, _ := NewKeyCertificate(KEYCERT_SIGN_ED25519PH, KEYCERT_CRYPTO_ELG, nil)
+	spk, err := key_certificate.ConstructSigningPublicKey(data)
+	assert.Nil(err)
+	assert.Equal(crypto.Ed25519phPublicKey{31: 0x01}, spk)
+
+	key_certificate, _ = NewKeyCertificate(KEYCERT_SIGN_REDDSA_ED25519, KEYCERT_CRYPTO_X25519, nil)
+	spk, err = key_certificate.ConstructSigningPublicKey(data)
+	assert.Nil(err)
+	assert.Equal(crypto.RedDSAPublicKey{31: 0x01}, spk)
+}
+
+func TestConstructSigningPublicKeyWithUnknownType(t *testing.T) {
+	assert := assert.New(t)
+
+	key_certificate := KeyCertificate([]byte{0x05, 0x00, 0x04, 0x00, 0x09, 0x00, 0x00})
+	_, err := key_certificate.ConstructSigningPublicKey(make([]byte, KEYCERT_SPK_SIZE))
+	if assert.NotNil(err) {
+		assert.Equal("error constructing signing public key: unknown signing key type", err.Error())
+	}
+}
+
+func TestKeyCertificateSizesMatchRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	sizes := map[int]int{
+		KEYCERT_SIGN_DSA_SHA1:       KEYCERT_SIGN_DSA_SHA1_SIZE,
+		KEYCERT_SIGN_P256:           KEYCERT_SIGN_P256_SIZE,
+		KEYCERT_SIGN_P384:           KEYCERT_SIGN_P384_SIZE,
+		KEYCERT_SIGN_P521:           KEYCERT_SIGN_P521_SIZE,
+		KEYCERT_SIGN_RSA2048:        KEYCERT_SIGN_RSA2048_SIZE,
+		KEYCERT_SIGN_RSA3072:        KEYCERT_SIGN_RSA3072_SIZE,
+		KEYCERT_SIGN_RSA4096:        KEYCERT_SIGN_RSA4096_SIZE,
+		KEYCERT_SIGN_ED25519:        KEYCERT_SIGN_ED25519_SIZE,
+		KEYCERT_SIGN_ED25519PH:      KEYCERT_SIGN_ED25519PH_SIZE,
+		KEYCERT_SIGN_REDDSA_ED25519: KEYCERT_SIGN_REDDSA_SIZE,
+	}
+	for signing_key_type, size := range sizes {
+		assert.Equal(size, newKeyCertificate(signing_key_type, KEYCERT_CRYPTO_ELG, nil).SigningPublicKeySize())
+	}
+	assert.Equal(40, SignatureSizeByType(KEYCERT_SIGN_DSA_SHA1))
+	assert.Equal(KEYCERT_CRYPTO_X25519_SIZE, newKeyCertificate(KEYCERT_SIGN_ED25519, KEYCERT_CRYPTO_X25519, nil).PublicKeySize())
+}
+
+func TestConstructSigningPublicKeyRejectsDisabledType(t *testing.T) {
+	assert := assert.New(t)
+
+	crypto.SetSignaturePolicy(crypto.RejectDeprecated)
+	defer crypto.SetSignaturePolicy(nil)
+
+	key_certificate := newKeyCertificate(KEYCERT_SIGN_DSA_SHA1, KEYCERT_CRYPTO_ELG, nil)
+	_, err := key_certificate.ConstructSigningPublicKey(make([]byte, KEYCERT_SPK_SIZE))
+	assert.Equal(crypto.ErrSignatureTypeDisabled, err)
+	assert.Equal(0, key_certificate.SigningPublicKeySize())
+	_, err = NewKeyCertificate(KEYCERT_SIGN_DSA_SHA1, KEYCERT_CRYPTO_ELG, nil)
+	assert.Equal(crypto.ErrSignatureTypeDisabled, err)
+	_, err = ConstructSigningPublicKeyByType(KEYCERT_SIGN_DSA_SHA1, make([]byte, KEYCERT_SIGN_DSA_SHA1_SIZE))
+	assert.Equal(crypto.ErrSignatureTypeDisabled, err)
+
+	key_certificate = newKeyCertificate(9, KEYCERT_CRYPTO_ELG, nil)
+	_, err = key_certificate.ConstructSigningPublicKey(make([]byte, KEYCERT_SPK_SIZE))
+	if assert.NotNil(err) {
+		assert.Equal("error constructing signing public key: unknown signing key type", err.Error())
+	}
+	_, err = NewKeyCertificate(9, KEYCERT_CRYPTO_ELG, nil)
+	if assert.NotNil(err) {
+		assert.Equal("error building key certificate: unknown key type", err.Error())
+	}
+
+	key_certificate = newKeyCertificate(KEYCERT_SIGN_ED25519, KEYCERT_CRYPTO_ELG, nil)
+	_, err = key_certificate.ConstructSigningPublicKey(make([]byte, KEYCERT_SPK_SIZE))
+	assert.Nil(err)
+}
+
+func TestConstructSigningPublicKeyWithRegisteredType(t *testing.T) {
+	assert := assert.New(t)
+
+	// a type in the experimental range, parsed without changes to KeyCertificate
+	experimental, _ := crypto.SignatureTypeByCode(KEYCERT_SIGN_ED25519)
+	experimental.Code = 65280
+	experimental.Name = "Experimental_Ed25519"
+	crypto.RegisterSignatureType(experimental)
+	t.Cleanup(func() { crypto.UnregisterSignatureType(65280) })
+
+	key_certificate, err := NewKeyCertificate(65280, KEYCERT_CRYPTO_X25519, nil)
+	if !assert.Nil(err) {
+		return
+	}
+	data := make([]byte, KEYCERT_SPK_SIZE)
+	data[KEYCERT_SPK_SIZE-1] = 0x01
+	spk, err := key_certificate.ConstructSigningPublicKey(data)
+	assert.Nil(err)
+	assert.Equal(crypto.Ed25519PublicKey{31: 0x01}, spk)
+	assert.Equal(64, key_certificate.SignatureSize())
+}
+
+func TestConstructPublicKeyRejectsDisabledType(t *testing.T) {
+	assert := assert.New(t)
+
+	crypto.SetEncryptionPolicy(func(encryption_type crypto.EncryptionType) bool {
+		return encryption_type.Code != KEYCERT_CRYPTO_ELG
+	})
+	t.Cleanup(func() { crypto.SetEncryptionPolicy(nil) })
+
+	key_certificate := newKeyCertificate(KEYCERT_SIGN_ED25519, KEYCERT_CRYPTO_ELG, nil)
+	_, err := key_certificate.ConstructPublicKey(make([]byte, KEYCERT_PUBKEY_SIZE))
+	assert.Equal(crypto.ErrEncryptionTypeDisabled, err)
+	_, err = NewKeyCertificate(KEYCERT_SIGN_ED25519, KEYCERT_CRYPTO_ELG, nil)
+	assert.Equal(crypto.ErrEncryptionTypeDisabled, err)
+}
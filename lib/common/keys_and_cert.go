@@ -46,9 +46,11 @@ total length: 387+ bytes
 */
 
 import (
+	"crypto/rand"
 	"errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/hkparker/go-i2p/lib/crypto"
+	"io"
 )
 
 // Sizes of various KeysAndCert structures and requirements
@@ -77,9 +79,7 @@ func (keys_and_cert KeysAndCert) PublicKey() (key crypto.PublicKey, err error) {
 	if cert_len == 0 {
 		// No Certificate is present, return the KEYS_AND_CERT_PUBKEY_SIZE byte
 		// PublicKey space as ElgPublicKey.
-		var elg_key crypto.ElgPublicKey
-		copy(keys_and_cert[:KEYS_AND_CERT_PUBKEY_SIZE], elg_key[:])
-		key = elg_key
+		key, err = legacyPublicKey(keys_and_cert[:KEYS_AND_CERT_PUBKEY_SIZE])
 	} else {
 		// A Certificate is present in this KeysAndCert
 		cert_type, _ := cert.Type()
@@ -90,13 +90,13 @@ func (keys_and_cert KeysAndCert) PublicKey() (key crypto.PublicKey, err error) {
 			key, err = KeyCertificate(cert).ConstructPublicKey(
 				keys_and_cert[:KEYS_AND_CERT_PUBKEY_SIZE],
 			)
+		} else if cert_type > CERT_KEY {
+			err = unsupportedCertificateType("(KeysAndCert) PublicKey", cert_type)
 		} else {
 			// Key Certificate is not present, return the KEYS_AND_CERT_PUBKEY_SIZE byte
-			// PublicKey space as ElgPublicKey.  No other Certificate
-			// types are currently in use.
-			var elg_key crypto.ElgPublicKey
-			copy(keys_and_cert[:KEYS_AND_CERT_PUBKEY_SIZE], elg_key[:])
-			key = elg_key
+			// PublicKey space as ElgPublicKey.  The other Certificate types
+			// are only used by legacy Destinations.
+			key, err = legacyPublicKey(keys_and_cert[:KEYS_AND_CERT_PUBKEY_SIZE])
 			log.WithFields(log.Fields{
 				"at":        "(KeysAndCert) PublicKey",
 				"cert_type": cert_type,
@@ -123,9 +123,9 @@ func (keys_and_cert KeysAndCert) SigningPublicKey() (signing_public_key crypto.S
 	if cert_len == 0 {
 		// No Certificate is present, return the KEYS_AND_CERT_SPK_SIZE byte
 		// SigningPublicKey space as legacy DSA SHA1 SigningPublicKey.
-		var dsa_pk crypto.DSAPublicKey
-		copy(dsa_pk[:], keys_and_cert[KEYS_AND_CERT_PUBKEY_SIZE:KEYS_AND_CERT_PUBKEY_SIZE+KEYS_AND_CERT_SPK_SIZE])
-		signing_public_key = dsa_pk
+		signing_public_key, err = legacySigningPublicKey(
+			keys_and_cert[KEYS_AND_CERT_PUBKEY_SIZE : KEYS_AND_CERT_PUBKEY_SIZE+KEYS_AND_CERT_SPK_SIZE],
+		)
 	} else {
 		// A Certificate is present in this KeysAndCert
 		cert_type, _ := cert.Type()
@@ -136,19 +136,144 @@ func (keys_and_cert KeysAndCert) SigningPublicKey() (signing_public_key crypto.S
 			signing_public_key, err = KeyCertificate(cert).ConstructSigningPublicKey(
 				keys_and_cert[KEYS_AND_CERT_PUBKEY_SIZE : KEYS_AND_CERT_PUBKEY_SIZE+KEYS_AND_CERT_SPK_SIZE],
 			)
+		} else if cert_type > CERT_KEY {
+			err = unsupportedCertificateType("(KeysAndCert) SigningPublicKey", cert_type)
 		} else {
 			// Key Certificate is not present, return the KEYS_AND_CERT_SPK_SIZE byte
 			// SigningPublicKey space as legacy SHA DSA1 SigningPublicKey.
-			// No other Certificate types are currently in use.
-			var dsa_pk crypto.DSAPublicKey
-			copy(dsa_pk[:], keys_and_cert[KEYS_AND_CERT_PUBKEY_SIZE:KEYS_AND_CERT_PUBKEY_SIZE+KEYS_AND_CERT_SPK_SIZE])
-			signing_public_key = dsa_pk
+			// The other Certificate types are only used by legacy Destinations.
+			signing_public_key, err = legacySigningPublicKey(
+				keys_and_cert[KEYS_AND_CERT_PUBKEY_SIZE : KEYS_AND_CERT_PUBKEY_SIZE+KEYS_AND_CERT_SPK_SIZE],
+			)
 		}
 
 	}
 	return
 }
 
+//
+// Build the ElGamal PublicKey used by KeysAndCerts without a Key Certificate, through the
+// type registry so the policy for encryption types applies to legacy keys as well.
+//
+func legacyPublicKey(data []byte) (key crypto.PublicKey, err error) {
+	encryption_type, err := crypto.EncryptionTypeByCode(KEYCERT_CRYPTO_ELG)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"at":     "legacyPublicKey",
+			"reason": err.Error(),
+		}).Error("error constructing public key")
+		return
+	}
+	key, err = encryption_type.NewPublicKey(data)
+	return
+}
+
+//
+// Build the DSA SHA1 SigningPublicKey used by KeysAndCerts without a Key Certificate, through
+// the type registry so the policy for signature types applies to legacy keys as well.
+//
+func legacySigningPublicKey(data []byte) (signing_public_key crypto.SigningPublicKey, err error) {
+	signature_type, err := crypto.SignatureTypeByCode(KEYCERT_SIGN_DSA_SHA1)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"at":     "legacySigningPublicKey",
+			"reason": err.Error(),
+		}).Error("error constructing signing public key")
+		return
+	}
+	signing_public_key, err = signature_type.NewPublicKey(data)
+	return
+}
+
+//
+// Return the Signing Key Type of this KeysAndCert, as specified in the Key Certificate
+// if present or the legacy DSA SHA1 type.
+//
+func (keys_and_cert KeysAndCert) signingPublicKeyType() (signing_key_type int, err error) {
+	cert, err := keys_and_cert.Certificate()
+	if err != nil {
+		return
+	}
+	signing_key_type = KEYCERT_SIGN_DSA_SHA1
+	if cert_type, _ := cert.Type(); cert_type > CERT_KEY {
+		err = unsupportedCertificateType("(KeysAndCert) signingPublicKeyType", cert_type)
+	} else if cert_type == CERT_KEY {
+		signing_key_type, err = KeyCertificate(cert).SigningPublicKeyType()
+	}
+	return
+}
+
+//
+// Return the Public Key Type of this KeysAndCert, as specified in the Key Certificate
+// if present or the legacy ElGamal type.
+//
+func (keys_and_cert KeysAndCert) publicKeyType() (crypto_key_type int, err error) {
+	cert, err := keys_and_cert.Certificate()
+	if err != nil {
+		return
+	}
+	crypto_key_type = KEYCERT_CRYPTO_ELG
+	if cert_type, _ := cert.Type(); cert_type > CERT_KEY {
+		err = unsupportedCertificateType("(KeysAndCert) publicKeyType", cert_type)
+	} else if cert_type == CERT_KEY {
+		crypto_key_type, err = KeyCertificate(cert).PublicKeyType()
+	}
+	return
+}
+
+//
+// Lay out a KeysAndCert from the unpadded bytes of a PublicKey and SigningPublicKey of the
+// provided types, filling the unused key space with random padding.  A Key Certificate is
+// used unless both keys are of the legacy types, and the excess bytes of signing keys larger
+// than the signing key space are moved into it.
+//
+func NewKeysAndCert(crypto_key_type int, public_key []byte, signing_key_type int, signing_public_key []byte) (keys_and_cert KeysAndCert, err error) {
+	key_certificate := newKeyCertificate(signing_key_type, crypto_key_type, nil)
+	public_key_size := key_certificate.PublicKeySize()
+	signing_key_size := key_certificate.SigningPublicKeySize()
+	if public_key_size == 0 || len(public_key) != public_key_size {
+		log.WithFields(log.Fields{
+			"at":              "NewKeysAndCert",
+			"crypto_key_type": crypto_key_type,
+			"key_len":         len(public_key),
+			"reason":          "public key does not match key type",
+		}).Error("error building keys and cert")
+		err = errors.New("error building keys and cert: public key does not match key type")
+		return
+	}
+	if signing_key_size == 0 || len(signing_public_key) != signing_key_size {
+		log.WithFields(log.Fields{
+			"at":               "NewKeysAndCert",
+			"signing_key_type": signing_key_type,
+			"key_len":          len(signing_public_key),
+			"reason":           "signing public key does not match key type",
+		}).Error("error building keys and cert")
+		err = errors.New("error building keys and cert: signing public key does not match key type")
+		return
+	}
+	data := make([]byte, KEYS_AND_CERT_DATA_SIZE)
+	_, err = io.ReadFull(rand.Reader, data)
+	if err != nil {
+		return
+	}
+	// the public key is aligned at the start of its space and the signing key at the end
+	copy(data, public_key)
+	var excess []byte
+	if signing_key_size > KEYS_AND_CERT_SPK_SIZE {
+		copy(data[KEYS_AND_CERT_PUBKEY_SIZE:], signing_public_key[:KEYS_AND_CERT_SPK_SIZE])
+		excess = signing_public_key[KEYS_AND_CERT_SPK_SIZE:]
+	} else {
+		copy(data[KEYS_AND_CERT_DATA_SIZE-signing_key_size:], signing_public_key)
+	}
+	if crypto_key_type == KEYCERT_CRYPTO_ELG && signing_key_type == KEYCERT_SIGN_DSA_SHA1 {
+		keys_and_cert = KeysAndCert(append(data, CERT_NULL, 0x00, 0x00))
+		return
+	}
+	key_certificate = newKeyCertificate(signing_key_type, crypto_key_type, excess)
+	keys_and_cert = KeysAndCert(append(data, key_certificate...))
+	return
+}
+
 //
 // Return the Certificate contained in the KeysAndCert and any errors encountered while parsing the
 // KeysAndCert or Certificate.
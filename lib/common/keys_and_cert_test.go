@@ -1,8 +1,10 @@
 package common
 
 import (
+	"github.com/hkparker/go-i2p/lib/crypto"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestCertificateWithMissingData(t *testing.T) {
@@ -164,7 +166,7 @@ func TestSigningPublicKeyWithKeyCertificate(t *testing.T) {
 
 	signing_pub_key, err := keys_and_cert.SigningPublicKey()
 	assert.Nil(err)
-	assert.Equal(len(signing_pub_key_data), signing_pub_key.Len())
+	assert.Equal(KEYCERT_SIGN_P256_SIZE, signing_pub_key.Len())
 }
 
 func TestReadKeysAndCertWithMissingData(t *testing.T) {
@@ -287,3 +289,162 @@ func TestReadKeysAndCertWithValidDataWithoutCertificateAndRemainder(t *testing.T
 	_, err = keys_and_cert.Certificate()
 	assert.Nil(err, "keys_and_cert.Certificate() returned error with valid data not containing certificate")
 }
+
+func TestNewKeysAndCertMovesExcessSigningKeyIntoCertificate(t *testing.T) {
+	assert := assert.New(t)
+
+	signing_public_key := make([]byte, KEYCERT_SIGN_P521_SIZE)
+	for i := range signing_public_key {
+		signing_public_key[i] = byte(i)
+	}
+	keys_and_cert, err := NewKeysAndCert(KEYCERT_CRYPTO_ELG, make([]byte, 256), KEYCERT_SIGN_P521, signing_public_key)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal(KEYS_AND_CERT_MIN_SIZE+4+4, len(keys_and_cert))
+	assert.Equal(signing_public_key[:128], []byte(keys_and_cert[256:384]))
+	parsed_key, err := keys_and_cert.SigningPublicKey()
+	assert.Nil(err)
+	assert.Equal(signing_public_key, parsed_key.Bytes())
+}
+
+func TestNewKeysAndCertAlignsSmallKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	public_key := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10,
+		0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f, 0x20}
+	keys_and_cert, err := NewKeysAndCert(KEYCERT_CRYPTO_X25519, public_key, KEYCERT_SIGN_ED25519, public_key)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal(public_key, []byte(keys_and_cert[:32]))
+	assert.Equal(public_key, []byte(keys_and_cert[384-32:384]))
+	crypto_key_type, _ := keys_and_cert.publicKeyType()
+	assert.Equal(KEYCERT_CRYPTO_X25519, crypto_key_type)
+}
+
+func TestNewKeysAndCertWithWrongKeySize(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewKeysAndCert(KEYCERT_CRYPTO_X25519, make([]byte, 256), KEYCERT_SIGN_ED25519, make([]byte, 32))
+	if assert.NotNil(err) {
+		assert.Equal("error building keys and cert: public key does not match key type", err.Error())
+	}
+	_, err = NewKeysAndCert(KEYCERT_CRYPTO_ELG, make([]byte, 256), KEYCERT_SIGN_ED25519, make([]byte, 64))
+	if assert.NotNil(err) {
+		assert.Equal("error building keys and cert: signing public key does not match key type", err.Error())
+	}
+}
+
+func TestKeysAndCertWithLegacyCertificateUsesLegacyKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	data := make([]byte, KEYS_AND_CERT_DATA_SIZE)
+	data[0] = 0x01
+	data[KEYS_AND_CERT_PUBKEY_SIZE] = 0x02
+	hashcash, _ := NewCertificate(CERT_HASHCASH, []byte("stamp"))
+	keys_and_cert, remainder, err := ReadKeysAndCert(append(append(data, hashcash...), 0x03))
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal([]byte{0x03}, remainder)
+
+	pub_key, err := keys_and_cert.PublicKey()
+	assert.Nil(err)
+	assert.Equal(crypto.ElgPublicKey{0x01}, pub_key)
+	signing_pub_key, err := keys_and_cert.SigningPublicKey()
+	assert.Nil(err)
+	assert.Equal(crypto.DSAPublicKey{0x02}, signing_pub_key)
+	assert.Equal(byte(0x01), keys_and_cert[0], "reading the public key must not modify the KeysAndCert")
+}
+
+func TestKeysAndCertWithUnknownCertificateType(t *testing.T) {
+	assert := assert.New(t)
+
+	data := make([]byte, KEYS_AND_CERT_DATA_SIZE)
+	keys_and_cert := KeysAndCert(append(data, 0x07, 0x00, 0x01, 0x00))
+
+	_, err := keys_and_cert.PublicKey()
+	if assert.NotNil(err) {
+		assert.Equal("error parsing certificate: unsupported certificate type", err.Error())
+	}
+	_, err = keys_and_cert.SigningPublicKey()
+	if assert.NotNil(err) {
+		assert.Equal("error parsing certificate: unsupported certificate type", err.Error())
+	}
+	_, err = keys_and_cert.signingPublicKeyType()
+	assert.NotNil(err)
+}
+
+func TestNewKeysAndCertWithRSAKeyRoundTrips(t *testing.T) {
+	assert := assert.New(t)
+
+	public_key := make([]byte, KEYCERT_CRYPTO_X25519_SIZE)
+	public_key[0] = 0x01
+	signing_public_key := make([]byte, KEYCERT_SIGN_RSA3072_SIZE)
+	for i := range signing_public_key {
+		signing_public_key[i] = byte(i)
+	}
+	keys_and_cert, err := NewKeysAndCert(KEYCERT_CRYPTO_X25519, public_key, KEYCERT_SIGN_RSA3072, signing_public_key)
+	if !assert.Nil(err) {
+		return
+	}
+	read_keys_and_cert, remainder, err := ReadKeysAndCert(keys_and_cert)
+	assert.Nil(err)
+	assert.Equal(0, len(remainder))
+	assert.Equal(keys_and_cert, read_keys_and_cert)
+
+	parsed_public_key, err := read_keys_and_cert.PublicKey()
+	assert.Nil(err)
+	assert.Equal(public_key, parsed_public_key.Bytes())
+	parsed_signing_key, err := read_keys_and_cert.SigningPublicKey()
+	assert.Nil(err)
+	assert.Equal(signing_public_key, parsed_signing_key.Bytes())
+}
+
+func TestNullCertificateKeysFollowTypePolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, sk, err := buildDSADestination()
+	if !assert.Nil(err) {
+		return
+	}
+	signer, err := sk.NewSigner()
+	if !assert.Nil(err) {
+		return
+	}
+	var published Date
+	router_info, err := NewRouterInfo(RouterIdentity(destination), published, nil, buildMapping(), signer)
+	if !assert.Nil(err) {
+		return
+	}
+	var encryption_key crypto.ElgPublicKey
+	var signing_key crypto.DSAPublicKey
+	leases := []Lease{NewLease(Hash{0x01}, 1, time.Unix(1500000000, 0))}
+	lease_set, err := NewLeaseSet(destination, encryption_key, signing_key, leases, signer)
+	if !assert.Nil(err) {
+		return
+	}
+
+	crypto.SetSignaturePolicy(crypto.RejectDeprecated)
+	t.Cleanup(func() { crypto.SetSignaturePolicy(nil) })
+	_, err = KeysAndCert(destination).SigningPublicKey()
+	assert.Equal(crypto.ErrSignatureTypeDisabled, err)
+	assert.NotNil(router_info.Verify())
+	assert.NotNil(lease_set.Verify())
+	_, err = lease_set.SigningKey()
+	assert.Equal(crypto.ErrSignatureTypeDisabled, err)
+	_, err = PrivateKeyFile(append(destination, make([]byte, 256+20)...)).NewSigner()
+	assert.Equal(crypto.ErrSignatureTypeDisabled, err)
+	_, err = GeneratePrivateKeyFile(KEYCERT_CRYPTO_ELG, KEYCERT_SIGN_DSA_SHA1)
+	assert.Equal(crypto.ErrSignatureTypeDisabled, err)
+
+	crypto.SetEncryptionPolicy(func(encryption_type crypto.EncryptionType) bool {
+		return encryption_type.Code != KEYCERT_CRYPTO_ELG
+	})
+	t.Cleanup(func() { crypto.SetEncryptionPolicy(nil) })
+	_, err = KeysAndCert(destination).PublicKey()
+	assert.Equal(crypto.ErrEncryptionTypeDisabled, err)
+	_, err = GeneratePrivateKeyFile(KEYCERT_CRYPTO_ELG, KEYCERT_SIGN_ED25519)
+	assert.Equal(crypto.ErrEncryptionTypeDisabled, err)
+}
@@ -29,6 +29,11 @@ end_date :: Date
             length -> 8 bytes
 */
 
+import (
+	"encoding/binary"
+	"time"
+)
+
 // Sizes or various components of a Lease
 const (
 	LEASE_SIZE           = 44
@@ -38,6 +43,18 @@ const (
 
 type Lease [LEASE_SIZE]byte
 
+//
+// Create a Lease from the Hash of the tunnel gateway's RouterIdentity, the TunnelID
+// at the gateway and the time the Lease expires.
+//
+func NewLease(tunnel_gateway Hash, tunnel_id uint32, expiration time.Time) (lease Lease) {
+	copy(lease[:LEASE_HASH_SIZE], tunnel_gateway[:])
+	binary.BigEndian.PutUint32(lease[LEASE_HASH_SIZE:LEASE_HASH_SIZE+LEASE_TUNNEL_ID_SIZE], tunnel_id)
+	date := DateFromTime(expiration)
+	copy(lease[LEASE_HASH_SIZE+LEASE_TUNNEL_ID_SIZE:], date[:])
+	return
+}
+
 //
 // Return the first 32 bytes of the Lease as a Hash.
 //
@@ -0,0 +1,77 @@
+package common
+
+/*
+I2P Lease2
+https://geti2p.net/spec/common-structures#lease2
+Accurate for version 0.9.38
+
++----+----+----+----+----+----+----+----+
+| tunnel_gw                             |
++                                       +
+|                                       |
++                                       +
+|                                       |
++                                       +
+|                                       |
++----+----+----+----+----+----+----+----+
+|     tunnel_id     |      end_date     |
++----+----+----+----+----+----+----+----+
+
+tunnel_gw :: Hash of the RouterIdentity of the tunnel gateway
+             length -> 32 bytes
+
+tunnel_id :: TunnelId
+             length -> 4 bytes
+
+end_date :: 4 byte date
+            length -> 4 bytes
+            Seconds since the epoch, rolls over in 2106.
+*/
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Sizes or various components of a Lease2
+const (
+	LEASE2_SIZE          = 40
+	LEASE2_END_DATE_SIZE = 4
+)
+
+type Lease2 [LEASE2_SIZE]byte
+
+//
+// Create a Lease2 from the Hash of the tunnel gateway's RouterIdentity, the TunnelID
+// at the gateway and the time the Lease2 expires, truncated to seconds.
+//
+func NewLease2(tunnel_gateway Hash, tunnel_id uint32, expiration time.Time) (lease Lease2) {
+	copy(lease[:LEASE_HASH_SIZE], tunnel_gateway[:])
+	binary.BigEndian.PutUint32(lease[LEASE_HASH_SIZE:LEASE_HASH_SIZE+LEASE_TUNNEL_ID_SIZE], tunnel_id)
+	binary.BigEndian.PutUint32(lease[LEASE_HASH_SIZE+LEASE_TUNNEL_ID_SIZE:], uint32(expiration.Unix()))
+	return
+}
+
+//
+// Return the first 32 bytes of the Lease2 as a Hash.
+//
+func (lease Lease2) TunnelGateway() (hash Hash) {
+	copy(hash[:], lease[:LEASE_HASH_SIZE])
+	return
+}
+
+//
+// Parse the TunnelID Integer in the Lease2.
+//
+func (lease Lease2) TunnelID() uint32 {
+	return uint32(
+		Integer(lease[LEASE_HASH_SIZE : LEASE_HASH_SIZE+LEASE_TUNNEL_ID_SIZE]),
+	)
+}
+
+//
+// Return the end date of the Lease2 as a Date.
+//
+func (lease Lease2) Date() Date {
+	return dateFromSeconds(lease[LEASE_HASH_SIZE+LEASE_TUNNEL_ID_SIZE:])
+}
@@ -93,8 +93,85 @@ const (
 	LEASE_SET_SIG_SIZE    = 40
 )
 
+// Maximum number of Leases in a LeaseSet
+const (
+	LEASE_SET_MAX_LEASES = 16
+)
+
 type LeaseSet []byte
 
+//
+// Build a LeaseSet from a Destination, the ElGamal PublicKey used to encrypt to it, a
+// SigningPublicKey of the Destination's signing type and up to 16 Leases, signed with the
+// provided Signer, which must belong to the Destination's SigningPublicKey.
+//
+func NewLeaseSet(destination Destination, encryption_key crypto.ElgPublicKey, signing_key crypto.SigningPublicKey, leases []Lease, signer crypto.Signer) (lease_set LeaseSet, err error) {
+	destination, _, err = ReadDestination(destination)
+	if err != nil {
+		return
+	}
+	lease_count := len(leases)
+	if lease_count > LEASE_SET_MAX_LEASES {
+		log.WithFields(log.Fields{
+			"at":          "NewLeaseSet",
+			"lease_count": lease_count,
+			"reason":      "more than 16 leases",
+		}).Error("error building lease set")
+		err = errors.New("error building lease set: more than 16 leases")
+		return
+	}
+	data := make([]byte, 0)
+	data = append(data, destination...)
+	data = append(data, encryption_key[:]...)
+	spk_size, err := LeaseSet(data).signingKeySize()
+	if err != nil {
+		return
+	}
+	if signing_key == nil || signing_key.Len() != spk_size {
+		log.WithFields(log.Fields{
+			"at":           "NewLeaseSet",
+			"required_len": spk_size,
+			"reason":       "signing key does not match destination",
+		}).Error("error building lease set")
+		err = errors.New("error building lease set: signing key does not match destination")
+		return
+	}
+	data = append(data, signing_key.Bytes()...)
+	data = append(data, byte(lease_count))
+	for _, lease := range leases {
+		data = append(data, lease[:]...)
+	}
+	signature, err := signer.Sign(data)
+	if err != nil {
+		return
+	}
+	sig_size := LEASE_SET_SIG_SIZE
+	cert, _ := destination.Certificate()
+	if cert_type, _ := cert.Type(); cert_type == CERT_KEY {
+		sig_size = KeyCertificate(cert).SignatureSize()
+	}
+	if len(signature) != sig_size {
+		log.WithFields(log.Fields{
+			"at":           "NewLeaseSet",
+			"sig_len":      len(signature),
+			"required_len": sig_size,
+			"reason":       "signature size does not match destination",
+		}).Error("error building lease set")
+		err = errors.New("error building lease set: signature size does not match destination")
+		return
+	}
+	lease_set = LeaseSet(append(data, signature...))
+	if verify_err := lease_set.Verify(); verify_err != nil {
+		log.WithFields(log.Fields{
+			"at":     "NewLeaseSet",
+			"reason": "signer does not match destination",
+		}).Error("error building lease set")
+		err = errors.New("error building lease set: signer does not match destination")
+		lease_set = nil
+	}
+	return
+}
+
 //
 // Read a Destination from the LeaseSet.
 //
@@ -143,12 +220,16 @@ func (lease_set LeaseSet) SigningKey() (signing_public_key crypto.SigningPublicK
 	if err != nil {
 		return
 	}
+	spk_size, err := lease_set.signingKeySize()
+	if err != nil {
+		return
+	}
 	lease_set_len := len(lease_set)
-	if lease_set_len < offset+LEASE_SET_SPK_SIZE {
+	if lease_set_len < offset+spk_size {
 		log.WithFields(log.Fields{
 			"at":           "(LeaseSet) SigningKey",
 			"data_len":     lease_set_len,
-			"required_len": offset + LEASE_SET_SPK_SIZE,
+			"required_len": offset + spk_size,
 			"reason":       "not enough data",
 		}).Error("error parsing signing public key")
 		err = errors.New("error parsing signing public key: not enough data")
@@ -157,25 +238,22 @@ func (lease_set LeaseSet) SigningKey() (signing_public_key crypto.SigningPublicK
 	if cert_len == 0 {
 		// No Certificate is present, return the LEASE_SET_SPK_SIZE byte
 		// SigningPublicKey space as legacy DSA SHA1 SigningPublicKey.
-		var dsa_pk crypto.DSAPublicKey
-		copy(dsa_pk[:], lease_set[offset:offset+LEASE_SET_SPK_SIZE])
-		signing_public_key = dsa_pk
+		signing_public_key, err = legacySigningPublicKey(lease_set[offset : offset+LEASE_SET_SPK_SIZE])
 	} else {
 		// A Certificate is present in this LeaseSet's Destination
 		cert_type, _ := cert.Type()
 		if cert_type == CERT_KEY {
 			// This LeaseSet's Destination's Certificate is a Key Certificate,
-			// create the signing publickey key using any data that might be
-			// contained in the key certificate.
-			signing_public_key, err = KeyCertificate(cert).ConstructSigningPublicKey(
-				lease_set[offset : offset+LEASE_SET_SPK_SIZE],
+			// the signing key is stored without padding in the LeaseSet.
+			signing_key_type, _ := KeyCertificate(cert).SigningPublicKeyType()
+			signing_public_key, err = ConstructSigningPublicKeyByType(
+				signing_key_type,
+				lease_set[offset:offset+spk_size],
 			)
 		} else {
 			// No Certificate is present, return the LEASE_SET_SPK_SIZE byte
 			// SigningPublicKey space as legacy DSA SHA1 SigningPublicKey.
-			var dsa_pk crypto.DSAPublicKey
-			copy(dsa_pk[:], lease_set[offset:offset+LEASE_SET_SPK_SIZE])
-			signing_public_key = dsa_pk
+			signing_public_key, err = legacySigningPublicKey(lease_set[offset : offset+LEASE_SET_SPK_SIZE])
 		}
 
 	}
@@ -190,19 +268,23 @@ func (lease_set LeaseSet) LeaseCount() (count int, err error) {
 	if err != nil {
 		return
 	}
+	spk_size, err := lease_set.signingKeySize()
+	if err != nil {
+		return
+	}
 	remainder_len := len(remainder)
-	if remainder_len < LEASE_SET_PUBKEY_SIZE+LEASE_SET_SPK_SIZE+1 {
+	if remainder_len < LEASE_SET_PUBKEY_SIZE+spk_size+1 {
 		log.WithFields(log.Fields{
 			"at":           "(LeaseSet) LeaseCount",
 			"data_len":     remainder_len,
-			"required_len": LEASE_SET_PUBKEY_SIZE + LEASE_SET_SPK_SIZE + 1,
+			"required_len": LEASE_SET_PUBKEY_SIZE + spk_size + 1,
 			"reason":       "not enough data",
 		}).Error("error parsing lease count")
 		err = errors.New("error parsing lease count: not enough data")
 		return
 	}
-	count = Integer([]byte{remainder[LEASE_SET_PUBKEY_SIZE+LEASE_SET_SPK_SIZE]})
-	if count > 16 {
+	count = Integer([]byte{remainder[LEASE_SET_PUBKEY_SIZE+spk_size]})
+	if count > LEASE_SET_MAX_LEASES {
 		log.WithFields(log.Fields{
 			"at":          "(LeaseSet) LeaseCount",
 			"lease_count": count,
@@ -221,7 +303,11 @@ func (lease_set LeaseSet) Leases() (leases []Lease, err error) {
 	if err != nil {
 		return
 	}
-	offset := len(destination) + LEASE_SET_PUBKEY_SIZE + LEASE_SET_SPK_SIZE + 1
+	spk_size, err := lease_set.signingKeySize()
+	if err != nil {
+		return
+	}
+	offset := len(destination) + LEASE_SET_PUBKEY_SIZE + spk_size + 1
 	count, err := lease_set.LeaseCount()
 	if err != nil {
 		return
@@ -256,15 +342,10 @@ func (lease_set LeaseSet) Signature() (signature Signature, err error) {
 	if err != nil {
 		return
 	}
-	lease_count, err := lease_set.LeaseCount()
+	start, err := lease_set.signatureOffset()
 	if err != nil {
 		return
 	}
-	start := len(destination) +
-		LEASE_SET_PUBKEY_SIZE +
-		LEASE_SET_SPK_SIZE +
-		1 +
-		(LEASE_SIZE * lease_count)
 	cert, err := destination.Certificate()
 	if err != nil {
 		return
@@ -292,23 +373,41 @@ func (lease_set LeaseSet) Signature() (signature Signature, err error) {
 }
 
 //
+// Verify the Signature of this LeaseSet against the SigningPublicKey in its Destination,
+// returning nil if the signature is valid, crypto.ErrInvalidSignature if it does not match,
+// or any errors encountered parsing the LeaseSet.
 //
-//
-func (lease_set LeaseSet) Verify() error {
-	//data_end := len(destination) +
-	//	LEASE_SET_PUBKEY_SIZE +
-	//	LEASE_SET_SPK_SIZE +
-	//	1 +
-	//	(44 * lease_set.LeaseCount())
-	//data := lease_set[:data_end]
-	//spk, _ := lease_set.
-	//	Destination().
-	//	SigningPublicKey()
-	//verifier, err := spk.NewVerifier()
-	//if err != nil {
-	//	return err
-	//}
-	return nil // verifier.Verify(data, lease_set.Signature())
+func (lease_set LeaseSet) Verify() (err error) {
+	destination, err := lease_set.Destination()
+	if err != nil {
+		return
+	}
+	signature, err := lease_set.Signature()
+	if err != nil {
+		return
+	}
+	data_end, err := lease_set.signatureOffset()
+	if err != nil {
+		return
+	}
+	signing_public_key, err := destination.SigningPublicKey()
+	if err != nil {
+		return
+	}
+	if signing_public_key == nil {
+		log.WithFields(log.Fields{
+			"at":     "(LeaseSet) Verify",
+			"reason": "unsupported signing key type",
+		}).Error("error verifying lease set")
+		err = errors.New("error verifying lease set: unsupported signing key type")
+		return
+	}
+	verifier, err := signing_public_key.NewVerifier()
+	if err != nil {
+		return
+	}
+	err = verifier.Verify(lease_set[:data_end], signature)
+	return
 }
 
 //
@@ -346,3 +445,57 @@ func (lease_set LeaseSet) OldestExpiration() (earliest Date, err error) {
 	}
 	return
 }
+
+//
+// Return the size of the SigningPublicKey in this LeaseSet, as specified in the
+// Destination's Key Certificate if present, or the legacy DSA size.
+//
+func (lease_set LeaseSet) signingKeySize() (size int, err error) {
+	destination, err := lease_set.Destination()
+	if err != nil {
+		return
+	}
+	cert, err := destination.Certificate()
+	if err != nil {
+		return
+	}
+	cert_type, _ := cert.Type()
+	if cert_type != CERT_KEY {
+		size = LEASE_SET_SPK_SIZE
+		return
+	}
+	size = KeyCertificate(cert).SigningPublicKeySize()
+	if size == 0 {
+		log.WithFields(log.Fields{
+			"at":     "(LeaseSet) signingKeySize",
+			"reason": "unknown signing key type",
+		}).Error("error parsing lease set")
+		err = errors.New("error parsing lease set: unknown signing key type")
+	}
+	return
+}
+
+//
+// Return the offset of the Signature in this LeaseSet, which is also the
+// length of the signed data.
+//
+func (lease_set LeaseSet) signatureOffset() (offset int, err error) {
+	destination, err := lease_set.Destination()
+	if err != nil {
+		return
+	}
+	spk_size, err := lease_set.signingKeySize()
+	if err != nil {
+		return
+	}
+	lease_count, err := lease_set.LeaseCount()
+	if err != nil {
+		return
+	}
+	offset = len(destination) +
+		LEASE_SET_PUBKEY_SIZE +
+		spk_size +
+		1 +
+		(LEASE_SIZE * lease_count)
+	return
+}
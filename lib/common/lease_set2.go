@@ -0,0 +1,806 @@
+package common
+
+/*
+I2P LeaseSet2Header
+https://geti2p.net/spec/common-structures#leaseset2header
+Accurate for version 0.9.38
+
++----+----+----+----+----+----+----+----+
+| destination                           |
++                                       +
+|                                       |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+|     published     | expires |  flags  |
++----+----+----+----+----+----+----+----+
+| offline_signature (optional)          |
++                                       +
+|                                       |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+
+destination :: Destination
+               length -> >= 387 bytes
+
+published :: 4 byte date
+             length -> 4 bytes
+             Seconds since the epoch, rolls over in 2106.
+
+expires :: 2 byte time
+           length -> 2 bytes
+           Offset from published timestamp in seconds, 18.2 hours max
+
+flags :: 2 bytes
+         Bit order: 15 14 ... 3 2 1 0
+         Bit 0: If 0, no offline keys; if 1, offline keys
+         Bit 1: If 0, a standard published leaseset.
+                If 1, an unpublished leaseset. Should not be flooded, published, or
+                sent in response to a query.
+         Bit 2: If 0, a standard published leaseset.
+                If 1, this unencrypted leaseset will be blinded and encrypted when published.
+         Bits 15-3: set to 0 for compatibility with future uses
+
+offline_signature :: OfflineSignature
+                     length -> varies
+                     Optional, only present if bit 0 is set in the flags.
+
+I2P LeaseSet2
+https://geti2p.net/spec/common-structures#leaseset2
+Accurate for version 0.9.38
+
++----+----+----+----+----+----+----+----+
+|         ls2_header                    |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+|          options                      |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+|numk| keytype0| keylen0 |              |
++----+----+----+----+----+              +
+|          encryption_key_0             |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+| keytypen| keylenn |                   |
++----+----+----+----+                   +
+|          encryption_key_n             |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+| num| Lease2 0                         |
++----+                                  +
+|                                       |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+| Lease2($num-1)                        |
++                                       +
+|                                       |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+| signature                             |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+
+ls2header :: LeaseSet2Header
+             length -> varies
+
+options :: Mapping
+           length -> varies, 2 bytes minimum
+
+numk :: Integer
+        length -> 1 byte
+        Number of key types, key lengths, and PublicKeys to follow
+        value: 1 <= numk <= max TBD
+
+keytype :: The encryption type of the PublicKey to follow.
+           length -> 2 bytes
+
+keylen :: The length of the PublicKey to follow.
+          Must match the specified length of the encryption type.
+          length -> 2 bytes
+
+encryption_key :: PublicKey
+                  length -> keylen bytes
+
+num :: Integer
+       length -> 1 byte
+       Number of Lease2s to follow
+       value: 0 <= num <= 16
+
+leases :: [Lease2]
+          length -> $num*40 bytes
+
+signature :: Signature
+             length -> 40+ bytes
+             The length is as implied by the signature type of the Destination, or
+             of the transient key if offline keys are used.
+             The signature is over the data above, prepended with the single byte
+             containing the DatabaseStore type (3).
+*/
+
+import (
+	"encoding/binary"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/hkparker/go-i2p/lib/crypto"
+	"time"
+)
+
+// DatabaseStore type of a LeaseSet2, prepended to the data when signing
+const (
+	LEASE_SET2_TYPE = 3
+)
+
+// Sizes of various structures in an I2P LeaseSet2
+const (
+	LEASE_SET2_PUBLISHED_SIZE   = 4
+	LEASE_SET2_EXPIRES_SIZE     = 2
+	LEASE_SET2_FLAGS_SIZE       = 2
+	LEASE_SET2_HEADER_DATA_SIZE = LEASE_SET2_PUBLISHED_SIZE + LEASE_SET2_EXPIRES_SIZE + LEASE_SET2_FLAGS_SIZE
+	LEASE_SET2_KEY_HEADER_SIZE  = 4
+	LEASE_SET2_MAX_EXPIRES      = 65535
+	LEASE_SET2_MAX_LEASES       = 16
+)
+
+// LeaseSet2Header flags
+const (
+	LEASE_SET2_FLAG_OFFLINE_KEYS = 1 << 0
+	LEASE_SET2_FLAG_UNPUBLISHED  = 1 << 1
+	LEASE_SET2_FLAG_BLINDED      = 1 << 2
+)
+
+type LeaseSet2Header []byte
+
+//
+// An encryption PublicKey in a LeaseSet2, along with the Key Certificate Public Key Type
+// identifying how to interpret the key data.
+//
+type LeaseSet2Key struct {
+	Type int
+	Data []byte
+}
+
+//
+// Return the PublicKey for this LeaseSet2Key, or an error if the encryption type is unsupported
+// or the key data does not match the length of the type.
+//
+func (key LeaseSet2Key) PublicKey() (public_key crypto.PublicKey, err error) {
+	encryption_type, err := crypto.EncryptionTypeByCode(key.Type)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"at":       "(LeaseSet2Key) PublicKey",
+			"key_type": key.Type,
+			"reason":   err.Error(),
+		}).Error("error constructing public key")
+		err = errors.New("error constructing public key: unsupported encryption key type")
+		return
+	}
+	if len(key.Data) != encryption_type.PublicKeySize {
+		log.WithFields(log.Fields{
+			"at":           "(LeaseSet2Key) PublicKey",
+			"key_type":     key.Type,
+			"data_len":     len(key.Data),
+			"required_len": encryption_type.PublicKeySize,
+			"reason":       "incorrect key size",
+		}).Error("error constructing public key")
+		err = errors.New("error constructing public key: incorrect key size")
+		return
+	}
+	public_key, err = encryption_type.NewPublicKey(key.Data)
+	return
+}
+
+//
+// Build a LeaseSet2Header for a Destination, published and expiring at the provided times, with the
+// flags provided and an optional OfflineSignature.  The offline keys flag is set if and only if an
+// OfflineSignature is provided.
+//
+func NewLeaseSet2Header(destination Destination, published, expires time.Time, flags int, offline_signature OfflineSignature) (header LeaseSet2Header, err error) {
+	destination, _, err = ReadDestination(destination)
+	if err != nil {
+		return
+	}
+	expires_offset := expires.Unix() - published.Unix()
+	if expires_offset < 0 || expires_offset > LEASE_SET2_MAX_EXPIRES {
+		log.WithFields(log.Fields{
+			"at":             "NewLeaseSet2Header",
+			"expires_offset": expires_offset,
+			"max_offset":     LEASE_SET2_MAX_EXPIRES,
+			"reason":         "expiration out of range",
+		}).Error("error building lease set2 header")
+		err = errors.New("error building lease set2 header: expiration out of range")
+		return
+	}
+	flags &^= LEASE_SET2_FLAG_OFFLINE_KEYS
+	if offline_signature != nil {
+		flags |= LEASE_SET2_FLAG_OFFLINE_KEYS
+		signing_key_type, _ := KeysAndCert(destination).signingPublicKeyType()
+		offline_signature, _, err = ReadOfflineSignature(offline_signature, signing_key_type)
+		if err != nil {
+			return
+		}
+	}
+	fields := make([]byte, LEASE_SET2_HEADER_DATA_SIZE)
+	binary.BigEndian.PutUint32(fields[:4], uint32(published.Unix()))
+	binary.BigEndian.PutUint16(fields[4:6], uint16(expires_offset))
+	binary.BigEndian.PutUint16(fields[6:8], uint16(flags))
+	header = append(header, destination...)
+	header = append(header, fields...)
+	header = append(header, offline_signature...)
+	return
+}
+
+//
+// Read a Destination from the LeaseSet2Header.
+//
+func (header LeaseSet2Header) Destination() (destination Destination, err error) {
+	destination, _, err = ReadDestination(header)
+	return
+}
+
+//
+// Return the Date the LeaseSet2 was published and any errors encountered parsing the header.
+//
+func (header LeaseSet2Header) Published() (date Date, err error) {
+	fields, err := header.fields()
+	if err != nil {
+		return
+	}
+	date = dateFromSeconds(fields[:4])
+	return
+}
+
+//
+// Return the Date the LeaseSet2 expires and any errors encountered parsing the header.
+//
+func (header LeaseSet2Header) Expires() (date Date, err error) {
+	fields, err := header.fields()
+	if err != nil {
+		return
+	}
+	expires := Integer(fields[:4]) + Integer(fields[4:6])
+	date = DateFromTime(time.Unix(int64(expires), 0))
+	return
+}
+
+//
+// Return the flags set in the LeaseSet2Header.
+//
+func (header LeaseSet2Header) Flags() (flags int, err error) {
+	fields, err := header.fields()
+	if err != nil {
+		return
+	}
+	flags = Integer(fields[6:8])
+	return
+}
+
+//
+// Return true if the LeaseSet2 is signed by a transient key certified by an OfflineSignature.
+//
+func (header LeaseSet2Header) HasOfflineKeys() bool {
+	flags, err := header.Flags()
+	return err == nil && flags&LEASE_SET2_FLAG_OFFLINE_KEYS != 0
+}
+
+//
+// Return true if the LeaseSet2 should not be flooded, published or sent in response to a query.
+//
+func (header LeaseSet2Header) IsUnpublished() bool {
+	flags, err := header.Flags()
+	return err == nil && flags&LEASE_SET2_FLAG_UNPUBLISHED != 0
+}
+
+//
+// Return true if the LeaseSet2 will be blinded and encrypted when published.
+//
+func (header LeaseSet2Header) IsBlinded() bool {
+	flags, err := header.Flags()
+	return err == nil && flags&LEASE_SET2_FLAG_BLINDED != 0
+}
+
+//
+// Return the OfflineSignature in the LeaseSet2Header, or nil if offline keys are not used.
+//
+func (header LeaseSet2Header) OfflineSignature() (offline_signature OfflineSignature, err error) {
+	destination, remainder, err := ReadDestination(header)
+	if err != nil {
+		return
+	}
+	if !header.HasOfflineKeys() {
+		return
+	}
+	signing_key_type, err := KeysAndCert(destination).signingPublicKeyType()
+	if err != nil {
+		return
+	}
+	offline_signature, _, err = ReadOfflineSignature(remainder[LEASE_SET2_HEADER_DATA_SIZE:], signing_key_type)
+	return
+}
+
+//
+// Return the size of the Signature over a structure beginning with this header, which is the size
+// of signatures made by the transient key if offline keys are used, or by the Destination otherwise.
+//
+func (header LeaseSet2Header) SignatureSize() (size int, err error) {
+	signing_key_type, err := header.signingPublicKeyType()
+	if err != nil {
+		return
+	}
+	size = SignatureSizeByType(signing_key_type)
+	return
+}
+
+//
+// Create a Verifier for signatures over a structure beginning with this header.  If offline keys are
+// used the OfflineSignature is first verified against the Destination, and the transient key must not
+// expire before the structure was published.
+//
+func (header LeaseSet2Header) NewVerifier() (verifier crypto.Verifier, err error) {
+	destination, err := header.Destination()
+	if err != nil {
+		return
+	}
+	signing_public_key, err := destination.SigningPublicKey()
+	if err != nil {
+		return
+	}
+	if signing_public_key == nil {
+		log.WithFields(log.Fields{
+			"at":     "(LeaseSet2Header) NewVerifier",
+			"reason": "unsupported signing key type",
+		}).Error("error verifying lease set2")
+		err = errors.New("error verifying lease set2: unsupported signing key type")
+		return
+	}
+	if header.HasOfflineKeys() {
+		var offline_signature OfflineSignature
+		offline_signature, err = header.OfflineSignature()
+		if err != nil {
+			return
+		}
+		err = offline_signature.Verify(signing_public_key)
+		if err != nil {
+			return
+		}
+		transient_expires, _ := offline_signature.Expires()
+		published, _ := header.Published()
+		if transient_expires.Time().Before(published.Time()) {
+			log.WithFields(log.Fields{
+				"at":        "(LeaseSet2Header) NewVerifier",
+				"expires":   transient_expires.Time(),
+				"published": published.Time(),
+				"reason":    "transient key expired",
+			}).Error("error verifying lease set2")
+			err = errors.New("error verifying lease set2: transient key expired")
+			return
+		}
+		signing_public_key, err = offline_signature.TransientSigningPublicKey()
+		if err != nil {
+			return
+		}
+	}
+	verifier, err = signing_public_key.NewVerifier()
+	return
+}
+
+//
+// Read a LeaseSet2Header from a slice of bytes, returning the remaining data and any errors
+// encountered parsing the header.
+//
+func ReadLeaseSet2Header(data []byte) (header LeaseSet2Header, remainder []byte, err error) {
+	destination, remainder, err := ReadDestination(data)
+	if err != nil {
+		return
+	}
+	header = LeaseSet2Header(data[:len(destination)+len(remainder)])
+	remainder_len := len(remainder)
+	if remainder_len < LEASE_SET2_HEADER_DATA_SIZE {
+		log.WithFields(log.Fields{
+			"at":           "ReadLeaseSet2Header",
+			"data_len":     remainder_len,
+			"required_len": LEASE_SET2_HEADER_DATA_SIZE,
+			"reason":       "not enough data",
+		}).Error("error parsing lease set2 header")
+		err = errors.New("error parsing lease set2 header: not enough data")
+		header = nil
+		return
+	}
+	size := len(destination) + LEASE_SET2_HEADER_DATA_SIZE
+	if header.HasOfflineKeys() {
+		var offline_signature OfflineSignature
+		offline_signature, err = header.OfflineSignature()
+		if err != nil {
+			header = nil
+			return
+		}
+		size += len(offline_signature)
+	}
+	header = LeaseSet2Header(data[:size])
+	remainder = data[size:]
+	return
+}
+
+//
+// Return the published, expires and flags fields following the Destination.
+//
+func (header LeaseSet2Header) fields() (fields []byte, err error) {
+	_, remainder, err := ReadDestination(header)
+	if err != nil {
+		return
+	}
+	remainder_len := len(remainder)
+	if remainder_len < LEASE_SET2_HEADER_DATA_SIZE {
+		log.WithFields(log.Fields{
+			"at":           "(LeaseSet2Header) fields",
+			"data_len":     remainder_len,
+			"required_len": LEASE_SET2_HEADER_DATA_SIZE,
+			"reason":       "not enough data",
+		}).Error("error parsing lease set2 header")
+		err = errors.New("error parsing lease set2 header: not enough data")
+		return
+	}
+	fields = remainder[:LEASE_SET2_HEADER_DATA_SIZE]
+	return
+}
+
+//
+// Return the Signing Key Type used to sign structures beginning with this header.
+//
+func (header LeaseSet2Header) signingPublicKeyType() (signing_key_type int, err error) {
+	if header.HasOfflineKeys() {
+		var offline_signature OfflineSignature
+		offline_signature, err = header.OfflineSignature()
+		if err != nil {
+			return
+		}
+		signing_key_type, err = offline_signature.TransientSigningPublicKeyType()
+		return
+	}
+	destination, err := header.Destination()
+	if err != nil {
+		return
+	}
+	signing_key_type, err = KeysAndCert(destination).signingPublicKeyType()
+	return
+}
+
+type LeaseSet2 []byte
+
+//
+// Build a LeaseSet2 from a LeaseSet2Header, an options Mapping, at least one encryption key and
+// up to 16 Lease2s, signed with the provided Signer.  The Signer must belong to the Destination's
+// SigningPublicKey, or to the transient key if the header contains a valid OfflineSignature.
+// Each encryption key must have the length of its registered encryption type.
+//
+func NewLeaseSet2(header LeaseSet2Header, options Mapping, encryption_keys []LeaseSet2Key, leases []Lease2, signer crypto.Signer) (lease_set LeaseSet2, err error) {
+	header, _, err = ReadLeaseSet2Header(header)
+	if err != nil {
+		return
+	}
+	if _, err = header.NewVerifier(); err != nil {
+		return
+	}
+	key_count := len(encryption_keys)
+	if key_count == 0 || key_count > 255 {
+		log.WithFields(log.Fields{
+			"at":        "NewLeaseSet2",
+			"key_count": key_count,
+			"reason":    "must have between 1 and 255 encryption keys",
+		}).Error("error building lease set2")
+		err = errors.New("error building lease set2: invalid number of encryption keys")
+		return
+	}
+	lease_count := len(leases)
+	if lease_count > LEASE_SET2_MAX_LEASES {
+		log.WithFields(log.Fields{
+			"at":          "NewLeaseSet2",
+			"lease_count": lease_count,
+			"reason":      "more than 16 leases",
+		}).Error("error building lease set2")
+		err = errors.New("error building lease set2: more than 16 leases")
+		return
+	}
+	for _, key := range encryption_keys {
+		if len(key.Data) > 65535 {
+			log.WithFields(log.Fields{
+				"at":       "NewLeaseSet2",
+				"key_type": key.Type,
+				"data_len": len(key.Data),
+				"reason":   "encryption key too long",
+			}).Error("error building lease set2")
+			err = errors.New("error building lease set2: encryption key too long")
+			return
+		}
+		if _, err = key.PublicKey(); err != nil {
+			return
+		}
+	}
+	if len(options) < 2 {
+		options = ValuesToMapping(MappingValues{})
+	}
+	data := make([]byte, 0)
+	data = append(data, header...)
+	data = append(data, options...)
+	data = append(data, byte(key_count))
+	for _, key := range encryption_keys {
+		key_header := make([]byte, LEASE_SET2_KEY_HEADER_SIZE)
+		binary.BigEndian.PutUint16(key_header[:2], uint16(key.Type))
+		binary.BigEndian.PutUint16(key_header[2:], uint16(len(key.Data)))
+		data = append(data, key_header...)
+		data = append(data, key.Data...)
+	}
+	data = append(data, byte(lease_count))
+	for _, lease := range leases {
+		data = append(data, lease[:]...)
+	}
+	signature, err := signer.Sign(append([]byte{LEASE_SET2_TYPE}, data...))
+	if err != nil {
+		return
+	}
+	sig_size, err := header.SignatureSize()
+	if err != nil {
+		return
+	}
+	if len(signature) != sig_size {
+		log.WithFields(log.Fields{
+			"at":           "NewLeaseSet2",
+			"sig_len":      len(signature),
+			"required_len": sig_size,
+			"reason":       "signature size does not match signing key",
+		}).Error("error building lease set2")
+		err = errors.New("error building lease set2: signature size does not match signing key")
+		return
+	}
+	lease_set = LeaseSet2(append(data, signature...))
+	if verify_err := lease_set.Verify(); verify_err != nil {
+		log.WithFields(log.Fields{
+			"at":     "NewLeaseSet2",
+			"reason": "signer does not match signing key",
+		}).Error("error building lease set2")
+		err = errors.New("error building lease set2: signer does not match signing key")
+		lease_set = nil
+	}
+	return
+}
+
+//
+// Return the LeaseSet2Header at the beginning of the LeaseSet2.
+//
+func (lease_set LeaseSet2) Header() (header LeaseSet2Header, err error) {
+	header, _, err = ReadLeaseSet2Header(lease_set)
+	return
+}
+
+//
+// Read a Destination from the LeaseSet2.
+//
+func (lease_set LeaseSet2) Destination() (destination Destination, err error) {
+	destination, _, err = ReadDestination(lease_set)
+	return
+}
+
+//
+// Return the Date the LeaseSet2 was published.
+//
+func (lease_set LeaseSet2) Published() (Date, error) {
+	return LeaseSet2Header(lease_set).Published()
+}
+
+//
+// Return the Date the LeaseSet2 expires.
+//
+func (lease_set LeaseSet2) Expires() (Date, error) {
+	return LeaseSet2Header(lease_set).Expires()
+}
+
+//
+// Return the options Mapping in the LeaseSet2.
+//
+func (lease_set LeaseSet2) Options() (options Mapping, err error) {
+	_, remainder, err := ReadLeaseSet2Header(lease_set)
+	if err != nil {
+		return
+	}
+	options, _, err = ReadMapping(remainder)
+	return
+}
+
+//
+// Return the encryption keys in the LeaseSet2 in the order they were published, which is the
+// order of preference of the LeaseSet2's owner.
+//
+func (lease_set LeaseSet2) EncryptionKeys() (keys []LeaseSet2Key, err error) {
+	keys, _, err = lease_set.readEncryptionKeys()
+	return
+}
+
+//
+// Return the number of Lease2s in the LeaseSet2.
+//
+func (lease_set LeaseSet2) LeaseCount() (count int, err error) {
+	_, remainder, err := lease_set.readEncryptionKeys()
+	if err != nil {
+		return
+	}
+	if len(remainder) < 1 {
+		log.WithFields(log.Fields{
+			"at":           "(LeaseSet2) LeaseCount",
+			"data_len":     len(remainder),
+			"required_len": 1,
+			"reason":       "not enough data",
+		}).Error("error parsing lease count")
+		err = errors.New("error parsing lease count: not enough data")
+		return
+	}
+	count = Integer([]byte{remainder[0]})
+	if count > LEASE_SET2_MAX_LEASES {
+		log.WithFields(log.Fields{
+			"at":          "(LeaseSet2) LeaseCount",
+			"lease_count": count,
+			"reason":      "more than 16 leases",
+		}).Warn("invalid lease set2")
+		err = errors.New("invalid lease set2: more than 16 leases")
+	}
+	return
+}
+
+//
+// Read the Lease2s in this LeaseSet2, returning a partial set if there is insufficient data.
+//
+func (lease_set LeaseSet2) Leases() (leases []Lease2, err error) {
+	count, err := lease_set.LeaseCount()
+	if err != nil {
+		return
+	}
+	_, remainder, _ := lease_set.readEncryptionKeys()
+	remainder = remainder[1:]
+	for i := 0; i < count; i++ {
+		if len(remainder) < LEASE2_SIZE {
+			log.WithFields(log.Fields{
+				"at":           "(LeaseSet2) Leases",
+				"data_len":     len(remainder),
+				"required_len": LEASE2_SIZE,
+				"reason":       "some leases missing",
+			}).Error("error parsing lease set2")
+			err = errors.New("error parsing lease set2: some leases missing")
+			return
+		}
+		var lease Lease2
+		copy(lease[:], remainder[:LEASE2_SIZE])
+		leases = append(leases, lease)
+		remainder = remainder[LEASE2_SIZE:]
+	}
+	return
+}
+
+//
+// Return the Signature following the Lease2s, sized as implied by the Destination's signing key
+// type or the transient key type if offline keys are used.
+//
+func (lease_set LeaseSet2) Signature() (signature Signature, err error) {
+	start, err := lease_set.signatureOffset()
+	if err != nil {
+		return
+	}
+	sig_size, err := LeaseSet2Header(lease_set).SignatureSize()
+	if err != nil {
+		return
+	}
+	end := start + sig_size
+	lease_set_len := len(lease_set)
+	if lease_set_len < end {
+		log.WithFields(log.Fields{
+			"at":           "(LeaseSet2) Signature",
+			"data_len":     lease_set_len,
+			"required_len": end,
+			"reason":       "not enough data",
+		}).Error("error parsing signature")
+		err = errors.New("error parsing signature: not enough data")
+		return
+	}
+	signature = Signature(lease_set[start:end])
+	return
+}
+
+//
+// Verify the Signature of this LeaseSet2 against the Destination's SigningPublicKey, or against
+// the transient key after verifying its OfflineSignature if offline keys are used.  Returns nil
+// if the signature is valid, crypto.ErrInvalidSignature if it does not match, or any errors
+// encountered parsing the LeaseSet2.
+//
+func (lease_set LeaseSet2) Verify() (err error) {
+	signature, err := lease_set.Signature()
+	if err != nil {
+		return
+	}
+	data_end, _ := lease_set.signatureOffset()
+	verifier, err := LeaseSet2Header(lease_set).NewVerifier()
+	if err != nil {
+		return
+	}
+	err = verifier.Verify(append([]byte{LEASE_SET2_TYPE}, lease_set[:data_end]...), signature)
+	return
+}
+
+//
+// Read the encryption keys following the options Mapping, returning the data that follows them.
+//
+func (lease_set LeaseSet2) readEncryptionKeys() (keys []LeaseSet2Key, remainder []byte, err error) {
+	_, remainder, err = ReadLeaseSet2Header(lease_set)
+	if err != nil {
+		return
+	}
+	_, remainder, err = ReadMapping(remainder)
+	if err != nil {
+		return
+	}
+	if len(remainder) < 1 {
+		log.WithFields(log.Fields{
+			"at":           "(LeaseSet2) readEncryptionKeys",
+			"data_len":     len(remainder),
+			"required_len": 1,
+			"reason":       "not enough data",
+		}).Error("error parsing encryption keys")
+		err = errors.New("error parsing encryption keys: not enough data")
+		return
+	}
+	key_count := Integer([]byte{remainder[0]})
+	remainder = remainder[1:]
+	for i := 0; i < key_count; i++ {
+		if len(remainder) < LEASE_SET2_KEY_HEADER_SIZE {
+			err = errors.New("error parsing encryption keys: not enough data")
+			break
+		}
+		key_type := Integer(remainder[:2])
+		key_len := Integer(remainder[2:LEASE_SET2_KEY_HEADER_SIZE])
+		remainder = remainder[LEASE_SET2_KEY_HEADER_SIZE:]
+		if len(remainder) < key_len {
+			err = errors.New("error parsing encryption keys: not enough data")
+			break
+		}
+		keys = append(keys, LeaseSet2Key{
+			Type: key_type,
+			Data: remainder[:key_len],
+		})
+		remainder = remainder[key_len:]
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"at":        "(LeaseSet2) readEncryptionKeys",
+			"key_count": key_count,
+			"reason":    "some keys missing",
+		}).Error("error parsing encryption keys")
+	}
+	return
+}
+
+//
+// Return the offset of the Signature in this LeaseSet2, which is also the length of the signed
+// data excluding the DatabaseStore type.
+//
+func (lease_set LeaseSet2) signatureOffset() (offset int, err error) {
+	count, err := lease_set.LeaseCount()
+	if err != nil {
+		return
+	}
+	_, remainder, _ := lease_set.readEncryptionKeys()
+	offset = len(lease_set) - len(remainder) + 1 + count*LEASE2_SIZE
+	return
+}
@@ -0,0 +1,327 @@
+package common
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"github.com/hkparker/go-i2p/lib/crypto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type testEd25519Signer ed25519.PrivateKey
+
+func (signer testEd25519Signer) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(signer), data), nil
+}
+
+func (signer testEd25519Signer) SignHash(h []byte) ([]byte, error) {
+	return signer.Sign(h)
+}
+
+func (signer testEd25519Signer) NewSigner() (crypto.Signer, error) {
+	return signer, nil
+}
+
+func (signer testEd25519Signer) Len() int {
+	return len(signer)
+}
+
+func (signer testEd25519Signer) Bytes() []byte {
+	return ed25519.PrivateKey(signer).Seed()
+}
+
+func (signer testEd25519Signer) Public() (crypto.SigningPublicKey, error) {
+	var public_key crypto.Ed25519PublicKey
+	copy(public_key[:], ed25519.PrivateKey(signer).Public().(ed25519.PublicKey))
+	return public_key, nil
+}
+
+func (signer testEd25519Signer) Generate() (crypto.SigningPrivateKey, error) {
+	_, private_key, err := ed25519.GenerateKey(rand.Reader)
+	return testEd25519Signer(private_key), err
+}
+
+func buildEd25519Destination() (Destination, testEd25519Signer, error) {
+	public_key, private_key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	destination_data := make([]byte, 256+128-len(public_key))
+	destination_data = append(destination_data, public_key...)
+	destination_data = append(destination_data, []byte{0x05, 0x00, 0x04, 0x00, 0x07, 0x00, 0x00}...)
+	return Destination(destination_data), testEd25519Signer(private_key), nil
+}
+
+func buildOfflineSignature(signer crypto.Signer, expires time.Time) (OfflineSignature, testEd25519Signer, error) {
+	transient_private_key, err := testEd25519Signer(nil).Generate()
+	if err != nil {
+		return nil, nil, err
+	}
+	transient_public_key, _ := transient_private_key.Public()
+	offline_signature, err := NewOfflineSignature(KEYCERT_SIGN_ED25519, signer, expires, KEYCERT_SIGN_ED25519, transient_public_key)
+	return offline_signature, transient_private_key.(testEd25519Signer), err
+}
+
+func buildLeaseSet2Keys() []LeaseSet2Key {
+	return []LeaseSet2Key{
+		{Type: 4, Data: make([]byte, 32)},
+		{Type: KEYCERT_CRYPTO_ELG, Data: buildPublicKey()},
+	}
+}
+
+func buildLeaseSet2Leases() []Lease2 {
+	return []Lease2{
+		NewLease2(Hash{0x01}, 1, time.Unix(1500000600, 0)),
+		NewLease2(Hash{0x02}, 2, time.Unix(1500000660, 0)),
+	}
+}
+
+func TestLeaseSet2KeyPublicKey(t *testing.T) {
+	assert := assert.New(t)
+
+	keys := buildLeaseSet2Keys()
+	keys[0].Data[0] = 0x01
+	public_key, err := keys[0].PublicKey()
+	if assert.Nil(err) {
+		assert.Equal(crypto.X25519PublicKey{0x01}, public_key)
+		_, err = public_key.NewEncrypter()
+		assert.Nil(err)
+	}
+	public_key, err = keys[1].PublicKey()
+	if assert.Nil(err) {
+		assert.Equal(KEYCERT_CRYPTO_ELG_SIZE, public_key.Len())
+	}
+
+	_, err = LeaseSet2Key{Type: KEYCERT_CRYPTO_X25519, Data: make([]byte, 31)}.PublicKey()
+	if assert.NotNil(err) {
+		assert.Equal("error constructing public key: incorrect key size", err.Error())
+	}
+	_, err = LeaseSet2Key{Type: 1, Data: make([]byte, 64)}.PublicKey()
+	if assert.NotNil(err) {
+		assert.Equal("error constructing public key: unsupported encryption key type", err.Error())
+	}
+}
+
+func TestLeaseSet2RoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, err := buildEd25519Destination()
+	if !assert.Nil(err) {
+		return
+	}
+	published := time.Unix(1500000000, 0)
+	header, err := NewLeaseSet2Header(destination, published, published.Add(10*time.Minute), LEASE_SET2_FLAG_UNPUBLISHED, nil)
+	if !assert.Nil(err) {
+		return
+	}
+	lease_set, err := NewLeaseSet2(header, buildMapping(), buildLeaseSet2Keys(), buildLeaseSet2Leases(), signer)
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Nil(lease_set.Verify())
+	parsed_destination, err := lease_set.Destination()
+	assert.Nil(err)
+	assert.Equal(destination, parsed_destination)
+	parsed_published, err := lease_set.Published()
+	assert.Nil(err)
+	assert.Equal(published.Unix(), parsed_published.Time().Unix())
+	expires, err := lease_set.Expires()
+	assert.Nil(err)
+	assert.Equal(published.Add(10*time.Minute).Unix(), expires.Time().Unix())
+	parsed_header, err := lease_set.Header()
+	if assert.Nil(err) {
+		assert.Equal(header, parsed_header)
+		assert.True(parsed_header.IsUnpublished())
+		assert.False(parsed_header.HasOfflineKeys())
+		assert.False(parsed_header.IsBlinded())
+	}
+	options, err := lease_set.Options()
+	assert.Nil(err)
+	assert.Equal(buildMapping(), options)
+	keys, err := lease_set.EncryptionKeys()
+	assert.Nil(err)
+	assert.Equal(buildLeaseSet2Keys(), keys)
+	leases, err := lease_set.Leases()
+	assert.Nil(err)
+	assert.Equal(buildLeaseSet2Leases(), leases)
+	signature, err := lease_set.Signature()
+	assert.Nil(err)
+	assert.Equal(KEYCERT_SIGN_ED25519_SIZE*2, len(signature))
+}
+
+func TestLeaseSet2VerifyReturnsErrorWithForgedData(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, err := buildEd25519Destination()
+	if !assert.Nil(err) {
+		return
+	}
+	published := time.Unix(1500000000, 0)
+	header, _ := NewLeaseSet2Header(destination, published, published.Add(time.Minute), 0, nil)
+	lease_set, err := NewLeaseSet2(header, nil, buildLeaseSet2Keys(), buildLeaseSet2Leases(), signer)
+	if assert.Nil(err) {
+		lease_set[len(lease_set)-64-1] ^= 0xff
+		assert.Equal(crypto.ErrInvalidSignature, lease_set.Verify())
+	}
+}
+
+func TestLeaseSet2WithOfflineKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, err := buildEd25519Destination()
+	if !assert.Nil(err) {
+		return
+	}
+	published := time.Unix(1500000000, 0)
+	offline_signature, transient_signer, err := buildOfflineSignature(signer, published.Add(24*time.Hour))
+	if !assert.Nil(err) {
+		return
+	}
+	header, err := NewLeaseSet2Header(destination, published, published.Add(time.Minute), 0, offline_signature)
+	if !assert.Nil(err) {
+		return
+	}
+	lease_set, err := NewLeaseSet2(header, nil, buildLeaseSet2Keys(), buildLeaseSet2Leases(), transient_signer)
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Nil(lease_set.Verify())
+	parsed_header, err := lease_set.Header()
+	if assert.Nil(err) && assert.True(parsed_header.HasOfflineKeys()) {
+		parsed_offline_signature, err := parsed_header.OfflineSignature()
+		assert.Nil(err)
+		assert.Equal(offline_signature, parsed_offline_signature)
+	}
+	leases, err := lease_set.Leases()
+	assert.Nil(err)
+	assert.Equal(buildLeaseSet2Leases(), leases)
+
+	forged, err := NewLeaseSet2(header, nil, buildLeaseSet2Keys(), buildLeaseSet2Leases(), signer)
+	if assert.NotNil(err, "lease set2 signed by the long-term key instead of the transient key") {
+		assert.Equal("error building lease set2: signer does not match signing key", err.Error())
+	}
+	assert.Nil(forged)
+}
+
+func TestLeaseSet2WithExpiredOfflineKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, err := buildEd25519Destination()
+	if !assert.Nil(err) {
+		return
+	}
+	published := time.Unix(1500000000, 0)
+	offline_signature, transient_signer, err := buildOfflineSignature(signer, published.Add(-time.Hour))
+	if !assert.Nil(err) {
+		return
+	}
+	header, _ := NewLeaseSet2Header(destination, published, published.Add(time.Minute), 0, offline_signature)
+	_, err = NewLeaseSet2(header, nil, buildLeaseSet2Keys(), nil, transient_signer)
+	if assert.NotNil(err) {
+		assert.Equal("error verifying lease set2: transient key expired", err.Error())
+	}
+	_, err = header.NewVerifier()
+	if assert.NotNil(err) {
+		assert.Equal("error verifying lease set2: transient key expired", err.Error())
+	}
+}
+
+func TestLeaseSet2WithForgedOfflineSignature(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, err := buildEd25519Destination()
+	if !assert.Nil(err) {
+		return
+	}
+	published := time.Unix(1500000000, 0)
+	offline_signature, transient_signer, err := buildOfflineSignature(signer, published.Add(time.Hour))
+	if !assert.Nil(err) {
+		return
+	}
+	offline_signature[len(offline_signature)-1] ^= 0xff
+	header, _ := NewLeaseSet2Header(destination, published, published.Add(time.Minute), 0, offline_signature)
+	_, err = NewLeaseSet2(header, nil, buildLeaseSet2Keys(), nil, transient_signer)
+	assert.Equal(crypto.ErrInvalidSignature, err)
+	_, err = header.NewVerifier()
+	assert.Equal(crypto.ErrInvalidSignature, err)
+}
+
+func TestNewLeaseSet2HeaderWithExpirationOutOfRange(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, _, err := buildEd25519Destination()
+	if !assert.Nil(err) {
+		return
+	}
+	published := time.Unix(1500000000, 0)
+	_, err = NewLeaseSet2Header(destination, published, published.Add(24*time.Hour), 0, nil)
+	if assert.NotNil(err) {
+		assert.Equal("error building lease set2 header: expiration out of range", err.Error())
+	}
+}
+
+func TestLeaseSet2SignatureWithTruncatedData(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, err := buildEd25519Destination()
+	if !assert.Nil(err) {
+		return
+	}
+	published := time.Unix(1500000000, 0)
+	header, _ := NewLeaseSet2Header(destination, published, published.Add(time.Minute), 0, nil)
+	lease_set, err := NewLeaseSet2(header, nil, buildLeaseSet2Keys(), buildLeaseSet2Leases(), signer)
+	if assert.Nil(err) {
+		lease_set = lease_set[:len(lease_set)-70]
+		_, err = lease_set.Leases()
+		if assert.NotNil(err) {
+			assert.Equal("error parsing lease set2: some leases missing", err.Error())
+		}
+		err = lease_set.Verify()
+		assert.NotNil(err)
+	}
+}
+
+func TestNewLeaseSet2WithInvalidEncryptionKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, err := buildEd25519Destination()
+	if !assert.Nil(err) {
+		return
+	}
+	published := time.Unix(1500000000, 0)
+	header, _ := NewLeaseSet2Header(destination, published, published.Add(time.Minute), 0, nil)
+	keys := []LeaseSet2Key{{Type: KEYCERT_CRYPTO_X25519, Data: make([]byte, 31)}}
+	lease_set, err := NewLeaseSet2(header, nil, keys, nil, signer)
+	if assert.NotNil(err) {
+		assert.Equal("error constructing public key: incorrect key size", err.Error())
+	}
+	assert.Nil(lease_set)
+	keys = []LeaseSet2Key{{Type: KEYCERT_CRYPTO_X25519, Data: make([]byte, 65536)}}
+	_, err = NewLeaseSet2(header, nil, keys, nil, signer)
+	if assert.NotNil(err) {
+		assert.Equal("error building lease set2: encryption key too long", err.Error())
+	}
+}
+
+func TestNewLeaseSet2WithSignerForAnotherKey(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, _, err := buildEd25519Destination()
+	if !assert.Nil(err) {
+		return
+	}
+	_, other_signer, err := buildEd25519Destination()
+	if !assert.Nil(err) {
+		return
+	}
+	published := time.Unix(1500000000, 0)
+	header, _ := NewLeaseSet2Header(destination, published, published.Add(time.Minute), 0, nil)
+	lease_set, err := NewLeaseSet2(header, nil, buildLeaseSet2Keys(), nil, other_signer)
+	if assert.NotNil(err) {
+		assert.Equal("error building lease set2: signer does not match signing key", err.Error())
+	}
+	assert.Nil(lease_set)
+}
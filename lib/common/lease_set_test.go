@@ -2,8 +2,12 @@ package common
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"github.com/hkparker/go-i2p/lib/crypto"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func buildDestination() RouterIdentity {
@@ -21,7 +25,7 @@ func buildPublicKey() []byte {
 }
 
 func buildSigningKey() []byte {
-	sk := make([]byte, 128)
+	sk := make([]byte, KEYCERT_SIGN_P256_SIZE)
 	for i := range sk {
 		sk[i] = 0x02
 	}
@@ -98,7 +102,7 @@ func TestSigningKeyIsCorrect(t *testing.T) {
 	lease_set := buildFullLeaseSet(1)
 	sk, err := lease_set.SigningKey()
 	if assert.Nil(err) {
-		assert.Equal(128, sk.Len())
+		assert.Equal(KEYCERT_SIGN_P256_SIZE, sk.Len())
 	}
 }
 
@@ -201,3 +205,160 @@ func TestOldestExpirationIsCorrect(t *testing.T) {
 		latest,
 	)
 }
+
+func buildSignedLeaseSet(n int) (LeaseSet, error) {
+	public_key, private_key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	lease_set_data := make([]byte, 256+128-len(public_key))
+	lease_set_data = append(lease_set_data, public_key...)
+	lease_set_data = append(lease_set_data, []byte{0x05, 0x00, 0x04, 0x00, 0x07, 0x00, 0x00}...)
+	lease_set_data = append(lease_set_data, buildPublicKey()...)
+	lease_set_data = append(lease_set_data, make([]byte, KEYCERT_SIGN_ED25519_SIZE)...)
+	lease_set_data = append(lease_set_data, byte(n))
+	lease_set_data = append(lease_set_data, buildLease(n)...)
+	lease_set_data = append(lease_set_data, ed25519.Sign(private_key, lease_set_data)...)
+	return LeaseSet(lease_set_data), nil
+}
+
+func TestVerifyWithValidSignature(t *testing.T) {
+	assert := assert.New(t)
+
+	lease_set, err := buildSignedLeaseSet(3)
+	if assert.Nil(err) {
+		assert.Nil(lease_set.Verify())
+	}
+}
+
+func TestVerifyReturnsErrorWithForgedLease(t *testing.T) {
+	assert := assert.New(t)
+
+	lease_set, err := buildSignedLeaseSet(3)
+	if assert.Nil(err) {
+		lease_set[391+256+32+1] ^= 0xff
+		assert.Equal(crypto.ErrInvalidSignature, lease_set.Verify())
+	}
+}
+
+func TestVerifyReturnsErrorWithTruncatedLeaseSet(t *testing.T) {
+	assert := assert.New(t)
+
+	lease_set, err := buildSignedLeaseSet(3)
+	if assert.Nil(err) {
+		lease_set = lease_set[:len(lease_set)-1]
+		err = lease_set.Verify()
+		if assert.NotNil(err) {
+			assert.Equal("error parsing signature: not enough data", err.Error())
+		}
+	}
+}
+
+func buildDSADestination() (Destination, crypto.DSAPrivateKey, error) {
+	var sk crypto.DSAPrivateKey
+	sk, err := sk.Generate()
+	if err != nil {
+		return nil, sk, err
+	}
+	pk, err := sk.Public()
+	if err != nil {
+		return nil, sk, err
+	}
+	destination_data := make([]byte, 256)
+	destination_data = append(destination_data, pk[:]...)
+	destination_data = append(destination_data, []byte{0x00, 0x00, 0x00}...)
+	return Destination(destination_data), sk, nil
+}
+
+func TestNewLeaseSetIsSignedAndParses(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, sk, err := buildDSADestination()
+	if !assert.Nil(err) {
+		return
+	}
+	signer, err := sk.NewSigner()
+	if !assert.Nil(err) {
+		return
+	}
+	var encryption_key crypto.ElgPublicKey
+	copy(encryption_key[:], buildPublicKey())
+	var signing_key crypto.DSAPublicKey
+	leases := []Lease{
+		NewLease(Hash{0x01}, 1, time.Unix(1500000000, 0)),
+		NewLease(Hash{0x02}, 2, time.Unix(1500000600, 0)),
+	}
+
+	lease_set, err := NewLeaseSet(destination, encryption_key, signing_key, leases, signer)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Nil(lease_set.Verify())
+	parsed_destination, err := lease_set.Destination()
+	assert.Nil(err)
+	assert.Equal(destination, parsed_destination)
+	parsed_key, err := lease_set.PublicKey()
+	assert.Nil(err)
+	assert.Equal(encryption_key, parsed_key)
+	parsed_leases, err := lease_set.Leases()
+	if assert.Nil(err) {
+		assert.Equal(leases, parsed_leases)
+	}
+}
+
+func TestNewLeaseSetWithTooManyLeases(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, _, err := buildDSADestination()
+	if !assert.Nil(err) {
+		return
+	}
+	var encryption_key crypto.ElgPublicKey
+	var signing_key crypto.DSAPublicKey
+	leases := make([]Lease, LEASE_SET_MAX_LEASES+1)
+	_, err = NewLeaseSet(destination, encryption_key, signing_key, leases, nil)
+	if assert.NotNil(err) {
+		assert.Equal("error building lease set: more than 16 leases", err.Error())
+	}
+}
+
+func TestNewLeaseSetWithMismatchedSigningKey(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, _, err := buildDSADestination()
+	if !assert.Nil(err) {
+		return
+	}
+	var encryption_key crypto.ElgPublicKey
+	var signing_key crypto.Ed25519PublicKey
+	_, err = NewLeaseSet(destination, encryption_key, signing_key, nil, nil)
+	if assert.NotNil(err) {
+		assert.Equal("error building lease set: signing key does not match destination", err.Error())
+	}
+}
+
+func TestNewLeaseSetWithSignerForAnotherKey(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, _, err := buildDSADestination()
+	if !assert.Nil(err) {
+		return
+	}
+	var other_sk crypto.DSAPrivateKey
+	other_sk, err = other_sk.Generate()
+	if !assert.Nil(err) {
+		return
+	}
+	signer, err := other_sk.NewSigner()
+	if !assert.Nil(err) {
+		return
+	}
+	var encryption_key crypto.ElgPublicKey
+	var signing_key crypto.DSAPublicKey
+	leases := []Lease{NewLease(Hash{0x01}, 1, time.Unix(1500000000, 0))}
+	lease_set, err := NewLeaseSet(destination, encryption_key, signing_key, leases, signer)
+	if assert.NotNil(err) {
+		assert.Equal("error building lease set: signer does not match destination", err.Error())
+	}
+	assert.Nil(lease_set)
+}
@@ -0,0 +1,22 @@
+package common
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewLeaseHasCorrectFields(t *testing.T) {
+	assert := assert.New(t)
+
+	var gateway Hash
+	for i := range gateway {
+		gateway[i] = byte(i)
+	}
+	expiration := time.Unix(1500000000, 0)
+	lease := NewLease(gateway, 0x01020304, expiration)
+
+	assert.Equal(gateway, lease.TunnelGateway())
+	assert.Equal(uint32(0x01020304), lease.TunnelID())
+	assert.Equal(expiration.Unix(), lease.Date().Time().Unix())
+}
@@ -32,6 +32,11 @@ import (
 	"sort"
 )
 
+// Maximum number of bytes following the size of a Mapping
+const (
+	MAPPING_MAX_SIZE = 65535
+)
+
 type Mapping []byte
 
 // Parsed key-values pairs inside a Mapping.
@@ -119,6 +124,38 @@ func (mapping Mapping) Values() (map_values MappingValues, errs []error) {
 	return
 }
 
+//
+// Read a Mapping from a slice of bytes, returning any extra data on the end of the slice
+// and any errors if the Mapping is shorter than specified by its size.
+//
+func ReadMapping(data []byte) (mapping Mapping, remainder []byte, err error) {
+	data_len := len(data)
+	if data_len < 2 {
+		log.WithFields(log.Fields{
+			"at":           "ReadMapping",
+			"data_len":     data_len,
+			"required_len": 2,
+			"reason":       "not enough data",
+		}).Error("error parsing mapping")
+		err = errors.New("error parsing mapping: not enough data")
+		return
+	}
+	inferred_length := Integer(data[:2]) + 2
+	if data_len < inferred_length {
+		log.WithFields(log.Fields{
+			"at":           "ReadMapping",
+			"data_len":     data_len,
+			"required_len": inferred_length,
+			"reason":       "data shorter than specified",
+		}).Error("error parsing mapping")
+		err = errors.New("error parsing mapping: data shorter than specified by size")
+		return
+	}
+	mapping = Mapping(data[:inferred_length])
+	remainder = data[inferred_length:]
+	return
+}
+
 //
 // Return true if two keys in a mapping are identical.
 //
@@ -156,6 +193,199 @@ func ValuesToMapping(values MappingValues) (mapping Mapping) {
 	return
 }
 
+//
+// Read a Mapping from a slice of bytes like ReadMapping, but also return an error if any
+// key-value pair is malformed or if a key appears more than once.  Signed structures and new
+// configuration should be read strictly, while ReadMapping accepts legacy data.
+//
+func ReadMappingStrict(data []byte) (mapping Mapping, remainder []byte, err error) {
+	mapping, remainder, err = ReadMapping(data)
+	if err != nil || Integer(mapping[:2]) == 0 {
+		return
+	}
+	_, errs := mapping.Values()
+	if len(errs) != 0 {
+		log.WithFields(log.Fields{
+			"at":     "ReadMappingStrict",
+			"reason": errs[0].Error(),
+		}).Error("error parsing mapping")
+		err = errors.New("error parsing mapping: invalid key-value pair")
+	} else if mapping.HasDuplicateKeys() {
+		log.WithFields(log.Fields{
+			"at":     "ReadMappingStrict",
+			"reason": "duplicate keys",
+		}).Error("error parsing mapping")
+		err = errors.New("error parsing mapping: duplicate keys")
+	}
+	if err != nil {
+		mapping = nil
+		remainder = data
+	}
+	return
+}
+
+//
+// Return the value for a key in the Mapping and whether the key is present.  If a legacy
+// Mapping contains the key more than once the last value is returned.
+//
+func (mapping Mapping) Get(key string) (value string, present bool) {
+	pairs, _ := mapping.pairs()
+	for _, pair := range pairs {
+		if pair[0] == key {
+			value = pair[1]
+			present = true
+		}
+	}
+	return
+}
+
+//
+// Set the value for a key, replacing any existing values.  Other keys repeated in a legacy
+// Mapping are kept.  The Mapping is reserialized in sorted order, so it stays valid for signing,
+// and is left unchanged if it cannot be parsed.
+//
+func (mapping *Mapping) Set(key, value string) (err error) {
+	pairs, err := mapping.pairs()
+	if err != nil {
+		return
+	}
+	pairs = append(pairsWithoutKey(pairs, key), [2]string{key, value})
+	updated, err := pairsToMapping(pairs)
+	if err != nil {
+		return
+	}
+	if len(updated) > MAPPING_MAX_SIZE+2 {
+		log.WithFields(log.Fields{
+			"at":       "(Mapping) Set",
+			"data_len": len(updated) - 2,
+			"max_len":  MAPPING_MAX_SIZE,
+			"reason":   "too much data",
+		}).Error("cannot set mapping value")
+		err = errors.New("cannot store that much data in mapping")
+		return
+	}
+	*mapping = updated
+	return
+}
+
+//
+// Remove a key and its values from the Mapping, reserializing it in sorted order.  Deleting a
+// key that is not present leaves the Mapping unchanged, as does a Mapping that cannot be parsed.
+//
+func (mapping *Mapping) Delete(key string) (err error) {
+	pairs, err := mapping.pairs()
+	if err != nil {
+		return
+	}
+	remaining := pairsWithoutKey(pairs, key)
+	if len(remaining) == len(pairs) {
+		return
+	}
+	updated, err := pairsToMapping(remaining)
+	if err != nil {
+		return
+	}
+	*mapping = updated
+	return
+}
+
+//
+// Return the keys in the Mapping in the order they are serialized.  Keys repeated in a legacy
+// Mapping are returned once.
+//
+func (mapping Mapping) Keys() (keys []string) {
+	seen := make(map[string]bool)
+	pairs, _ := mapping.pairs()
+	for _, pair := range pairs {
+		if !seen[pair[0]] {
+			seen[pair[0]] = true
+			keys = append(keys, pair[0])
+		}
+	}
+	return
+}
+
+//
+// Return the number of distinct keys in the Mapping.
+//
+func (mapping Mapping) Len() int {
+	return len(mapping.Keys())
+}
+
+//
+// Return the key-value pairs of the Mapping as Go strings in serialized order, along with an
+// error if any pair is malformed, in which case only the readable pairs are returned.  An empty
+// Mapping has no pairs.
+//
+func (mapping Mapping) pairs() (pairs [][2]string, err error) {
+	mapping_len := len(mapping)
+	if mapping_len == 0 || (mapping_len == 2 && Integer(mapping[:2]) == 0) {
+		return
+	}
+	if mapping_len < 2 {
+		log.WithFields(log.Fields{
+			"at":           "(Mapping) pairs",
+			"data_len":     mapping_len,
+			"required_len": 2,
+			"reason":       "not enough data",
+		}).Error("error parsing mapping")
+		err = errors.New("error parsing mapping: not enough data")
+		return
+	}
+	values, errs := mapping.Values()
+	for _, value := range values {
+		key, key_err := value[0].Data()
+		val, val_err := value[1].Data()
+		if key_err != nil || val_err != nil {
+			errs = append(errs, errors.New("error parsing mapping: truncated string"))
+			continue
+		}
+		pairs = append(pairs, [2]string{key, val})
+	}
+	if len(errs) != 0 {
+		log.WithFields(log.Fields{
+			"at":     "(Mapping) pairs",
+			"reason": errs[0].Error(),
+		}).Error("error parsing mapping")
+		err = errors.New("error parsing mapping: invalid key-value pair")
+	}
+	return
+}
+
+//
+// Return the pairs whose key is not the provided key, preserving their order.
+//
+func pairsWithoutKey(pairs [][2]string, key string) (remaining [][2]string) {
+	for _, pair := range pairs {
+		if pair[0] != key {
+			remaining = append(remaining, pair)
+		}
+	}
+	return
+}
+
+//
+// Convert key-value pairs of unformatted strings to a sorted Mapping, keeping repeated keys.
+//
+func pairsToMapping(pairs [][2]string) (mapping Mapping, err error) {
+	map_vals := MappingValues{}
+	for _, pair := range pairs {
+		key_str, kerr := ToI2PString(pair[0])
+		if kerr != nil {
+			err = kerr
+			return
+		}
+		val_str, verr := ToI2PString(pair[1])
+		if verr != nil {
+			err = verr
+			return
+		}
+		map_vals = append(map_vals, [2]String{key_str, val_str})
+	}
+	mapping = ValuesToMapping(map_vals)
+	return
+}
+
 //
 // Convert a Go map of unformatted strings to a sorted Mapping.
 //
@@ -3,6 +3,7 @@ package common
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -185,3 +186,124 @@ func TestBeginsWithCorrectWhenNil(t *testing.T) {
 
 	assert.Equal(beginsWith(slice, 0x41), false, "beginsWith() did not return false on empty slice")
 }
+
+func TestMappingGetSetDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	var mapping Mapping
+	_, present := mapping.Get("a")
+	assert.False(present)
+	assert.Nil(mapping.Set("b", "2"))
+	assert.Nil(mapping.Set("a", "1"))
+	assert.Nil(mapping.Set("b", "3"))
+
+	value, present := mapping.Get("b")
+	assert.True(present)
+	assert.Equal("3", value)
+	assert.Equal([]string{"a", "b"}, mapping.Keys())
+	assert.Equal(2, mapping.Len())
+	expected, _ := GoMapToMapping(map[string]string{"a": "1", "b": "3"})
+	assert.Equal(expected, mapping)
+
+	assert.Nil(mapping.Delete("a"))
+	assert.Nil(mapping.Delete("missing"))
+	_, present = mapping.Get("a")
+	assert.False(present)
+	assert.Equal([]string{"b"}, mapping.Keys())
+	assert.Nil(mapping.Delete("b"))
+	assert.Equal(Mapping{0x00, 0x00}, mapping)
+	assert.Equal(0, mapping.Len())
+}
+
+func TestMappingSetRejectsLongStrings(t *testing.T) {
+	assert := assert.New(t)
+
+	var mapping Mapping
+	err := mapping.Set(string(make([]byte, 256)), "value")
+	assert.NotNil(err)
+	assert.Nil(mapping)
+}
+
+func TestMappingSetRejectsOversizedMapping(t *testing.T) {
+	assert := assert.New(t)
+
+	var mapping Mapping
+	value := string(bytes.Repeat([]byte("v"), 255))
+	var err error
+	for i := 0; i < 300 && err == nil; i++ {
+		err = mapping.Set(fmt.Sprintf("%03d", i), value)
+	}
+	if assert.NotNil(err) {
+		assert.Equal("cannot store that much data in mapping", err.Error())
+	}
+	assert.True(len(mapping) <= MAPPING_MAX_SIZE+2)
+}
+
+func TestMappingGetReturnsLastDuplicate(t *testing.T) {
+	assert := assert.New(t)
+
+	mapping := Mapping([]byte{0x00, 0x0c, 0x01, 0x61, 0x3d, 0x01, 0x62, 0x3b, 0x01, 0x61, 0x3d, 0x01, 0x63, 0x3b})
+	value, present := mapping.Get("a")
+	assert.True(present)
+	assert.Equal("c", value)
+	assert.Equal([]string{"a"}, mapping.Keys())
+}
+
+func TestMappingSetAndDeleteKeepDuplicates(t *testing.T) {
+	assert := assert.New(t)
+
+	mapping := Mapping([]byte{0x00, 0x0c, 0x01, 0x61, 0x3d, 0x01, 0x62, 0x3b, 0x01, 0x61, 0x3d, 0x01, 0x63, 0x3b})
+	assert.Nil(mapping.Set("d", "e"))
+	assert.Equal(Mapping([]byte{0x00, 0x12, 0x01, 0x61, 0x3d, 0x01, 0x62, 0x3b, 0x01, 0x61, 0x3d, 0x01, 0x63, 0x3b, 0x01, 0x64, 0x3d, 0x01, 0x65, 0x3b}), mapping)
+	assert.Nil(mapping.Delete("d"))
+	assert.Equal(Mapping([]byte{0x00, 0x0c, 0x01, 0x61, 0x3d, 0x01, 0x62, 0x3b, 0x01, 0x61, 0x3d, 0x01, 0x63, 0x3b}), mapping)
+	assert.Nil(mapping.Set("a", "f"))
+	assert.Equal(Mapping([]byte{0x00, 0x06, 0x01, 0x61, 0x3d, 0x01, 0x66, 0x3b}), mapping)
+}
+
+func TestMappingSetAndDeleteWithMalformedMapping(t *testing.T) {
+	assert := assert.New(t)
+
+	truncated := Mapping([]byte{0x00, 0x0c, 0x01, 0x61, 0x3d, 0x01, 0x62, 0x3b, 0x01, 0x63, 0x3d})
+	mapping := append(Mapping{}, truncated...)
+	err := mapping.Set("d", "e")
+	if assert.NotNil(err) {
+		assert.Equal("error parsing mapping: invalid key-value pair", err.Error())
+	}
+	err = mapping.Delete("a")
+	if assert.NotNil(err) {
+		assert.Equal("error parsing mapping: invalid key-value pair", err.Error())
+	}
+	assert.Equal(truncated, mapping)
+	value, present := mapping.Get("a")
+	assert.True(present)
+	assert.Equal("b", value)
+}
+
+func TestReadMappingStrict(t *testing.T) {
+	assert := assert.New(t)
+
+	valid, _ := GoMapToMapping(map[string]string{"a": "b"})
+	mapping, remainder, err := ReadMappingStrict(append(valid, 0x01))
+	assert.Nil(err)
+	assert.Equal(valid, mapping)
+	assert.Equal([]byte{0x01}, remainder)
+
+	mapping, _, err = ReadMappingStrict([]byte{0x00, 0x00})
+	assert.Nil(err)
+	assert.Equal(Mapping{0x00, 0x00}, mapping)
+
+	duplicates := []byte{0x00, 0x0c, 0x01, 0x61, 0x3d, 0x01, 0x62, 0x3b, 0x01, 0x61, 0x3d, 0x01, 0x63, 0x3b}
+	_, _, err = ReadMappingStrict(duplicates)
+	if assert.NotNil(err) {
+		assert.Equal("error parsing mapping: duplicate keys", err.Error())
+	}
+	mapping, _, err = ReadMapping(duplicates)
+	assert.Nil(err)
+	assert.Equal(Mapping(duplicates), mapping)
+
+	_, _, err = ReadMappingStrict([]byte{0x00, 0x06, 0x01, 0x61, 0x30, 0x01, 0x62, 0x3b})
+	if assert.NotNil(err) {
+		assert.Equal("error parsing mapping: invalid key-value pair", err.Error())
+	}
+}
@@ -0,0 +1,106 @@
+package common
+
+/*
+I2P MetaLease
+https://geti2p.net/spec/common-structures#metalease
+Accurate for version 0.9.38
+
++----+----+----+----+----+----+----+----+
+| tunnel_gw                             |
++                                       +
+|                                       |
++                                       +
+|                                       |
++                                       +
+|                                       |
++----+----+----+----+----+----+----+----+
+|    flags     |cost|      end_date     |
++----+----+----+----+----+----+----+----+
+
+tunnel_gw :: Hash of the LeaseSet, or of the RouterIdentity of the tunnel gateway
+             length -> 32 bytes
+
+flags :: 3 bytes
+         Bit order: 23 22 ... 3 2 1 0
+         Bits 3-0: Type of the entry
+                   0: Unknown
+                   1: LeaseSet
+                   3: LeaseSet2
+                   5: EncryptedLeaseSet
+                   7: MetaLeaseSet
+         Bits 23-4: set to 0 for compatibility with future uses
+
+cost :: 1 byte, 0-255.  Lower numbers are higher priority.
+
+end_date :: 4 byte date
+            length -> 4 bytes
+            Seconds since the epoch, rolls over in 2106.
+*/
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Sizes or various components of a MetaLease
+const (
+	META_LEASE_SIZE          = 40
+	META_LEASE_FLAGS_SIZE    = 3
+	META_LEASE_COST_SIZE     = 1
+	META_LEASE_END_DATE_SIZE = 4
+)
+
+// Types of entries referenced by a MetaLease
+const (
+	META_LEASE_TYPE_UNKNOWN    = 0
+	META_LEASE_TYPE_LEASE_SET  = 1
+	META_LEASE_TYPE_LEASE_SET2 = LEASE_SET2_TYPE
+	META_LEASE_TYPE_ENCRYPTED  = ENCRYPTED_LEASE_SET_TYPE
+	META_LEASE_TYPE_META       = META_LEASE_SET_TYPE
+	META_LEASE_TYPE_MASK       = 0x0f
+)
+
+type MetaLease [META_LEASE_SIZE]byte
+
+//
+// Create a MetaLease referencing the entry with the provided Hash, with the type of the entry,
+// a cost where lower numbers are preferred, and the time the MetaLease expires, truncated to
+// seconds.
+//
+func NewMetaLease(hash Hash, entry_type int, cost uint8, expiration time.Time) (lease MetaLease) {
+	copy(lease[:LEASE_HASH_SIZE], hash[:])
+	lease[LEASE_HASH_SIZE+META_LEASE_FLAGS_SIZE-1] = byte(entry_type & META_LEASE_TYPE_MASK)
+	lease[LEASE_HASH_SIZE+META_LEASE_FLAGS_SIZE] = cost
+	binary.BigEndian.PutUint32(lease[META_LEASE_SIZE-META_LEASE_END_DATE_SIZE:], uint32(expiration.Unix()))
+	return
+}
+
+//
+// Return the first 32 bytes of the MetaLease as a Hash.
+//
+func (lease MetaLease) Hash() (hash Hash) {
+	copy(hash[:], lease[:LEASE_HASH_SIZE])
+	return
+}
+
+//
+// Return the type of the entry referenced by the MetaLease.
+//
+func (lease MetaLease) Type() int {
+	flags := Integer(lease[LEASE_HASH_SIZE : LEASE_HASH_SIZE+META_LEASE_FLAGS_SIZE])
+	return flags & META_LEASE_TYPE_MASK
+}
+
+//
+// Return the cost of the MetaLease, lower costs are preferred.
+//
+func (lease MetaLease) Cost() int {
+	return int(lease[LEASE_HASH_SIZE+META_LEASE_FLAGS_SIZE])
+}
+
+//
+// Return the end date of the MetaLease as a Date.
+//
+func (lease MetaLease) Date() Date {
+	return dateFromSeconds(lease[META_LEASE_SIZE-META_LEASE_END_DATE_SIZE:])
+}
@@ -0,0 +1,399 @@
+package common
+
+/*
+I2P MetaLeaseSet
+https://geti2p.net/spec/common-structures#metaleaseset
+Accurate for version 0.9.38
+
++----+----+----+----+----+----+----+----+
+|         ls2_header                    |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+|          options                      |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+| num| MetaLease 0                      |
++----+                                  +
+|                                       |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+| MetaLease($num-1)                     |
++                                       +
+|                                       |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+|numr|                                  |
++----+                                  +
+|          revocation_0                 |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+|          revocation_n                 |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+| signature                             |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+
+ls2header :: LeaseSet2Header
+             length -> varies
+
+options :: Mapping
+           length -> varies, 2 bytes minimum
+
+num :: Integer
+       length -> 1 byte
+       Number of MetaLeases to follow
+       value: 1 <= num <= max TBD
+
+leases :: MetaLeases
+          length -> $num*40 bytes
+
+numr :: Integer
+        length -> 1 byte
+        Number of Hashes to follow
+        value: 0 <= numr <= max TBD
+
+revocations :: [Hash]
+               length -> $numr*32 bytes
+
+signature :: Signature
+             length -> 40+ bytes
+             The length is as implied by the signature type of the Destination, or
+             of the transient key if offline keys are used.
+             The signature is over the data above, prepended with the single byte
+             containing the DatabaseStore type (7).
+*/
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/hkparker/go-i2p/lib/crypto"
+)
+
+// DatabaseStore type of a MetaLeaseSet, prepended to the data when signing
+const (
+	META_LEASE_SET_TYPE = 7
+)
+
+// Sizes and limits of the entries in a MetaLeaseSet
+const (
+	META_LEASE_SET_REVOCATION_SIZE = 32
+	META_LEASE_SET_MAX_LEASES      = 255
+	META_LEASE_SET_MAX_REVOCATIONS = 255
+)
+
+type MetaLeaseSet []byte
+
+//
+// Build a MetaLeaseSet from a LeaseSet2Header, an options Mapping, between 1 and 255 MetaLeases
+// referencing the LeaseSets of each instance of the Destination, and up to 255 Hashes of revoked
+// entries, signed with the provided Signer.  The Signer must belong to the Destination's
+// SigningPublicKey, or to the transient key if the header contains a valid OfflineSignature.
+//
+func NewMetaLeaseSet(header LeaseSet2Header, options Mapping, leases []MetaLease, revocations []Hash, signer crypto.Signer) (meta_lease_set MetaLeaseSet, err error) {
+	header, _, err = ReadLeaseSet2Header(header)
+	if err != nil {
+		return
+	}
+	if _, err = header.NewVerifier(); err != nil {
+		return
+	}
+	lease_count := len(leases)
+	if lease_count == 0 || lease_count > META_LEASE_SET_MAX_LEASES {
+		log.WithFields(log.Fields{
+			"at":          "NewMetaLeaseSet",
+			"lease_count": lease_count,
+			"reason":      "must have between 1 and 255 meta leases",
+		}).Error("error building meta lease set")
+		err = errors.New("error building meta lease set: invalid number of meta leases")
+		return
+	}
+	revocation_count := len(revocations)
+	if revocation_count > META_LEASE_SET_MAX_REVOCATIONS {
+		log.WithFields(log.Fields{
+			"at":               "NewMetaLeaseSet",
+			"revocation_count": revocation_count,
+			"reason":           "more than 255 revocations",
+		}).Error("error building meta lease set")
+		err = errors.New("error building meta lease set: more than 255 revocations")
+		return
+	}
+	if len(options) < 2 {
+		options = ValuesToMapping(MappingValues{})
+	}
+	data := make([]byte, 0)
+	data = append(data, header...)
+	data = append(data, options...)
+	data = append(data, byte(lease_count))
+	for _, lease := range leases {
+		data = append(data, lease[:]...)
+	}
+	data = append(data, byte(revocation_count))
+	for _, revocation := range revocations {
+		data = append(data, revocation[:]...)
+	}
+	signature, err := signer.Sign(append([]byte{META_LEASE_SET_TYPE}, data...))
+	if err != nil {
+		return
+	}
+	sig_size, err := header.SignatureSize()
+	if err != nil {
+		return
+	}
+	if len(signature) != sig_size {
+		log.WithFields(log.Fields{
+			"at":           "NewMetaLeaseSet",
+			"sig_len":      len(signature),
+			"required_len": sig_size,
+			"reason":       "signature size does not match signing key",
+		}).Error("error building meta lease set")
+		err = errors.New("error building meta lease set: signature size does not match signing key")
+		return
+	}
+	meta_lease_set = MetaLeaseSet(append(data, signature...))
+	if verify_err := meta_lease_set.Verify(); verify_err != nil {
+		log.WithFields(log.Fields{
+			"at":     "NewMetaLeaseSet",
+			"reason": "signer does not match signing key",
+		}).Error("error building meta lease set")
+		err = errors.New("error building meta lease set: signer does not match signing key")
+		meta_lease_set = nil
+	}
+	return
+}
+
+//
+// Return the LeaseSet2Header at the beginning of the MetaLeaseSet.
+//
+func (meta_lease_set MetaLeaseSet) Header() (header LeaseSet2Header, err error) {
+	header, _, err = ReadLeaseSet2Header(meta_lease_set)
+	return
+}
+
+//
+// Read a Destination from the MetaLeaseSet.
+//
+func (meta_lease_set MetaLeaseSet) Destination() (destination Destination, err error) {
+	destination, _, err = ReadDestination(meta_lease_set)
+	return
+}
+
+//
+// Return the Date the MetaLeaseSet was published.
+//
+func (meta_lease_set MetaLeaseSet) Published() (Date, error) {
+	return LeaseSet2Header(meta_lease_set).Published()
+}
+
+//
+// Return the Date the MetaLeaseSet expires.
+//
+func (meta_lease_set MetaLeaseSet) Expires() (Date, error) {
+	return LeaseSet2Header(meta_lease_set).Expires()
+}
+
+//
+// Return the options Mapping in the MetaLeaseSet.
+//
+func (meta_lease_set MetaLeaseSet) Options() (options Mapping, err error) {
+	_, remainder, err := ReadLeaseSet2Header(meta_lease_set)
+	if err != nil {
+		return
+	}
+	options, _, err = ReadMapping(remainder)
+	return
+}
+
+//
+// Return the number of MetaLeases in the MetaLeaseSet.
+//
+func (meta_lease_set MetaLeaseSet) LeaseCount() (count int, err error) {
+	remainder, err := meta_lease_set.leaseData()
+	if err != nil {
+		return
+	}
+	count = Integer([]byte{remainder[0]})
+	return
+}
+
+//
+// Read the MetaLeases in this MetaLeaseSet, returning a partial set if there is insufficient data.
+//
+func (meta_lease_set MetaLeaseSet) Leases() (leases []MetaLease, err error) {
+	remainder, err := meta_lease_set.leaseData()
+	if err != nil {
+		return
+	}
+	count := Integer([]byte{remainder[0]})
+	remainder = remainder[1:]
+	for i := 0; i < count; i++ {
+		if len(remainder) < META_LEASE_SIZE {
+			log.WithFields(log.Fields{
+				"at":           "(MetaLeaseSet) Leases",
+				"data_len":     len(remainder),
+				"required_len": META_LEASE_SIZE,
+				"reason":       "some meta leases missing",
+			}).Error("error parsing meta lease set")
+			err = errors.New("error parsing meta lease set: some meta leases missing")
+			return
+		}
+		var lease MetaLease
+		copy(lease[:], remainder[:META_LEASE_SIZE])
+		leases = append(leases, lease)
+		remainder = remainder[META_LEASE_SIZE:]
+	}
+	return
+}
+
+//
+// Read the Hashes of the entries revoked by this MetaLeaseSet, returning a partial set if there
+// is insufficient data.
+//
+func (meta_lease_set MetaLeaseSet) Revocations() (revocations []Hash, err error) {
+	remainder, err := meta_lease_set.revocationData()
+	if err != nil {
+		return
+	}
+	count := Integer([]byte{remainder[0]})
+	remainder = remainder[1:]
+	for i := 0; i < count; i++ {
+		if len(remainder) < META_LEASE_SET_REVOCATION_SIZE {
+			log.WithFields(log.Fields{
+				"at":           "(MetaLeaseSet) Revocations",
+				"data_len":     len(remainder),
+				"required_len": META_LEASE_SET_REVOCATION_SIZE,
+				"reason":       "some revocations missing",
+			}).Error("error parsing meta lease set")
+			err = errors.New("error parsing meta lease set: some revocations missing")
+			return
+		}
+		var revocation Hash
+		copy(revocation[:], remainder[:META_LEASE_SET_REVOCATION_SIZE])
+		revocations = append(revocations, revocation)
+		remainder = remainder[META_LEASE_SET_REVOCATION_SIZE:]
+	}
+	return
+}
+
+//
+// Return the Signature following the revocations, sized as implied by the Destination's signing
+// key type or the transient key type if offline keys are used.
+//
+func (meta_lease_set MetaLeaseSet) Signature() (signature Signature, err error) {
+	start, err := meta_lease_set.signatureOffset()
+	if err != nil {
+		return
+	}
+	sig_size, err := LeaseSet2Header(meta_lease_set).SignatureSize()
+	if err != nil {
+		return
+	}
+	end := start + sig_size
+	meta_lease_set_len := len(meta_lease_set)
+	if meta_lease_set_len < end {
+		log.WithFields(log.Fields{
+			"at":           "(MetaLeaseSet) Signature",
+			"data_len":     meta_lease_set_len,
+			"required_len": end,
+			"reason":       "not enough data",
+		}).Error("error parsing signature")
+		err = errors.New("error parsing signature: not enough data")
+		return
+	}
+	signature = Signature(meta_lease_set[start:end])
+	return
+}
+
+//
+// Verify the Signature of this MetaLeaseSet against the Destination's SigningPublicKey, or against
+// the transient key after verifying its OfflineSignature if offline keys are used.  Returns nil
+// if the signature is valid, crypto.ErrInvalidSignature if it does not match, or any errors
+// encountered parsing the MetaLeaseSet.
+//
+func (meta_lease_set MetaLeaseSet) Verify() (err error) {
+	signature, err := meta_lease_set.Signature()
+	if err != nil {
+		return
+	}
+	data_end, _ := meta_lease_set.signatureOffset()
+	verifier, err := LeaseSet2Header(meta_lease_set).NewVerifier()
+	if err != nil {
+		return
+	}
+	err = verifier.Verify(append([]byte{META_LEASE_SET_TYPE}, meta_lease_set[:data_end]...), signature)
+	return
+}
+
+//
+// Return the data following the options Mapping, beginning with the MetaLease count.
+//
+func (meta_lease_set MetaLeaseSet) leaseData() (remainder []byte, err error) {
+	_, remainder, err = ReadLeaseSet2Header(meta_lease_set)
+	if err != nil {
+		return
+	}
+	_, remainder, err = ReadMapping(remainder)
+	if err != nil {
+		return
+	}
+	if len(remainder) < 1 {
+		log.WithFields(log.Fields{
+			"at":           "(MetaLeaseSet) leaseData",
+			"data_len":     len(remainder),
+			"required_len": 1,
+			"reason":       "not enough data",
+		}).Error("error parsing lease count")
+		err = errors.New("error parsing lease count: not enough data")
+	}
+	return
+}
+
+//
+// Return the data following the MetaLeases, beginning with the revocation count.
+//
+func (meta_lease_set MetaLeaseSet) revocationData() (remainder []byte, err error) {
+	remainder, err = meta_lease_set.leaseData()
+	if err != nil {
+		return
+	}
+	start := 1 + Integer([]byte{remainder[0]})*META_LEASE_SIZE
+	if len(remainder) < start+1 {
+		log.WithFields(log.Fields{
+			"at":           "(MetaLeaseSet) revocationData",
+			"data_len":     len(remainder),
+			"required_len": start + 1,
+			"reason":       "not enough data",
+		}).Error("error parsing revocation count")
+		err = errors.New("error parsing revocation count: not enough data")
+		return
+	}
+	remainder = remainder[start:]
+	return
+}
+
+//
+// Return the offset of the Signature in this MetaLeaseSet, which is also the length of the signed
+// data excluding the DatabaseStore type.
+//
+func (meta_lease_set MetaLeaseSet) signatureOffset() (offset int, err error) {
+	remainder, err := meta_lease_set.revocationData()
+	if err != nil {
+		return
+	}
+	offset = len(meta_lease_set) - len(remainder) + 1 + Integer([]byte{remainder[0]})*META_LEASE_SET_REVOCATION_SIZE
+	return
+}
@@ -0,0 +1,147 @@
+package common
+
+import (
+	"github.com/hkparker/go-i2p/lib/crypto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func buildMetaLeases() []MetaLease {
+	return []MetaLease{
+		NewMetaLease(Hash{0x01}, META_LEASE_TYPE_LEASE_SET2, 0, time.Unix(1500000600, 0)),
+		NewMetaLease(Hash{0x02}, META_LEASE_TYPE_LEASE_SET2, 5, time.Unix(1500000660, 0)),
+		NewMetaLease(Hash{0x03}, META_LEASE_TYPE_ENCRYPTED, 10, time.Unix(1500000720, 0)),
+	}
+}
+
+func TestMetaLeaseSetRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, err := buildEd25519Destination()
+	if !assert.Nil(err) {
+		return
+	}
+	published := time.Unix(1500000000, 0)
+	header, err := NewLeaseSet2Header(destination, published, published.Add(10*time.Minute), 0, nil)
+	if !assert.Nil(err) {
+		return
+	}
+	revocations := []Hash{{0x04}, {0x05}}
+	meta_lease_set, err := NewMetaLeaseSet(header, buildMapping(), buildMetaLeases(), revocations, signer)
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Nil(meta_lease_set.Verify())
+	parsed_header, err := meta_lease_set.Header()
+	assert.Nil(err)
+	assert.Equal(header, parsed_header)
+	parsed_destination, err := meta_lease_set.Destination()
+	assert.Nil(err)
+	assert.Equal(destination, parsed_destination)
+	published_date, _ := meta_lease_set.Published()
+	assert.Equal(published, published_date.Time())
+	expires, _ := meta_lease_set.Expires()
+	assert.Equal(published.Add(10*time.Minute), expires.Time())
+	options, err := meta_lease_set.Options()
+	assert.Nil(err)
+	assert.Equal(buildMapping(), options)
+	count, err := meta_lease_set.LeaseCount()
+	assert.Nil(err)
+	assert.Equal(3, count)
+	leases, err := meta_lease_set.Leases()
+	assert.Nil(err)
+	assert.Equal(buildMetaLeases(), leases)
+	parsed_revocations, err := meta_lease_set.Revocations()
+	assert.Nil(err)
+	assert.Equal(revocations, parsed_revocations)
+	signature, err := meta_lease_set.Signature()
+	assert.Nil(err)
+	assert.Equal(64, len(signature))
+}
+
+func TestMetaLeaseSetVerifyReturnsErrorWithForgedData(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	header, _ := NewLeaseSet2Header(destination, published, published.Add(10*time.Minute), 0, nil)
+	meta_lease_set, err := NewMetaLeaseSet(header, nil, buildMetaLeases(), nil, signer)
+	if !assert.Nil(err) {
+		return
+	}
+
+	meta_lease_set[len(header)+5] ^= 0xff
+	assert.Equal(crypto.ErrInvalidSignature, meta_lease_set.Verify())
+}
+
+func TestMetaLeaseSetWithOfflineKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	offline_signature, transient_signer, err := buildOfflineSignature(signer, published.Add(time.Hour))
+	if !assert.Nil(err) {
+		return
+	}
+	header, _ := NewLeaseSet2Header(destination, published, published.Add(10*time.Minute), 0, offline_signature)
+	meta_lease_set, err := NewMetaLeaseSet(header, nil, buildMetaLeases(), nil, transient_signer)
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Nil(meta_lease_set.Verify())
+	revocations, err := meta_lease_set.Revocations()
+	assert.Nil(err)
+	assert.Equal(0, len(revocations))
+}
+
+func TestNewMetaLeaseSetWithSignerForAnotherKey(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	offline_signature, _, err := buildOfflineSignature(signer, published.Add(time.Hour))
+	if !assert.Nil(err) {
+		return
+	}
+	header, _ := NewLeaseSet2Header(destination, published, published.Add(10*time.Minute), 0, offline_signature)
+	meta_lease_set, err := NewMetaLeaseSet(header, nil, buildMetaLeases(), nil, signer)
+	if assert.NotNil(err, "meta lease set signed by the long-term key instead of the transient key") {
+		assert.Equal("error building meta lease set: signer does not match signing key", err.Error())
+	}
+	assert.Nil(meta_lease_set)
+}
+
+func TestNewMetaLeaseSetRequiresLeases(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	header, _ := NewLeaseSet2Header(destination, published, published.Add(10*time.Minute), 0, nil)
+	_, err := NewMetaLeaseSet(header, nil, nil, nil, signer)
+	if assert.NotNil(err) {
+		assert.Equal("error building meta lease set: invalid number of meta leases", err.Error())
+	}
+}
+
+func TestMetaLeaseSetSignatureWithTruncatedData(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	published := time.Unix(1500000000, 0)
+	header, _ := NewLeaseSet2Header(destination, published, published.Add(10*time.Minute), 0, nil)
+	meta_lease_set, _ := NewMetaLeaseSet(header, nil, buildMetaLeases(), []Hash{{0x04}}, signer)
+
+	truncated := meta_lease_set[:len(meta_lease_set)-10]
+	_, err := truncated.Signature()
+	if assert.NotNil(err) {
+		assert.Equal("error parsing signature: not enough data", err.Error())
+	}
+	truncated = meta_lease_set[:len(meta_lease_set)-64-10]
+	_, err = truncated.Revocations()
+	if assert.NotNil(err) {
+		assert.Equal("error parsing meta lease set: some revocations missing", err.Error())
+	}
+}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewMetaLeaseHasCorrectFields(t *testing.T) {
+	assert := assert.New(t)
+
+	var hash Hash
+	for i := range hash {
+		hash[i] = byte(i)
+	}
+	expiration := time.Unix(1500000000, 0)
+	lease := NewMetaLease(hash, META_LEASE_TYPE_LEASE_SET2, 10, expiration)
+
+	assert.Equal(hash, lease.Hash())
+	assert.Equal(META_LEASE_TYPE_LEASE_SET2, lease.Type())
+	assert.Equal(10, lease.Cost())
+	assert.Equal(expiration.Unix(), lease.Date().Time().Unix())
+	assert.Equal([]byte{0x00, 0x00, 0x03, 0x0a}, lease[32:36])
+}
+
+func TestMetaLeaseTypeIgnoresUnknownFlags(t *testing.T) {
+	assert := assert.New(t)
+
+	lease := NewMetaLease(Hash{}, META_LEASE_TYPE_META, 0, time.Unix(1500000000, 0))
+	lease[33] = 0xff
+	lease[34] |= 0xf0
+
+	assert.Equal(META_LEASE_TYPE_META, lease.Type())
+}
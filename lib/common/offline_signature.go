@@ -0,0 +1,285 @@
+package common
+
+/*
+I2P OfflineSignature
+https://geti2p.net/spec/common-structures#offlinesignature
+Accurate for version 0.9.38
+
++----+----+----+----+----+----+----+----+
+|     expires       | sigtype |         |
++----+----+----+----+----+----+         +
+|       transient_public_key            |
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+|           signature                   |
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+
+expires :: 4 byte date
+           length -> 4 bytes
+           Seconds since the epoch, rolls over in 2106.
+
+sigtype :: 2 byte type of the transient_public_key
+           length -> 2 bytes
+
+transient_public_key :: SigningPublicKey
+                        length -> As inferred from the sigtype
+
+signature :: Signature
+             length -> As inferred from the sigtype of the signing public key
+                       in the Destination that preceded this offline signature.
+             Signature of expires timestamp, transient sig type, and public key,
+             by the destination public key.
+*/
+
+import (
+	"encoding/binary"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/hkparker/go-i2p/lib/crypto"
+	"time"
+)
+
+// Sizes of the fixed fields in an OfflineSignature
+const (
+	OFFLINE_SIGNATURE_EXPIRES_SIZE = 4
+	OFFLINE_SIGNATURE_SIGTYPE_SIZE = 2
+	OFFLINE_SIGNATURE_MIN_SIZE     = OFFLINE_SIGNATURE_EXPIRES_SIZE + OFFLINE_SIGNATURE_SIGTYPE_SIZE
+)
+
+type OfflineSignature []byte
+
+//
+// Build an OfflineSignature certifying a transient SigningPublicKey of the provided Signing Key
+// Type until the expiration, truncated to seconds.  The Signer belongs to the long-term key of
+// the provided Signing Key Type, which can then be kept offline while the transient key signs.
+//
+func NewOfflineSignature(signing_key_type int, signer crypto.Signer, expires time.Time, transient_key_type int, transient_public_key crypto.SigningPublicKey) (offline_signature OfflineSignature, err error) {
+	key_size := newKeyCertificate(transient_key_type, KEYCERT_CRYPTO_ELG, nil).SigningPublicKeySize()
+	if key_size == 0 || transient_public_key == nil || transient_public_key.Len() != key_size {
+		log.WithFields(log.Fields{
+			"at":                 "NewOfflineSignature",
+			"transient_key_type": transient_key_type,
+			"reason":             "transient key does not match transient key type",
+		}).Error("error building offline signature")
+		err = errors.New("error building offline signature: transient key does not match transient key type")
+		return
+	}
+	expires_seconds := expires.Unix()
+	if expires_seconds < 0 || expires_seconds > 0xffffffff {
+		log.WithFields(log.Fields{
+			"at":      "NewOfflineSignature",
+			"expires": expires,
+			"reason":  "expiration out of range",
+		}).Error("error building offline signature")
+		err = errors.New("error building offline signature: expiration out of range")
+		return
+	}
+	data := make([]byte, OFFLINE_SIGNATURE_MIN_SIZE)
+	binary.BigEndian.PutUint32(data[:OFFLINE_SIGNATURE_EXPIRES_SIZE], uint32(expires_seconds))
+	binary.BigEndian.PutUint16(data[OFFLINE_SIGNATURE_EXPIRES_SIZE:], uint16(transient_key_type))
+	data = append(data, transient_public_key.Bytes()...)
+	signature, err := signer.Sign(data)
+	if err != nil {
+		return
+	}
+	if len(signature) != SignatureSizeByType(signing_key_type) {
+		log.WithFields(log.Fields{
+			"at":               "NewOfflineSignature",
+			"signature_len":    len(signature),
+			"required_len":     SignatureSizeByType(signing_key_type),
+			"signing_key_type": signing_key_type,
+			"reason":           "signature size does not match signing key",
+		}).Error("error building offline signature")
+		err = errors.New("error building offline signature: signature size does not match signing key")
+		return
+	}
+	offline_signature = OfflineSignature(append(data, signature...))
+	return
+}
+
+//
+// Build an OfflineSignature with the Destination's long-term Signer, certifying the public half
+// of a transient SigningPrivateKey until the expiration.  The transient private key and the
+// OfflineSignature are all a live router needs to sign structures for the Destination.
+//
+func (destination Destination) NewOfflineSignature(signer crypto.Signer, expires time.Time, transient_key_type int, transient_private_key crypto.SigningPrivateKey) (offline_signature OfflineSignature, err error) {
+	signing_key_type, err := KeysAndCert(destination).signingPublicKeyType()
+	if err != nil {
+		return
+	}
+	transient_public_key, err := transient_private_key.Public()
+	if err != nil {
+		return
+	}
+	offline_signature, err = NewOfflineSignature(signing_key_type, signer, expires, transient_key_type, transient_public_key)
+	return
+}
+
+//
+// Return the Date the transient key in this OfflineSignature expires and any errors
+// encountered parsing the OfflineSignature.
+//
+func (offline_signature OfflineSignature) Expires() (date Date, err error) {
+	err = offline_signature.checkValid()
+	if err != nil {
+		return
+	}
+	date = dateFromSeconds(offline_signature[:OFFLINE_SIGNATURE_EXPIRES_SIZE])
+	return
+}
+
+//
+// Return the Signing Key Type of the transient key in this OfflineSignature.
+//
+func (offline_signature OfflineSignature) TransientSigningPublicKeyType() (signing_key_type int, err error) {
+	err = offline_signature.checkValid()
+	if err != nil {
+		return
+	}
+	signing_key_type = Integer(offline_signature[OFFLINE_SIGNATURE_EXPIRES_SIZE:OFFLINE_SIGNATURE_MIN_SIZE])
+	return
+}
+
+//
+// Return the transient SigningPublicKey certified by this OfflineSignature.
+//
+func (offline_signature OfflineSignature) TransientSigningPublicKey() (signing_public_key crypto.SigningPublicKey, err error) {
+	end, err := offline_signature.transientKeyEnd()
+	if err != nil {
+		return
+	}
+	signing_key_type, _ := offline_signature.TransientSigningPublicKeyType()
+	signing_public_key, err = ConstructSigningPublicKeyByType(
+		signing_key_type,
+		offline_signature[OFFLINE_SIGNATURE_MIN_SIZE:end],
+	)
+	return
+}
+
+//
+// Return the Signature of the long-term key over the expiration, transient key type and
+// transient key.
+//
+func (offline_signature OfflineSignature) Signature() (signature Signature, err error) {
+	end, err := offline_signature.transientKeyEnd()
+	if err != nil {
+		return
+	}
+	signature = Signature(offline_signature[end:])
+	return
+}
+
+//
+// Verify that this OfflineSignature was made by the long-term SigningPublicKey provided,
+// returning nil if the signature is valid.
+//
+func (offline_signature OfflineSignature) Verify(signing_public_key crypto.SigningPublicKey) (err error) {
+	end, err := offline_signature.transientKeyEnd()
+	if err != nil {
+		return
+	}
+	verifier, err := signing_public_key.NewVerifier()
+	if err != nil {
+		return
+	}
+	err = verifier.Verify(offline_signature[:end], offline_signature[end:])
+	return
+}
+
+//
+// Return true if the transient key certified by this OfflineSignature has expired at the time
+// provided, or if the OfflineSignature cannot be parsed.
+//
+func (offline_signature OfflineSignature) IsExpired(now time.Time) bool {
+	expires, err := offline_signature.Expires()
+	return err != nil || expires.Time().Before(now)
+}
+
+//
+// Read an OfflineSignature from a slice of bytes, using the Signing Key Type of the long-term key
+// that made the signature to determine its length.  Returns the remaining data and any errors
+// encountered parsing the OfflineSignature.
+//
+func ReadOfflineSignature(data []byte, signing_key_type int) (offline_signature OfflineSignature, remainder []byte, err error) {
+	test_signature := OfflineSignature(data)
+	end, err := test_signature.transientKeyEnd()
+	if err != nil {
+		return
+	}
+	sig_size := SignatureSizeByType(signing_key_type)
+	if sig_size == 0 {
+		log.WithFields(log.Fields{
+			"at":               "ReadOfflineSignature",
+			"signing_key_type": signing_key_type,
+			"reason":           "unknown signing key type",
+		}).Error("error parsing offline signature")
+		err = errors.New("error parsing offline signature: unknown signing key type")
+		return
+	}
+	data_len := len(data)
+	if data_len < end+sig_size {
+		log.WithFields(log.Fields{
+			"at":           "ReadOfflineSignature",
+			"data_len":     data_len,
+			"required_len": end + sig_size,
+			"reason":       "not enough data",
+		}).Error("error parsing offline signature")
+		err = errors.New("error parsing offline signature: not enough data")
+		return
+	}
+	offline_signature = OfflineSignature(data[:end+sig_size])
+	remainder = data[end+sig_size:]
+	return
+}
+
+//
+// Used during parsing to find the end of the transient key, which is where the Signature begins.
+//
+func (offline_signature OfflineSignature) transientKeyEnd() (end int, err error) {
+	signing_key_type, err := offline_signature.TransientSigningPublicKeyType()
+	if err != nil {
+		return
+	}
+	key_size := newKeyCertificate(signing_key_type, KEYCERT_CRYPTO_ELG, nil).SigningPublicKeySize()
+	if key_size == 0 {
+		log.WithFields(log.Fields{
+			"at":               "(OfflineSignature) transientKeyEnd",
+			"signing_key_type": signing_key_type,
+			"reason":           "unknown transient signing key type",
+		}).Error("error parsing offline signature")
+		err = errors.New("error parsing offline signature: unknown transient signing key type")
+		return
+	}
+	end = OFFLINE_SIGNATURE_MIN_SIZE + key_size
+	offline_signature_len := len(offline_signature)
+	if offline_signature_len < end {
+		log.WithFields(log.Fields{
+			"at":           "(OfflineSignature) transientKeyEnd",
+			"data_len":     offline_signature_len,
+			"required_len": end,
+			"reason":       "not enough data",
+		}).Error("error parsing offline signature")
+		err = errors.New("error parsing offline signature: not enough data")
+	}
+	return
+}
+
+//
+// Check if the OfflineSignature is too small to contain the expiration and key type.
+//
+func (offline_signature OfflineSignature) checkValid() (err error) {
+	offline_signature_len := len(offline_signature)
+	if offline_signature_len < OFFLINE_SIGNATURE_MIN_SIZE {
+		log.WithFields(log.Fields{
+			"at":           "(OfflineSignature) checkValid",
+			"data_len":     offline_signature_len,
+			"required_len": OFFLINE_SIGNATURE_MIN_SIZE,
+			"reason":       "not enough data",
+		}).Error("error parsing offline signature")
+		err = errors.New("error parsing offline signature: not enough data")
+	}
+	return
+}
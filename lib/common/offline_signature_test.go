@@ -0,0 +1,116 @@
+package common
+
+import (
+	"github.com/hkparker/go-i2p/lib/crypto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewOfflineSignatureHasCorrectFields(t *testing.T) {
+	assert := assert.New(t)
+
+	_, signer, _ := buildEd25519Destination()
+	transient_private_key, _ := testEd25519Signer(nil).Generate()
+	transient_public_key, _ := transient_private_key.Public()
+	expires := time.Unix(1500000000, 0)
+	offline_signature, err := NewOfflineSignature(KEYCERT_SIGN_ED25519, signer, expires, KEYCERT_SIGN_ED25519, transient_public_key)
+	if !assert.Nil(err) {
+		return
+	}
+
+	date, err := offline_signature.Expires()
+	assert.Nil(err)
+	assert.Equal(expires, date.Time())
+	transient_key_type, err := offline_signature.TransientSigningPublicKeyType()
+	assert.Nil(err)
+	assert.Equal(KEYCERT_SIGN_ED25519, transient_key_type)
+	parsed_key, err := offline_signature.TransientSigningPublicKey()
+	assert.Nil(err)
+	assert.Equal(transient_public_key.Bytes(), parsed_key.Bytes())
+	signature, err := offline_signature.Signature()
+	assert.Nil(err)
+	assert.Equal(64, len(signature))
+	assert.Equal(OFFLINE_SIGNATURE_MIN_SIZE+32+64, len(offline_signature))
+}
+
+func TestDestinationNewOfflineSignatureVerifies(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, signer, _ := buildEd25519Destination()
+	transient_private_key, _ := testEd25519Signer(nil).Generate()
+	offline_signature, err := destination.NewOfflineSignature(signer, time.Unix(1500000000, 0), KEYCERT_SIGN_ED25519, transient_private_key)
+	if !assert.Nil(err) {
+		return
+	}
+
+	signing_public_key, _ := destination.SigningPublicKey()
+	assert.Nil(offline_signature.Verify(signing_public_key))
+	other_destination, _, _ := buildEd25519Destination()
+	other_public_key, _ := other_destination.SigningPublicKey()
+	assert.Equal(crypto.ErrInvalidSignature, offline_signature.Verify(other_public_key))
+}
+
+func TestOfflineSignatureWithDSALongTermKey(t *testing.T) {
+	assert := assert.New(t)
+
+	destination, private_key, err := buildDSADestination()
+	if !assert.Nil(err) {
+		return
+	}
+	signer, _ := private_key.NewSigner()
+	transient_private_key, _ := testEd25519Signer(nil).Generate()
+	offline_signature, err := destination.NewOfflineSignature(signer, time.Unix(1500000000, 0), KEYCERT_SIGN_ED25519, transient_private_key)
+	if !assert.Nil(err) {
+		return
+	}
+
+	signature, _ := offline_signature.Signature()
+	assert.Equal(40, len(signature))
+	parsed, remainder, err := ReadOfflineSignature(append(offline_signature, 0x01), KEYCERT_SIGN_DSA_SHA1)
+	assert.Nil(err)
+	assert.Equal(offline_signature, parsed)
+	assert.Equal([]byte{0x01}, remainder)
+	signing_public_key, _ := destination.SigningPublicKey()
+	assert.Nil(offline_signature.Verify(signing_public_key))
+}
+
+func TestNewOfflineSignatureWithMismatchedTransientKey(t *testing.T) {
+	assert := assert.New(t)
+
+	_, signer, _ := buildEd25519Destination()
+	transient_private_key, _ := testEd25519Signer(nil).Generate()
+	transient_public_key, _ := transient_private_key.Public()
+	_, err := NewOfflineSignature(KEYCERT_SIGN_ED25519, signer, time.Unix(1500000000, 0), KEYCERT_SIGN_P256, transient_public_key)
+	if assert.NotNil(err) {
+		assert.Equal("error building offline signature: transient key does not match transient key type", err.Error())
+	}
+}
+
+func TestNewOfflineSignatureWithMismatchedSigner(t *testing.T) {
+	assert := assert.New(t)
+
+	_, signer, _ := buildEd25519Destination()
+	transient_private_key, _ := testEd25519Signer(nil).Generate()
+	transient_public_key, _ := transient_private_key.Public()
+	_, err := NewOfflineSignature(KEYCERT_SIGN_DSA_SHA1, signer, time.Unix(1500000000, 0), KEYCERT_SIGN_ED25519, transient_public_key)
+	if assert.NotNil(err) {
+		assert.Equal("error building offline signature: signature size does not match signing key", err.Error())
+	}
+}
+
+func TestOfflineSignatureIsExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	_, signer, _ := buildEd25519Destination()
+	expires := time.Unix(1500000000, 0)
+	offline_signature, _, err := buildOfflineSignature(signer, expires)
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.False(offline_signature.IsExpired(expires.Add(-time.Second)))
+	assert.False(offline_signature.IsExpired(expires))
+	assert.True(offline_signature.IsExpired(expires.Add(time.Second)))
+	assert.True(OfflineSignature{0x00}.IsExpired(expires))
+}
@@ -0,0 +1,412 @@
+package common
+
+/*
+I2P Private Key File
+https://geti2p.net/javadoc/net/i2p/data/PrivateKeyFile.html
+
+The format used by I2P software to store a Destination with its private keys,
+as read by SAM, i2ptunnel and the Java router.
+
++----+----+----+----+----+----+----+----+
+| destination                           |
++                                       +
+|                                       |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+| private_key                           |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+| signing_private_key                   |
+~                                       ~
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+
+destination :: Destination
+               length -> >= 387 bytes
+
+private_key :: PrivateKey
+               length -> As implied by the crypto type in the Destination,
+                         256 bytes for ElGamal or 32 bytes for X25519
+
+signing_private_key :: SigningPrivateKey
+                       length -> As implied by the sig type in the Destination
+*/
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/hkparker/go-i2p/lib/common/base64"
+	"github.com/hkparker/go-i2p/lib/crypto"
+)
+
+// PrivateKey sizes for Public Key Types
+const (
+	PRIVATE_KEY_ELG_SIZE    = 256
+	PRIVATE_KEY_X25519_SIZE = 32
+)
+
+// SigningPrivateKey sizes for Signing Key Types
+const (
+	SIGNING_PRIVATE_KEY_DSA_SHA1_SIZE  = 20
+	SIGNING_PRIVATE_KEY_P256_SIZE      = 32
+	SIGNING_PRIVATE_KEY_P384_SIZE      = 48
+	SIGNING_PRIVATE_KEY_P521_SIZE      = 66
+	SIGNING_PRIVATE_KEY_RSA2048_SIZE   = 512
+	SIGNING_PRIVATE_KEY_RSA3072_SIZE   = 768
+	SIGNING_PRIVATE_KEY_RSA4096_SIZE   = 1024
+	SIGNING_PRIVATE_KEY_ED25519_SIZE   = 32
+	SIGNING_PRIVATE_KEY_ED25519PH_SIZE = 32
+	SIGNING_PRIVATE_KEY_REDDSA_SIZE    = 32
+)
+
+type PrivateKeyFile []byte
+
+//
+// Generate a new Destination with an encryption key of the provided Public Key Type and a
+// signing key of the provided Signing Key Type, returning it in a PrivateKeyFile along with
+// both private keys.
+//
+func GeneratePrivateKeyFile(crypto_key_type, signing_key_type int) (private_key_file PrivateKeyFile, err error) {
+	public_key, private_key, err := generateEncryptionKeys(crypto_key_type)
+	if err != nil {
+		return
+	}
+	signing_public_key, signing_private_key, err := generateSigningKeys(signing_key_type)
+	if err != nil {
+		return
+	}
+	keys_and_cert, err := NewKeysAndCert(crypto_key_type, public_key, signing_key_type, signing_public_key)
+	if err != nil {
+		return
+	}
+	private_key_file, err = NewPrivateKeyFile(Destination(keys_and_cert), private_key, signing_private_key)
+	return
+}
+
+//
+// Assemble a PrivateKeyFile from a Destination and the raw bytes of its private keys, which
+// must have the lengths implied by the key types of the Destination.
+//
+func NewPrivateKeyFile(destination Destination, private_key, signing_private_key []byte) (private_key_file PrivateKeyFile, err error) {
+	destination, _, err = ReadDestination(destination)
+	if err != nil {
+		return
+	}
+	private_key_size, signing_private_key_size, err := privateKeySizes(destination)
+	if err != nil {
+		return
+	}
+	if len(private_key) != private_key_size || len(signing_private_key) != signing_private_key_size {
+		log.WithFields(log.Fields{
+			"at":                       "NewPrivateKeyFile",
+			"private_key_len":          len(private_key),
+			"required_private_key_len": private_key_size,
+			"signing_key_len":          len(signing_private_key),
+			"required_signing_key_len": signing_private_key_size,
+			"reason":                   "private keys do not match destination",
+		}).Error("error building private key file")
+		err = errors.New("error building private key file: private keys do not match destination")
+		return
+	}
+	data := make([]byte, 0)
+	data = append(data, destination...)
+	data = append(data, private_key...)
+	data = append(data, signing_private_key...)
+	private_key_file = PrivateKeyFile(data)
+	return
+}
+
+//
+// Read a PrivateKeyFile from a slice of bytes, returning the remaining data and any errors
+// encountered parsing the PrivateKeyFile.
+//
+func ReadPrivateKeyFile(data []byte) (private_key_file PrivateKeyFile, remainder []byte, err error) {
+	destination, remainder, err := ReadDestination(data)
+	if err != nil {
+		return
+	}
+	private_key_size, signing_private_key_size, err := privateKeySizes(destination)
+	if err != nil {
+		return
+	}
+	keys_size := private_key_size + signing_private_key_size
+	if len(remainder) < keys_size {
+		log.WithFields(log.Fields{
+			"at":           "ReadPrivateKeyFile",
+			"data_len":     len(remainder),
+			"required_len": keys_size,
+			"reason":       "not enough data",
+		}).Error("error parsing private key file")
+		err = errors.New("error parsing private key file: not enough data")
+		return
+	}
+	private_key_file = PrivateKeyFile(data[:len(destination)+keys_size])
+	remainder = remainder[keys_size:]
+	return
+}
+
+//
+// Read the Destination at the beginning of the PrivateKeyFile.
+//
+func (private_key_file PrivateKeyFile) Destination() (destination Destination, err error) {
+	destination, _, err = ReadDestination(private_key_file)
+	return
+}
+
+//
+// Read the RouterIdentity at the beginning of the PrivateKeyFile, for files holding the keys
+// of a router rather than a Destination.
+//
+func (private_key_file PrivateKeyFile) RouterIdentity() (router_identity RouterIdentity, err error) {
+	router_identity, _, err = ReadRouterIdentity(private_key_file)
+	return
+}
+
+//
+// Return the raw bytes of the private key matching the Destination's PublicKey.
+//
+func (private_key_file PrivateKeyFile) PrivateKey() (private_key []byte, err error) {
+	private_key_start, signing_private_key_start, _, err := private_key_file.keyOffsets()
+	if err != nil {
+		return
+	}
+	private_key = private_key_file[private_key_start:signing_private_key_start]
+	return
+}
+
+//
+// Return the raw bytes of the private key matching the Destination's SigningPublicKey.
+//
+func (private_key_file PrivateKeyFile) SigningPrivateKey() (signing_private_key []byte, err error) {
+	_, signing_private_key_start, end, err := private_key_file.keyOffsets()
+	if err != nil {
+		return
+	}
+	signing_private_key = private_key_file[signing_private_key_start:end]
+	return
+}
+
+//
+// Create a Signer for the Destination's SigningPublicKey, returning an error if signing is not
+// supported for the Destination's Signing Key Type.
+//
+func (private_key_file PrivateKeyFile) NewSigner() (signer crypto.Signer, err error) {
+	signing_private_key, err := private_key_file.SigningPrivateKey()
+	if err != nil {
+		return
+	}
+	signing_key_type, _ := KeysAndCert(private_key_file).signingPublicKeyType()
+	if signing_key_type == KEYCERT_SIGN_DSA_SHA1 {
+		var dsa_key crypto.DSAPrivateKey
+		copy(dsa_key[:], signing_private_key)
+		signer, err = dsa_key.NewSigner()
+		return
+	}
+	key := newSigningPrivateKey(signing_key_type, signing_private_key)
+	if key == nil {
+		log.WithFields(log.Fields{
+			"at":               "(PrivateKeyFile) NewSigner",
+			"signing_key_type": signing_key_type,
+			"reason":           "unsupported signing key type",
+		}).Error("error creating signer")
+		err = errors.New("error creating signer: unsupported signing key type")
+		return
+	}
+	signer, err = key.NewSigner()
+	return
+}
+
+//
+// Generate the I2P base64 encoding of the whole PrivateKeyFile, as used by SAM and i2ptunnel
+// to import a Destination along with its private keys.
+//
+func (private_key_file PrivateKeyFile) Base64() string {
+	return base64.EncodeToString(private_key_file)
+}
+
+//
+// Generate the I2P base32 address of the Destination in this PrivateKeyFile.
+//
+func (private_key_file PrivateKeyFile) Base32Address() (str string, err error) {
+	destination, err := private_key_file.Destination()
+	if err != nil {
+		return
+	}
+	str = destination.Base32Address()
+	return
+}
+
+//
+// Return the offsets of the private key, the signing private key and the end of the
+// PrivateKeyFile.
+//
+func (private_key_file PrivateKeyFile) keyOffsets() (private_key_start, signing_private_key_start, end int, err error) {
+	_, remainder, err := ReadPrivateKeyFile(private_key_file)
+	if err != nil {
+		return
+	}
+	destination, _ := private_key_file.Destination()
+	private_key_size, _, _ := privateKeySizes(destination)
+	private_key_start = len(destination)
+	signing_private_key_start = private_key_start + private_key_size
+	end = len(private_key_file) - len(remainder)
+	return
+}
+
+//
+// Return the sizes of the private keys matching the key types of a Destination, or the
+// registry's policy error if either type is disabled.
+//
+func privateKeySizes(destination Destination) (private_key_size, signing_private_key_size int, err error) {
+	crypto_key_type, err := KeysAndCert(destination).publicKeyType()
+	if err != nil {
+		return
+	}
+	signing_key_type, err := KeysAndCert(destination).signingPublicKeyType()
+	if err != nil {
+		return
+	}
+	if err = keyTypePolicyError(signing_key_type, crypto_key_type); err != nil {
+		log.WithFields(log.Fields{
+			"at":               "privateKeySizes",
+			"crypto_key_type":  crypto_key_type,
+			"signing_key_type": signing_key_type,
+			"reason":           err.Error(),
+		}).Error("error parsing private key file")
+		return
+	}
+	encryption_type, crypto_err := crypto.EncryptionTypeByCode(crypto_key_type)
+	signature_type, signing_err := crypto.SignatureTypeByCode(signing_key_type)
+	if crypto_err == nil && signing_err == nil {
+		private_key_size = encryption_type.PrivateKeySize
+		signing_private_key_size = signature_type.PrivateKeySize
+	}
+	if private_key_size == 0 || signing_private_key_size == 0 {
+		log.WithFields(log.Fields{
+			"at":               "privateKeySizes",
+			"crypto_key_type":  crypto_key_type,
+			"signing_key_type": signing_key_type,
+			"reason":           "unknown key type",
+		}).Error("error parsing private key file")
+		err = errors.New("error parsing private key file: unknown key type")
+	}
+	return
+}
+
+//
+// Generate an encryption key pair of the provided Public Key Type, returning the raw bytes
+// of both keys.
+//
+func generateEncryptionKeys(crypto_key_type int) (public_key, private_key []byte, err error) {
+	if _, lookup_err := crypto.EncryptionTypeByCode(crypto_key_type); lookup_err == crypto.ErrEncryptionTypeDisabled {
+		log.WithFields(log.Fields{
+			"at":              "generateEncryptionKeys",
+			"crypto_key_type": crypto_key_type,
+			"reason":          lookup_err.Error(),
+		}).Error("error generating keys")
+		err = lookup_err
+		return
+	}
+	switch crypto_key_type {
+	case KEYCERT_CRYPTO_ELG:
+		var elg_private_key crypto.ElgPrivateKey
+		elg_private_key, err = elg_private_key.Generate()
+		if err != nil {
+			return
+		}
+		var elg_public_key crypto.ElgPublicKey
+		elg_public_key, err = elg_private_key.Public()
+		public_key, private_key = elg_public_key.Bytes(), elg_private_key.Bytes()
+	case KEYCERT_CRYPTO_X25519:
+		var x25519_private_key crypto.X25519PrivateKey
+		x25519_private_key, err = x25519_private_key.Generate()
+		if err != nil {
+			return
+		}
+		var x25519_public_key crypto.X25519PublicKey
+		x25519_public_key, err = x25519_private_key.Public()
+		public_key, private_key = x25519_public_key.Bytes(), x25519_private_key.Bytes()
+	default:
+		log.WithFields(log.Fields{
+			"at":              "generateEncryptionKeys",
+			"crypto_key_type": crypto_key_type,
+			"reason":          "unsupported crypto key type",
+		}).Error("error generating keys")
+		err = errors.New("error generating keys: unsupported crypto key type")
+	}
+	return
+}
+
+//
+// Generate a signing key pair of the provided Signing Key Type, returning the raw bytes of
+// both keys.
+//
+func generateSigningKeys(signing_key_type int) (signing_public_key, signing_private_key []byte, err error) {
+	if _, lookup_err := crypto.SignatureTypeByCode(signing_key_type); lookup_err == crypto.ErrSignatureTypeDisabled {
+		log.WithFields(log.Fields{
+			"at":               "generateSigningKeys",
+			"signing_key_type": signing_key_type,
+			"reason":           lookup_err.Error(),
+		}).Error("error generating keys")
+		err = lookup_err
+		return
+	}
+	switch signing_key_type {
+	case KEYCERT_SIGN_DSA_SHA1:
+		var dsa_private_key crypto.DSAPrivateKey
+		dsa_private_key, err = dsa_private_key.Generate()
+		if err != nil {
+			return
+		}
+		var dsa_public_key crypto.DSAPublicKey
+		dsa_public_key, err = dsa_private_key.Public()
+		signing_public_key, signing_private_key = dsa_public_key.Bytes(), dsa_private_key.Bytes()
+	default:
+		private_key := newSigningPrivateKey(signing_key_type, nil)
+		if private_key == nil {
+			log.WithFields(log.Fields{
+				"at":               "generateSigningKeys",
+				"signing_key_type": signing_key_type,
+				"reason":           "unsupported signing key type",
+			}).Error("error generating keys")
+			err = errors.New("error generating keys: unsupported signing key type")
+			return
+		}
+		private_key, err = private_key.Generate()
+		if err != nil {
+			return
+		}
+		var public_key crypto.SigningPublicKey
+		public_key, err = private_key.Public()
+		if err != nil {
+			return
+		}
+		signing_public_key, signing_private_key = public_key.Bytes(), private_key.Bytes()
+	}
+	return
+}
+
+//
+// Build a SigningPrivateKey of the provided Signing Key Type from its raw bytes, or a zero key to
+// generate into when data is nil.  Returns nil if signing is not supported for the type.  DSA keys
+// predate the SigningPrivateKey interface and are handled by callers.
+//
+func newSigningPrivateKey(signing_key_type int, data []byte) crypto.SigningPrivateKey {
+	signature_type, err := crypto.SignatureTypeByCode(signing_key_type)
+	if err != nil || signature_type.NewPrivateKey == nil {
+		return nil
+	}
+	if data == nil {
+		data = make([]byte, signature_type.PrivateKeySize)
+	}
+	key, err := signature_type.NewPrivateKey(data)
+	if err != nil {
+		return nil
+	}
+	return key
+}
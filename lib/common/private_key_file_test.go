@@ -0,0 +1,187 @@
+package common
+
+import (
+	"github.com/hkparker/go-i2p/lib/common/base64"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestGeneratePrivateKeyFileEd25519X25519(t *testing.T) {
+	assert := assert.New(t)
+
+	private_key_file, err := GeneratePrivateKeyFile(KEYCERT_CRYPTO_X25519, KEYCERT_SIGN_ED25519)
+	if !assert.Nil(err) {
+		return
+	}
+	destination, err := private_key_file.Destination()
+	assert.Nil(err)
+	assert.Equal(KEYS_AND_CERT_MIN_SIZE+4, len(destination))
+	assert.Equal(len(destination)+32+32, len(private_key_file))
+	cert, _ := destination.Certificate()
+	cert_type, _ := cert.Type()
+	assert.Equal(CERT_KEY, cert_type)
+	crypto_key_type, _ := KeyCertificate(cert).PublicKeyType()
+	assert.Equal(KEYCERT_CRYPTO_X25519, crypto_key_type)
+
+	private_key, err := private_key_file.PrivateKey()
+	assert.Nil(err)
+	assert.Equal(32, len(private_key))
+	signing_private_key, err := private_key_file.SigningPrivateKey()
+	assert.Nil(err)
+	assert.Equal(32, len(signing_private_key))
+
+	signer, err := private_key_file.NewSigner()
+	if !assert.Nil(err) {
+		return
+	}
+	data := []byte("signed by a generated destination")
+	signature, err := signer.Sign(data)
+	assert.Nil(err)
+	signing_public_key, err := destination.SigningPublicKey()
+	assert.Nil(err)
+	verifier, _ := signing_public_key.NewVerifier()
+	assert.Nil(verifier.Verify(data, signature))
+}
+
+func TestGeneratePrivateKeyFileDSAElGamal(t *testing.T) {
+	assert := assert.New(t)
+
+	private_key_file, err := GeneratePrivateKeyFile(KEYCERT_CRYPTO_ELG, KEYCERT_SIGN_DSA_SHA1)
+	if !assert.Nil(err) {
+		return
+	}
+	destination, _ := private_key_file.Destination()
+	assert.Equal(KEYS_AND_CERT_MIN_SIZE, len(destination))
+	assert.Equal(len(destination)+256+20, len(private_key_file))
+	cert, _ := destination.Certificate()
+	cert_type, _ := cert.Type()
+	assert.Equal(CERT_NULL, cert_type)
+
+	signer, err := private_key_file.NewSigner()
+	if !assert.Nil(err) {
+		return
+	}
+	data := []byte("signed by a generated destination")
+	signature, _ := signer.Sign(data)
+	signing_public_key, _ := destination.SigningPublicKey()
+	verifier, _ := signing_public_key.NewVerifier()
+	assert.Nil(verifier.Verify(data, signature))
+}
+
+func TestGeneratePrivateKeyFileUnsupportedType(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := GeneratePrivateKeyFile(KEYCERT_CRYPTO_ELG, 9)
+	if assert.NotNil(err) {
+		assert.Equal("error generating keys: unsupported signing key type", err.Error())
+	}
+	_, err = GeneratePrivateKeyFile(3, KEYCERT_SIGN_ED25519)
+	if assert.NotNil(err) {
+		assert.Equal("error generating keys: unsupported crypto key type", err.Error())
+	}
+}
+
+func TestPrivateKeyFileBase64RoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	private_key_file, err := GeneratePrivateKeyFile(KEYCERT_CRYPTO_ELG, KEYCERT_SIGN_ED25519)
+	if !assert.Nil(err) {
+		return
+	}
+	decoded, err := base64.DecodeFromString(private_key_file.Base64())
+	assert.Nil(err)
+	read_file, remainder, err := ReadPrivateKeyFile(append(decoded, 0x01))
+	assert.Nil(err)
+	assert.Equal(private_key_file, read_file)
+	assert.Equal([]byte{0x01}, remainder)
+
+	destination, _ := private_key_file.Destination()
+	address, err := private_key_file.Base32Address()
+	assert.Nil(err)
+	assert.Equal(destination.Base32Address(), address)
+	assert.True(strings.HasSuffix(address, ".b32.i2p"))
+	assert.True(strings.HasPrefix(private_key_file.Base64(), destination.Base64()[:512]))
+}
+
+func TestReadPrivateKeyFileTruncated(t *testing.T) {
+	assert := assert.New(t)
+
+	private_key_file, _ := GeneratePrivateKeyFile(KEYCERT_CRYPTO_X25519, KEYCERT_SIGN_ED25519)
+	_, _, err := ReadPrivateKeyFile(private_key_file[:len(private_key_file)-1])
+	if assert.NotNil(err) {
+		assert.Equal("error parsing private key file: not enough data", err.Error())
+	}
+	_, err = PrivateKeyFile(private_key_file[:len(private_key_file)-1]).SigningPrivateKey()
+	assert.NotNil(err)
+}
+
+func TestNewPrivateKeyFileWithMismatchedKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	private_key_file, _ := GeneratePrivateKeyFile(KEYCERT_CRYPTO_X25519, KEYCERT_SIGN_ED25519)
+	destination, _ := private_key_file.Destination()
+	_, err := NewPrivateKeyFile(destination, make([]byte, 256), make([]byte, 32))
+	if assert.NotNil(err) {
+		assert.Equal("error building private key file: private keys do not match destination", err.Error())
+	}
+}
+
+func TestPrivateKeyFileRouterIdentity(t *testing.T) {
+	assert := assert.New(t)
+
+	private_key_file, err := GeneratePrivateKeyFile(KEYCERT_CRYPTO_X25519, KEYCERT_SIGN_ED25519)
+	if !assert.Nil(err) {
+		return
+	}
+	destination, _ := private_key_file.Destination()
+	router_identity, err := private_key_file.RouterIdentity()
+	assert.Nil(err)
+	assert.Equal([]byte(destination), []byte(router_identity))
+	assert.Equal(HashData(destination), router_identity.IdentHash())
+}
+
+func TestGeneratePrivateKeyFileSigningKeyTypes(t *testing.T) {
+	assert := assert.New(t)
+
+	signing_key_types := []struct {
+		signing_key_type         int
+		signing_public_key_size  int
+		signing_private_key_size int
+	}{
+		{KEYCERT_SIGN_RSA2048, KEYCERT_SIGN_RSA2048_SIZE, SIGNING_PRIVATE_KEY_RSA2048_SIZE},
+		{KEYCERT_SIGN_P256, KEYCERT_SIGN_P256_SIZE, SIGNING_PRIVATE_KEY_P256_SIZE},
+		{KEYCERT_SIGN_P384, KEYCERT_SIGN_P384_SIZE, SIGNING_PRIVATE_KEY_P384_SIZE},
+		{KEYCERT_SIGN_P521, KEYCERT_SIGN_P521_SIZE, SIGNING_PRIVATE_KEY_P521_SIZE},
+		{KEYCERT_SIGN_ED25519PH, KEYCERT_SIGN_ED25519PH_SIZE, SIGNING_PRIVATE_KEY_ED25519PH_SIZE},
+		{KEYCERT_SIGN_REDDSA_ED25519, KEYCERT_SIGN_REDDSA_SIZE, SIGNING_PRIVATE_KEY_REDDSA_SIZE},
+	}
+	for _, key_type := range signing_key_types {
+		private_key_file, err := GeneratePrivateKeyFile(KEYCERT_CRYPTO_X25519, key_type.signing_key_type)
+		if !assert.Nil(err, "signing key type %d", key_type.signing_key_type) {
+			continue
+		}
+		destination, _ := private_key_file.Destination()
+		excess := 0
+		if key_type.signing_public_key_size > KEYCERT_SPK_SIZE {
+			excess = key_type.signing_public_key_size - KEYCERT_SPK_SIZE
+		}
+		assert.Equal(KEYS_AND_CERT_MIN_SIZE+4+excess, len(destination))
+		signing_private_key, _ := private_key_file.SigningPrivateKey()
+		assert.Equal(key_type.signing_private_key_size, len(signing_private_key))
+
+		signer, err := private_key_file.NewSigner()
+		if !assert.Nil(err, "signing key type %d", key_type.signing_key_type) {
+			continue
+		}
+		data := []byte("signed by a generated destination")
+		signature, err := signer.Sign(data)
+		assert.Nil(err)
+		signing_public_key, err := destination.SigningPublicKey()
+		if !assert.Nil(err) {
+			continue
+		}
+		verifier, _ := signing_public_key.NewVerifier()
+		assert.Nil(verifier.Verify(data, signature), "signing key type %d", key_type.signing_key_type)
+	}
+}
@@ -37,7 +37,12 @@ options :: Mapping
 
 import (
 	"errors"
+	"fmt"
 	log "github.com/sirupsen/logrus"
+	"github.com/hkparker/go-i2p/lib/common/base64"
+	"net"
+	"strconv"
+	"time"
 )
 
 // Minimum number of bytes in a valid RouterAddress
@@ -45,6 +50,61 @@ const (
 	ROUTER_ADDRESS_MIN_SIZE = 9
 )
 
+// Transport styles of RouterAddresses
+const (
+	ROUTER_ADDRESS_TRANSPORT_NTCP2 = "NTCP2"
+	ROUTER_ADDRESS_TRANSPORT_SSU2  = "SSU2"
+)
+
+// Keys of the options used by the NTCP2 and SSU2 transports
+const (
+	ROUTER_ADDRESS_OPTION_HOST               = "host"
+	ROUTER_ADDRESS_OPTION_PORT               = "port"
+	ROUTER_ADDRESS_OPTION_STATIC_KEY         = "s"
+	ROUTER_ADDRESS_OPTION_IV                 = "i"
+	ROUTER_ADDRESS_OPTION_VERSION            = "v"
+	ROUTER_ADDRESS_OPTION_MTU                = "mtu"
+	ROUTER_ADDRESS_OPTION_CAPS               = "caps"
+	ROUTER_ADDRESS_OPTION_INTRODUCER_HASH    = "ih"
+	ROUTER_ADDRESS_OPTION_INTRODUCER_TAG     = "itag"
+	ROUTER_ADDRESS_OPTION_INTRODUCER_EXPIRES = "iexp"
+)
+
+// Sizes and limits of NTCP2 and SSU2 options
+const (
+	ROUTER_ADDRESS_STATIC_KEY_SIZE     = 32
+	ROUTER_ADDRESS_NTCP2_IV_SIZE       = 16
+	ROUTER_ADDRESS_SSU2_INTRO_KEY_SIZE = 32
+	ROUTER_ADDRESS_SSU2_MIN_MTU        = 1280
+	ROUTER_ADDRESS_SSU2_MAX_MTU        = 1500
+	ROUTER_ADDRESS_MAX_INTRODUCERS     = 3
+)
+
+//
+// An introducer listed in a firewalled SSU2 RouterAddress, through which peers can reach the
+// router.
+//
+type RouterAddressIntroducer struct {
+	Hash       Hash
+	Tag        uint32
+	Expiration time.Time
+}
+
+//
+// The typed NTCP2 and SSU2 options of a RouterAddress, as passed to NewRouterAddress.  Zero
+// values are left out of the resulting options Mapping.
+//
+type RouterAddressOptions struct {
+	Host                 net.IP
+	Port                 int
+	StaticKey            []byte
+	InitializationVector []byte
+	ProtocolVersion      string
+	MTU                  int
+	Caps                 string
+	Introducers          []RouterAddressIntroducer
+}
+
 type RouterAddress []byte
 
 //
@@ -103,6 +163,142 @@ func (router_address RouterAddress) Options() (mapping Mapping, err error) {
 	return
 }
 
+//
+// Return the IP address in the host option of this RouterAddress, returning an error if the
+// option is missing or is not an IP address.
+//
+func (router_address RouterAddress) Host() (host net.IP, err error) {
+	value, err := router_address.option(ROUTER_ADDRESS_OPTION_HOST)
+	if err != nil {
+		return
+	}
+	host = net.ParseIP(value)
+	if host == nil {
+		err = router_address.optionError(ROUTER_ADDRESS_OPTION_HOST, "not an ip address")
+	}
+	return
+}
+
+//
+// Return the port option of this RouterAddress, returning an error if the option is missing
+// or is not a valid port number.
+//
+func (router_address RouterAddress) Port() (port int, err error) {
+	value, err := router_address.option(ROUTER_ADDRESS_OPTION_PORT)
+	if err != nil {
+		return
+	}
+	port, err = strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		port = 0
+		err = router_address.optionError(ROUTER_ADDRESS_OPTION_PORT, "invalid port")
+	}
+	return
+}
+
+//
+// Return the 32 byte static key in the s option of this RouterAddress, used by both NTCP2 and
+// SSU2 for the Noise handshake.
+//
+func (router_address RouterAddress) StaticKey() (static_key []byte, err error) {
+	static_key, err = router_address.base64Option(ROUTER_ADDRESS_OPTION_STATIC_KEY, ROUTER_ADDRESS_STATIC_KEY_SIZE)
+	return
+}
+
+//
+// Return the i option of this RouterAddress.  This is the 16 byte AES IV for NTCP2 and the
+// 32 byte intro key for SSU2, and is checked against the size for the transport style.
+//
+func (router_address RouterAddress) InitializationVector() (iv []byte, err error) {
+	style, err := router_address.transportStyle()
+	if err != nil {
+		return
+	}
+	iv, err = router_address.base64Option(ROUTER_ADDRESS_OPTION_IV, initializationVectorSize(style))
+	return
+}
+
+//
+// Return the protocol version in the v option of this RouterAddress.
+//
+func (router_address RouterAddress) ProtocolVersion() (version string, err error) {
+	version, err = router_address.option(ROUTER_ADDRESS_OPTION_VERSION)
+	return
+}
+
+//
+// Return the MTU option of this RouterAddress, returning an error if it is not a positive
+// integer or if it is out of range for SSU2.
+//
+func (router_address RouterAddress) MTU() (mtu int, err error) {
+	style, err := router_address.transportStyle()
+	if err != nil {
+		return
+	}
+	value, err := router_address.option(ROUTER_ADDRESS_OPTION_MTU)
+	if err != nil {
+		return
+	}
+	mtu, err = strconv.Atoi(value)
+	if err != nil || !validMTU(style, mtu) {
+		mtu = 0
+		err = router_address.optionError(ROUTER_ADDRESS_OPTION_MTU, "invalid mtu")
+	}
+	return
+}
+
+//
+// Return the caps option of this RouterAddress, such as "4" or "6" for the IP versions a
+// router supports, or "B" and "C" for SSU2 peer testing and introduction.
+//
+func (router_address RouterAddress) Caps() (caps string, err error) {
+	caps, err = router_address.option(ROUTER_ADDRESS_OPTION_CAPS)
+	return
+}
+
+//
+// Return the introducers listed in the ih, itag and iexp options of this RouterAddress, in
+// order of their index.  A firewalled address without introducers returns none.
+//
+func (router_address RouterAddress) Introducers() (introducers []RouterAddressIntroducer, err error) {
+	options, err := router_address.Options()
+	if err != nil {
+		return
+	}
+	for i := 0; i < ROUTER_ADDRESS_MAX_INTRODUCERS; i++ {
+		hash_key := fmt.Sprintf("%s%d", ROUTER_ADDRESS_OPTION_INTRODUCER_HASH, i)
+		if _, present := options.Get(hash_key); !present {
+			break
+		}
+		var introducer RouterAddressIntroducer
+		var hash []byte
+		hash, err = router_address.base64Option(hash_key, len(introducer.Hash))
+		if err != nil {
+			return
+		}
+		copy(introducer.Hash[:], hash)
+		tag_key := fmt.Sprintf("%s%d", ROUTER_ADDRESS_OPTION_INTRODUCER_TAG, i)
+		tag_value, _ := options.Get(tag_key)
+		tag, terr := strconv.ParseUint(tag_value, 10, 32)
+		if terr != nil {
+			err = router_address.optionError(tag_key, "invalid introducer tag")
+			return
+		}
+		introducer.Tag = uint32(tag)
+		expires_key := fmt.Sprintf("%s%d", ROUTER_ADDRESS_OPTION_INTRODUCER_EXPIRES, i)
+		if value, present := options.Get(expires_key); present {
+			expires, eerr := strconv.ParseInt(value, 10, 64)
+			if eerr != nil {
+				err = router_address.optionError(expires_key, "invalid introducer expiration")
+				return
+			}
+			introducer.Expiration = time.Unix(expires, 0)
+		}
+		introducers = append(introducers, introducer)
+	}
+	return
+}
+
 //
 // Check if the RouterAddress is empty or if it is too small to contain valid data.
 //
@@ -159,3 +355,168 @@ func ReadRouterAddress(data []byte) (router_address RouterAddress, remainder []b
 	remainder = data[ROUTER_ADDRESS_MIN_SIZE+len(str)+len(mapping):]
 	return
 }
+
+//
+// Build a RouterAddress for a transport from typed options, validating them the same way the
+// accessors do.  The expiration is left as zero and the options are sorted, so the result can
+// be placed in a RouterInfo and signed.
+//
+func NewRouterAddress(cost uint8, transport_style string, options RouterAddressOptions) (router_address RouterAddress, err error) {
+	style, err := ToI2PString(transport_style)
+	if err != nil {
+		return
+	}
+	values := make(map[string]string)
+	if options.Host != nil {
+		values[ROUTER_ADDRESS_OPTION_HOST] = options.Host.String()
+	}
+	if options.Port != 0 {
+		if options.Port < 1 || options.Port > 65535 {
+			err = builderError("invalid port")
+			return
+		}
+		values[ROUTER_ADDRESS_OPTION_PORT] = strconv.Itoa(options.Port)
+	}
+	if options.StaticKey != nil {
+		if len(options.StaticKey) != ROUTER_ADDRESS_STATIC_KEY_SIZE {
+			err = builderError("incorrect static key size")
+			return
+		}
+		values[ROUTER_ADDRESS_OPTION_STATIC_KEY] = base64.EncodeToString(options.StaticKey)
+	}
+	if options.InitializationVector != nil {
+		iv_size := initializationVectorSize(transport_style)
+		if iv_size != 0 && len(options.InitializationVector) != iv_size {
+			err = builderError("incorrect iv size")
+			return
+		}
+		values[ROUTER_ADDRESS_OPTION_IV] = base64.EncodeToString(options.InitializationVector)
+	}
+	if options.ProtocolVersion != "" {
+		values[ROUTER_ADDRESS_OPTION_VERSION] = options.ProtocolVersion
+	}
+	if options.MTU != 0 {
+		if !validMTU(transport_style, options.MTU) {
+			err = builderError("invalid mtu")
+			return
+		}
+		values[ROUTER_ADDRESS_OPTION_MTU] = strconv.Itoa(options.MTU)
+	}
+	if options.Caps != "" {
+		values[ROUTER_ADDRESS_OPTION_CAPS] = options.Caps
+	}
+	if len(options.Introducers) > ROUTER_ADDRESS_MAX_INTRODUCERS {
+		err = builderError("too many introducers")
+		return
+	}
+	for i, introducer := range options.Introducers {
+		values[fmt.Sprintf("%s%d", ROUTER_ADDRESS_OPTION_INTRODUCER_HASH, i)] = base64.EncodeToString(introducer.Hash[:])
+		values[fmt.Sprintf("%s%d", ROUTER_ADDRESS_OPTION_INTRODUCER_TAG, i)] = strconv.FormatUint(uint64(introducer.Tag), 10)
+		if !introducer.Expiration.IsZero() {
+			values[fmt.Sprintf("%s%d", ROUTER_ADDRESS_OPTION_INTRODUCER_EXPIRES, i)] = strconv.FormatInt(introducer.Expiration.Unix(), 10)
+		}
+	}
+	mapping, err := GoMapToMapping(values)
+	if err != nil {
+		return
+	}
+	router_address = make(RouterAddress, ROUTER_ADDRESS_MIN_SIZE)
+	router_address[0] = cost
+	router_address = append(router_address, style...)
+	router_address = append(router_address, mapping...)
+	return
+}
+
+//
+// Return the transport style of the RouterAddress as a Go string.
+//
+func (router_address RouterAddress) transportStyle() (style string, err error) {
+	str, err := router_address.TransportStyle()
+	if err != nil {
+		return
+	}
+	style, err = str.Data()
+	return
+}
+
+//
+// Return the value of an option, returning an error if it is not present.
+//
+func (router_address RouterAddress) option(key string) (value string, err error) {
+	options, err := router_address.Options()
+	if err != nil {
+		return
+	}
+	value, present := options.Get(key)
+	if !present {
+		err = router_address.optionError(key, "missing option")
+	}
+	return
+}
+
+//
+// Return the base64 decoded value of an option, checking its size if size is not zero.
+//
+func (router_address RouterAddress) base64Option(key string, size int) (data []byte, err error) {
+	value, err := router_address.option(key)
+	if err != nil {
+		return
+	}
+	data, err = base64.DecodeFromString(value)
+	if err != nil {
+		data = nil
+		err = router_address.optionError(key, "invalid base64")
+		return
+	}
+	if size != 0 && len(data) != size {
+		data = nil
+		err = router_address.optionError(key, "incorrect size")
+	}
+	return
+}
+
+//
+// Log and return an error for an invalid option.
+//
+func (router_address RouterAddress) optionError(key, reason string) error {
+	log.WithFields(log.Fields{
+		"at":     "(RouterAddress) option",
+		"option": key,
+		"reason": reason,
+	}).Error("error parsing RouterAddress option")
+	return fmt.Errorf("error parsing RouterAddress: %s: %s", reason, key)
+}
+
+//
+// Log and return an error for an option that cannot be placed in a new RouterAddress.
+//
+func builderError(reason string) error {
+	log.WithFields(log.Fields{
+		"at":     "NewRouterAddress",
+		"reason": reason,
+	}).Error("error building RouterAddress")
+	return errors.New("error building RouterAddress: " + reason)
+}
+
+//
+// Return the size of the i option for a transport style, or zero if it is not known.
+//
+func initializationVectorSize(transport_style string) int {
+	switch transport_style {
+	case ROUTER_ADDRESS_TRANSPORT_NTCP2:
+		return ROUTER_ADDRESS_NTCP2_IV_SIZE
+	case ROUTER_ADDRESS_TRANSPORT_SSU2:
+		return ROUTER_ADDRESS_SSU2_INTRO_KEY_SIZE
+	}
+	return 0
+}
+
+//
+// Check an MTU is positive and, for SSU2, within the range allowed by the transport.
+//
+func validMTU(transport_style string, mtu int) bool {
+	if transport_style == ROUTER_ADDRESS_TRANSPORT_SSU2 {
+		return mtu >= ROUTER_ADDRESS_SSU2_MIN_MTU && mtu <= ROUTER_ADDRESS_SSU2_MAX_MTU
+	}
+	return mtu > 0
+}
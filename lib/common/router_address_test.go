@@ -3,7 +3,9 @@ package common
 import (
 	"bytes"
 	"github.com/stretchr/testify/assert"
+	"net"
 	"testing"
+	"time"
 )
 
 func TestCheckValidReportsEmptySlice(t *testing.T) {
@@ -95,3 +97,156 @@ func TestCorrectsFuzzCrasher1(t *testing.T) {
 	router_address_bytes := []byte{0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x00, 0x30, 0x30}
 	ReadRouterAddress(router_address_bytes)
 }
+
+func buildNTCP2Address(t *testing.T) RouterAddress {
+	static_key := make([]byte, ROUTER_ADDRESS_STATIC_KEY_SIZE)
+	iv := make([]byte, ROUTER_ADDRESS_NTCP2_IV_SIZE)
+	for i := range static_key {
+		static_key[i] = byte(i)
+	}
+	router_address, err := NewRouterAddress(10, ROUTER_ADDRESS_TRANSPORT_NTCP2, RouterAddressOptions{
+		Host:                 net.ParseIP("192.0.2.7"),
+		Port:                 12345,
+		StaticKey:            static_key,
+		InitializationVector: iv,
+		ProtocolVersion:      "2",
+	})
+	if err != nil {
+		t.Fatalf("failed to build router address: %s", err.Error())
+	}
+	return router_address
+}
+
+func TestNewRouterAddressNTCP2Options(t *testing.T) {
+	assert := assert.New(t)
+
+	router_address := buildNTCP2Address(t)
+	cost, _ := router_address.Cost()
+	assert.Equal(10, cost)
+	expiration, _ := router_address.Expiration()
+	assert.Equal(Date{}, expiration)
+	style, _ := router_address.TransportStyle()
+	style_data, _ := style.Data()
+	assert.Equal(ROUTER_ADDRESS_TRANSPORT_NTCP2, style_data)
+
+	host, err := router_address.Host()
+	assert.Nil(err)
+	assert.True(net.ParseIP("192.0.2.7").Equal(host))
+	port, err := router_address.Port()
+	assert.Nil(err)
+	assert.Equal(12345, port)
+	static_key, err := router_address.StaticKey()
+	assert.Nil(err)
+	assert.Equal(byte(31), static_key[31])
+	iv, err := router_address.InitializationVector()
+	assert.Nil(err)
+	assert.Equal(ROUTER_ADDRESS_NTCP2_IV_SIZE, len(iv))
+	version, err := router_address.ProtocolVersion()
+	assert.Nil(err)
+	assert.Equal("2", version)
+
+	_, err = router_address.MTU()
+	if assert.NotNil(err) {
+		assert.Equal("error parsing RouterAddress: missing option: mtu", err.Error())
+	}
+	introducers, err := router_address.Introducers()
+	assert.Nil(err)
+	assert.Equal(0, len(introducers))
+}
+
+func TestReadRouterAddressFromBuiltAddress(t *testing.T) {
+	assert := assert.New(t)
+
+	router_address := buildNTCP2Address(t)
+	read_address, remainder, err := ReadRouterAddress(append(router_address, 0x01))
+	assert.Nil(err)
+	assert.Equal(router_address, read_address)
+	assert.Equal([]byte{0x01}, remainder)
+}
+
+func TestNewRouterAddressSSU2Introducers(t *testing.T) {
+	assert := assert.New(t)
+
+	expiration := time.Unix(1700000000, 0)
+	introducers := []RouterAddressIntroducer{
+		{Hash: HashData([]byte("first")), Tag: 1234, Expiration: expiration},
+		{Hash: HashData([]byte("second")), Tag: 4294967295},
+	}
+	router_address, err := NewRouterAddress(5, ROUTER_ADDRESS_TRANSPORT_SSU2, RouterAddressOptions{
+		StaticKey:            make([]byte, ROUTER_ADDRESS_STATIC_KEY_SIZE),
+		InitializationVector: make([]byte, ROUTER_ADDRESS_SSU2_INTRO_KEY_SIZE),
+		ProtocolVersion:      "2",
+		MTU:                  1400,
+		Caps:                 "BC",
+		Introducers:          introducers,
+	})
+	if !assert.Nil(err) {
+		return
+	}
+	mtu, err := router_address.MTU()
+	assert.Nil(err)
+	assert.Equal(1400, mtu)
+	caps, err := router_address.Caps()
+	assert.Nil(err)
+	assert.Equal("BC", caps)
+	iv, err := router_address.InitializationVector()
+	assert.Nil(err)
+	assert.Equal(ROUTER_ADDRESS_SSU2_INTRO_KEY_SIZE, len(iv))
+	read_introducers, err := router_address.Introducers()
+	assert.Nil(err)
+	assert.Equal(introducers, read_introducers)
+	_, err = router_address.Host()
+	assert.NotNil(err)
+}
+
+func TestNewRouterAddressRejectsInvalidOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewRouterAddress(0, ROUTER_ADDRESS_TRANSPORT_NTCP2, RouterAddressOptions{Port: 70000})
+	if assert.NotNil(err) {
+		assert.Equal("error building RouterAddress: invalid port", err.Error())
+	}
+	_, err = NewRouterAddress(0, ROUTER_ADDRESS_TRANSPORT_NTCP2, RouterAddressOptions{StaticKey: make([]byte, 31)})
+	if assert.NotNil(err) {
+		assert.Equal("error building RouterAddress: incorrect static key size", err.Error())
+	}
+	_, err = NewRouterAddress(0, ROUTER_ADDRESS_TRANSPORT_NTCP2, RouterAddressOptions{InitializationVector: make([]byte, 32)})
+	if assert.NotNil(err) {
+		assert.Equal("error building RouterAddress: incorrect iv size", err.Error())
+	}
+	_, err = NewRouterAddress(0, ROUTER_ADDRESS_TRANSPORT_SSU2, RouterAddressOptions{MTU: 1000})
+	if assert.NotNil(err) {
+		assert.Equal("error building RouterAddress: invalid mtu", err.Error())
+	}
+	_, err = NewRouterAddress(0, ROUTER_ADDRESS_TRANSPORT_SSU2, RouterAddressOptions{Introducers: make([]RouterAddressIntroducer, 4)})
+	if assert.NotNil(err) {
+		assert.Equal("error building RouterAddress: too many introducers", err.Error())
+	}
+}
+
+func TestRouterAddressOptionValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	router_address := RouterAddress([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+	style, _ := ToI2PString(ROUTER_ADDRESS_TRANSPORT_NTCP2)
+	router_address = append(router_address, style...)
+	mapping, _ := GoMapToMapping(map[string]string{"host": "example.com", "port": "0", "s": "not base64!", "i": "AAAA"})
+	router_address = append(router_address, mapping...)
+
+	_, err := router_address.Host()
+	if assert.NotNil(err) {
+		assert.Equal("error parsing RouterAddress: not an ip address: host", err.Error())
+	}
+	_, err = router_address.Port()
+	if assert.NotNil(err) {
+		assert.Equal("error parsing RouterAddress: invalid port: port", err.Error())
+	}
+	_, err = router_address.StaticKey()
+	if assert.NotNil(err) {
+		assert.Equal("error parsing RouterAddress: invalid base64: s", err.Error())
+	}
+	_, err = router_address.InitializationVector()
+	if assert.NotNil(err) {
+		assert.Equal("error parsing RouterAddress: incorrect size: i", err.Error())
+	}
+}
@@ -0,0 +1,237 @@
+package common
+
+/*
+I2P RouterInfo Options
+https://geti2p.net/spec/common-structures#routerinfo
+Accurate for version 0.9.58
+
+caps :: Capability letters of the router
+        f: floodfill
+        H: hidden
+        R: reachable
+        U: unreachable
+        K, L, M, N, O, P, X: shared bandwidth tier, from under 12 KBps to over 2000 KBps
+        D, E, G: moderate congestion, severe congestion, rejecting all tunnels
+
+netId :: The network the router belongs to, 2 for the I2P network
+
+router.version :: The version of the router software, such as 0.9.58
+*/
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+// Keys of the RouterInfo options interpreted by RouterCapabilities
+const (
+	ROUTER_INFO_OPTION_CAPS    = "caps"
+	ROUTER_INFO_OPTION_NET_ID  = "netId"
+	ROUTER_INFO_OPTION_VERSION = "router.version"
+)
+
+// Capability letters in the caps option of a RouterInfo
+const (
+	ROUTER_CAPS_FLOODFILL             = 'f'
+	ROUTER_CAPS_HIDDEN                = 'H'
+	ROUTER_CAPS_REACHABLE             = 'R'
+	ROUTER_CAPS_UNREACHABLE           = 'U'
+	ROUTER_CAPS_CONGESTION_MODERATE   = 'D'
+	ROUTER_CAPS_CONGESTION_SEVERE     = 'E'
+	ROUTER_CAPS_CONGESTION_NO_TUNNELS = 'G'
+	ROUTER_CAPS_BANDWIDTH_TIERS       = "KLMNOPX"
+	ROUTER_CAPS_BANDWIDTH_UNKNOWN     = 0
+)
+
+// Network ID of the I2P network
+const I2P_NETWORK_ID = 2
+
+//
+// The capabilities a router advertises in the caps option of its RouterInfo.  Bandwidth is the
+// highest bandwidth tier letter listed, and Congestion is the congestion letter listed, if any.
+//
+type RouterCapabilities struct {
+	Floodfill   bool
+	Hidden      bool
+	Reachable   bool
+	Unreachable bool
+	Bandwidth   byte
+	Congestion  byte
+}
+
+//
+// Parse a caps string into RouterCapabilities.  Unknown letters are ignored so that routers
+// advertising newer capabilities can still be used.
+//
+func ParseRouterCapabilities(caps string) (capabilities RouterCapabilities) {
+	for i := 0; i < len(caps); i++ {
+		letter := caps[i]
+		switch letter {
+		case ROUTER_CAPS_FLOODFILL:
+			capabilities.Floodfill = true
+		case ROUTER_CAPS_HIDDEN:
+			capabilities.Hidden = true
+		case ROUTER_CAPS_REACHABLE:
+			capabilities.Reachable = true
+		case ROUTER_CAPS_UNREACHABLE:
+			capabilities.Unreachable = true
+		case ROUTER_CAPS_CONGESTION_MODERATE, ROUTER_CAPS_CONGESTION_SEVERE, ROUTER_CAPS_CONGESTION_NO_TUNNELS:
+			capabilities.Congestion = letter
+		default:
+			if bandwidthTier(letter) > bandwidthTier(capabilities.Bandwidth) {
+				capabilities.Bandwidth = letter
+			}
+		}
+	}
+	return
+}
+
+//
+// Return true if the router advertises a bandwidth tier at least as high as the provided tier
+// letter.
+//
+func (capabilities RouterCapabilities) BandwidthAtLeast(tier byte) bool {
+	return bandwidthTier(capabilities.Bandwidth) >= bandwidthTier(tier) &&
+		capabilities.Bandwidth != ROUTER_CAPS_BANDWIDTH_UNKNOWN
+}
+
+//
+// Return true if the router advertises any level of congestion.
+//
+func (capabilities RouterCapabilities) IsCongested() bool {
+	return capabilities.Congestion != 0
+}
+
+//
+// Return true if the router advertises that it is rejecting all tunnel build requests.
+//
+func (capabilities RouterCapabilities) RejectsTunnels() bool {
+	return capabilities.Congestion == ROUTER_CAPS_CONGESTION_NO_TUNNELS
+}
+
+//
+// Return the position of a bandwidth tier letter in ROUTER_CAPS_BANDWIDTH_TIERS, or -1 if the
+// letter is not a bandwidth tier.
+//
+func bandwidthTier(letter byte) int {
+	if letter == ROUTER_CAPS_BANDWIDTH_UNKNOWN {
+		return -1
+	}
+	return strings.IndexByte(ROUTER_CAPS_BANDWIDTH_TIERS, letter)
+}
+
+//
+// A router software version, such as 0.9.58, which compares numerically component by
+// component.
+//
+type RouterVersion []int
+
+//
+// Parse a dotted router version string, returning an error if any component is not a
+// non-negative integer.
+//
+func ParseRouterVersion(str string) (version RouterVersion, err error) {
+	for _, component := range strings.Split(str, ".") {
+		number, aerr := strconv.Atoi(component)
+		if aerr != nil || number < 0 {
+			log.WithFields(log.Fields{
+				"at":      "ParseRouterVersion",
+				"version": str,
+				"reason":  "invalid version component",
+			}).Error("error parsing router version")
+			err = errors.New("error parsing router version: invalid version component")
+			version = nil
+			return
+		}
+		version = append(version, number)
+	}
+	return
+}
+
+//
+// Compare two RouterVersions, returning -1, 0 or 1 if this version is older than, the same
+// as or newer than the other.  Missing components count as zero, so 0.9 equals 0.9.0.
+//
+func (version RouterVersion) Compare(other RouterVersion) int {
+	for i := 0; i < len(version) || i < len(other); i++ {
+		a, b := 0, 0
+		if i < len(version) {
+			a = version[i]
+		}
+		if i < len(other) {
+			b = other[i]
+		}
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+	}
+	return 0
+}
+
+//
+// Return true if this RouterVersion is the same as or newer than the other.
+//
+func (version RouterVersion) AtLeast(other RouterVersion) bool {
+	return version.Compare(other) >= 0
+}
+
+//
+// Return the RouterVersion in dotted form.
+//
+func (version RouterVersion) String() string {
+	components := make([]string, len(version))
+	for i, number := range version {
+		components[i] = strconv.Itoa(number)
+	}
+	return strings.Join(components, ".")
+}
+
+//
+// Return the RouterCapabilities advertised in the caps option of this RouterInfo.  A RouterInfo
+// without a caps option advertises no capabilities.
+//
+func (router_info RouterInfo) Capabilities() RouterCapabilities {
+	caps, _ := router_info.Options().Get(ROUTER_INFO_OPTION_CAPS)
+	return ParseRouterCapabilities(caps)
+}
+
+//
+// Return the network ID in the netId option of this RouterInfo, returning an error if it is
+// missing or is not an integer.
+//
+func (router_info RouterInfo) NetworkID() (network_id int, err error) {
+	value, present := router_info.Options().Get(ROUTER_INFO_OPTION_NET_ID)
+	if present {
+		network_id, err = strconv.Atoi(value)
+	}
+	if !present || err != nil {
+		log.WithFields(log.Fields{
+			"at":     "(RouterInfo) NetworkID",
+			"reason": "missing or invalid netId",
+		}).Error("error parsing router info options")
+		err = errors.New("error parsing router info options: missing or invalid netId")
+		network_id = 0
+	}
+	return
+}
+
+//
+// Return the version of the router software in the router.version option of this RouterInfo.
+//
+func (router_info RouterInfo) RouterVersion() (version RouterVersion, err error) {
+	value, present := router_info.Options().Get(ROUTER_INFO_OPTION_VERSION)
+	if !present {
+		log.WithFields(log.Fields{
+			"at":     "(RouterInfo) RouterVersion",
+			"reason": "missing router.version",
+		}).Error("error parsing router info options")
+		err = errors.New("error parsing router info options: missing router.version")
+		return
+	}
+	version, err = ParseRouterVersion(value)
+	return
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func buildRouterInfoWithOptions(options map[string]string) RouterInfo {
+	mapping, _ := GoMapToMapping(options)
+	router_info_data := make([]byte, 0)
+	router_info_data = append(router_info_data, buildRouterIdentity()...)
+	router_info_data = append(router_info_data, buildDate()...)
+	router_info_data = append(router_info_data, 0x00)
+	router_info_data = append(router_info_data, 0x00)
+	router_info_data = append(router_info_data, mapping...)
+	router_info_data = append(router_info_data, make([]byte, 64)...)
+	return RouterInfo(router_info_data)
+}
+
+func TestParseRouterCapabilities(t *testing.T) {
+	assert := assert.New(t)
+
+	capabilities := ParseRouterCapabilities("XOfRD")
+	assert.True(capabilities.Floodfill)
+	assert.True(capabilities.Reachable)
+	assert.False(capabilities.Unreachable)
+	assert.False(capabilities.Hidden)
+	assert.Equal(byte('X'), capabilities.Bandwidth)
+	assert.Equal(byte(ROUTER_CAPS_CONGESTION_MODERATE), capabilities.Congestion)
+	assert.True(capabilities.IsCongested())
+	assert.False(capabilities.RejectsTunnels())
+	assert.True(capabilities.BandwidthAtLeast('P'))
+
+	capabilities = ParseRouterCapabilities("LUGz")
+	assert.False(capabilities.Floodfill)
+	assert.True(capabilities.Unreachable)
+	assert.Equal(byte('L'), capabilities.Bandwidth)
+	assert.True(capabilities.RejectsTunnels())
+	assert.True(capabilities.BandwidthAtLeast('K'))
+	assert.False(capabilities.BandwidthAtLeast('N'))
+
+	capabilities = ParseRouterCapabilities("")
+	assert.Equal(RouterCapabilities{}, capabilities)
+	assert.False(capabilities.BandwidthAtLeast('K'))
+	assert.False(capabilities.IsCongested())
+}
+
+func TestRouterVersionCompare(t *testing.T) {
+	assert := assert.New(t)
+
+	version, err := ParseRouterVersion("0.9.58")
+	if !assert.Nil(err) {
+		return
+	}
+	older, _ := ParseRouterVersion("0.9.9")
+	same, _ := ParseRouterVersion("0.9.58.0")
+	newer, _ := ParseRouterVersion("2.1")
+	assert.Equal(1, version.Compare(older))
+	assert.Equal(0, version.Compare(same))
+	assert.Equal(-1, version.Compare(newer))
+	assert.True(version.AtLeast(same))
+	assert.False(version.AtLeast(newer))
+	assert.Equal("0.9.58", version.String())
+
+	_, err = ParseRouterVersion("0.9.x")
+	if assert.NotNil(err) {
+		assert.Equal("error parsing router version: invalid version component", err.Error())
+	}
+}
+
+func TestRouterInfoOptionAccessors(t *testing.T) {
+	assert := assert.New(t)
+
+	router_info := buildRouterInfoWithOptions(map[string]string{
+		"caps":           "PfR",
+		"netId":          "2",
+		"router.version": "0.9.58",
+	})
+	capabilities := router_info.Capabilities()
+	assert.True(capabilities.Floodfill)
+	assert.Equal(byte('P'), capabilities.Bandwidth)
+	network_id, err := router_info.NetworkID()
+	assert.Nil(err)
+	assert.Equal(I2P_NETWORK_ID, network_id)
+	version, err := router_info.RouterVersion()
+	assert.Nil(err)
+	assert.Equal(RouterVersion{0, 9, 58}, version)
+}
+
+func TestRouterInfoOptionAccessorsWithMissingOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	router_info := buildRouterInfoWithOptions(map[string]string{"netId": "two"})
+	assert.Equal(RouterCapabilities{}, router_info.Capabilities())
+	_, err := router_info.NetworkID()
+	if assert.NotNil(err) {
+		assert.Equal("error parsing router info options: missing or invalid netId", err.Error())
+	}
+	_, err = router_info.RouterVersion()
+	if assert.NotNil(err) {
+		assert.Equal("error parsing router info options: missing router.version", err.Error())
+	}
+}
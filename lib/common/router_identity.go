@@ -29,6 +29,14 @@ func (router_identity RouterIdentity) Certificate() (Certificate, error) {
 	return KeysAndCert(router_identity).Certificate()
 }
 
+//
+// Calculate the Identity Hash of this RouterIdentity, the sha256 of the RouterIdentity, which
+// identifies the router to its peers and in the netdb.
+//
+func (router_identity RouterIdentity) IdentHash() Hash {
+	return HashData(router_identity)
+}
+
 func ReadRouterIdentity(data []byte) (router_identity RouterIdentity, remainder []byte, err error) {
 	keys_and_cert, remainder, err := ReadKeysAndCert(data)
 	router_identity = RouterIdentity(keys_and_cert)
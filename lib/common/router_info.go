@@ -70,16 +70,89 @@ peer_size :: Integer
 options :: Mapping
 
 signature :: Signature
-             length -> 40 bytes
+             length -> 40 bytes or as specified in router_ident's key certificate
 */
 
 import (
 	"errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/hkparker/go-i2p/lib/crypto"
+)
+
+// Size of the legacy DSA Signature in a RouterInfo without a Key Certificate
+const (
+	ROUTER_INFO_SIG_SIZE = 40
+)
+
+// Maximum number of RouterAddresses in a RouterInfo
+const (
+	ROUTER_INFO_MAX_ADDRESSES = 255
 )
 
 type RouterInfo []byte
 
+//
+// Build a RouterInfo from a RouterIdentity, the Date it was published, its RouterAddresses and
+// an options Mapping, serialized in the order defined by the spec and signed with the provided
+// Signer, which must belong to the RouterIdentity's SigningPublicKey.
+//
+func NewRouterInfo(router_identity RouterIdentity, published Date, router_addresses []RouterAddress, options Mapping, signer crypto.Signer) (router_info RouterInfo, err error) {
+	router_identity, _, err = ReadRouterIdentity(router_identity)
+	if err != nil {
+		return
+	}
+	addr_count := len(router_addresses)
+	if addr_count > ROUTER_INFO_MAX_ADDRESSES {
+		log.WithFields(log.Fields{
+			"at":            "NewRouterInfo",
+			"address_count": addr_count,
+			"max_count":     ROUTER_INFO_MAX_ADDRESSES,
+			"reason":        "too many router addresses",
+		}).Error("error building router info")
+		err = errors.New("error building router info: too many router addresses")
+		return
+	}
+	if len(options) < 2 {
+		options = ValuesToMapping(MappingValues{})
+	}
+	data := make([]byte, 0)
+	data = append(data, router_identity...)
+	data = append(data, published[:]...)
+	data = append(data, byte(addr_count))
+	for _, router_address := range router_addresses {
+		data = append(data, router_address...)
+	}
+	data = append(data, 0x00)
+	data = append(data, options...)
+	signature, err := signer.Sign(data)
+	if err != nil {
+		return
+	}
+	router_info = RouterInfo(data)
+	sig_size := router_info.signatureSize()
+	if len(signature) != sig_size {
+		log.WithFields(log.Fields{
+			"at":           "NewRouterInfo",
+			"sig_len":      len(signature),
+			"required_len": sig_size,
+			"reason":       "signature size does not match router identity",
+		}).Error("error building router info")
+		err = errors.New("error building router info: signature size does not match router identity")
+		router_info = nil
+		return
+	}
+	router_info = append(router_info, signature...)
+	if verify_err := router_info.Verify(); verify_err != nil {
+		log.WithFields(log.Fields{
+			"at":     "NewRouterInfo",
+			"reason": "signer does not match router identity",
+		}).Error("error building router info")
+		err = errors.New("error building router info: signer does not match router identity")
+		router_info = nil
+	}
+	return
+}
+
 //
 // Read a RouterIdentity from the RouterInfo, returning the RouterIdentity and any errors
 // encountered parsing the RouterIdentity.
@@ -97,7 +170,7 @@ func (router_info RouterInfo) IdentHash() (h Hash, err error) {
 	var ri RouterIdentity
 	ri, err = router_info.RouterIdentity()
 	if err == nil {
-		h = HashData(ri)
+		h = ri.IdentHash()
 	}
 	return
 }
@@ -204,13 +277,79 @@ func (router_info RouterInfo) Options() (mapping Mapping) {
 }
 
 //
-// Return the 40 bytes that follow the Mapping in the RouterInfo.
+// Return the Signature that follows the Mapping in the RouterInfo, sized as specified
+// in the RouterIdentity's Key Certificate if present or the 40 byte legacy DSA size.
+// A partial Signature is returned if data is missing.
 //
 func (router_info RouterInfo) Signature() (signature Signature) {
 	head := router_info.optionsLocation()
-	size := head + router_info.optionsSize()
-	// TODO: signature is not always 40 bytes, is 40 bytes for DSA only
-	signature = Signature(router_info[size : size+40])
+	start := head + router_info.optionsSize()
+	end := start + router_info.signatureSize()
+	router_info_len := len(router_info)
+	if start > router_info_len {
+		start = router_info_len
+	}
+	if end > router_info_len {
+		end = router_info_len
+	}
+	signature = Signature(router_info[start:end])
+	return
+}
+
+//
+// Verify the Signature of this RouterInfo against the SigningPublicKey in its RouterIdentity,
+// returning nil if the signature is valid or any errors encountered parsing the RouterInfo
+// or verifying the Signature.  Data following the Signature is rejected.
+//
+func (router_info RouterInfo) Verify() (err error) {
+	router_identity, err := router_info.RouterIdentity()
+	if err != nil {
+		return
+	}
+	signing_public_key, err := router_identity.SigningPublicKey()
+	if err != nil {
+		return
+	}
+	if signing_public_key == nil {
+		log.WithFields(log.Fields{
+			"at":     "(RouterInfo) Verify",
+			"reason": "unsupported signing key type",
+		}).Error("error verifying router info")
+		err = errors.New("error verifying router info: unsupported signing key type")
+		return
+	}
+	_, err = router_info.RouterAddresses()
+	if err != nil {
+		return
+	}
+	data_end := router_info.optionsLocation() + router_info.optionsSize()
+	sig_end := data_end + router_info.signatureSize()
+	router_info_len := len(router_info)
+	if router_info_len < sig_end {
+		log.WithFields(log.Fields{
+			"at":           "(RouterInfo) Verify",
+			"data_len":     router_info_len,
+			"required_len": sig_end,
+			"reason":       "not enough data",
+		}).Error("error verifying router info")
+		err = errors.New("error verifying router info: not enough data")
+		return
+	}
+	if router_info_len > sig_end {
+		log.WithFields(log.Fields{
+			"at":           "(RouterInfo) Verify",
+			"data_len":     router_info_len,
+			"required_len": sig_end,
+			"reason":       "data after signature",
+		}).Error("error verifying router info")
+		err = errors.New("error verifying router info: data after signature")
+		return
+	}
+	verifier, err := signing_public_key.NewVerifier()
+	if err != nil {
+		return
+	}
+	err = verifier.Verify(router_info[:data_end], router_info[data_end:sig_end])
 	return
 }
 
@@ -261,6 +400,33 @@ func (router_info RouterInfo) optionsLocation() (location int) {
 //
 func (router_info RouterInfo) optionsSize() (size int) {
 	head := router_info.optionsLocation()
+	if len(router_info) < head+2 {
+		return
+	}
 	size = Integer(router_info[head:head+2]) + 2
 	return
 }
+
+//
+// Used during parsing to determine the size of the Signature in the RouterInfo, as specified
+// in the RouterIdentity's Key Certificate if present or the legacy DSA size.  The size is 0
+// when the policy for signature types disables the type.
+//
+func (router_info RouterInfo) signatureSize() (size int) {
+	size = ROUTER_INFO_SIG_SIZE
+	router_identity, err := router_info.RouterIdentity()
+	if err != nil {
+		return
+	}
+	cert, err := router_identity.Certificate()
+	if err != nil {
+		return
+	}
+	cert_type, _ := cert.Type()
+	if cert_type == CERT_KEY {
+		size = KeyCertificate(cert).SignatureSize()
+	} else {
+		size = SignatureSizeByType(KEYCERT_SIGN_DSA_SHA1)
+	}
+	return
+}
@@ -2,7 +2,10 @@ package common
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
 	"fmt"
+	"github.com/hkparker/go-i2p/lib/crypto"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -39,7 +42,7 @@ func buildFullRouterInfo() RouterInfo {
 	router_info_data = append(router_info_data, buildRouterAddress("foo")...)
 	router_info_data = append(router_info_data, 0x00)
 	router_info_data = append(router_info_data, buildMapping()...)
-	router_info_data = append(router_info_data, make([]byte, 40)...)
+	router_info_data = append(router_info_data, make([]byte, 64)...)
 	return RouterInfo(router_info_data)
 }
 
@@ -124,7 +127,7 @@ func TestRouterAddressesReturnsAddressesWithMultiple(t *testing.T) {
 	router_info_data = append(router_info_data, buildRouterAddress("foo2")...)
 	router_info_data = append(router_info_data, 0x00)
 	router_info_data = append(router_info_data, buildMapping()...)
-	router_info_data = append(router_info_data, make([]byte, 40)...)
+	router_info_data = append(router_info_data, make([]byte, 64)...)
 	router_info := RouterInfo(router_info_data)
 
 	count, err := router_info.RouterAddressCount()
@@ -172,7 +175,7 @@ func TestSignatureIsCorrectSize(t *testing.T) {
 
 	router_info := buildFullRouterInfo()
 	signature := router_info.Signature()
-	assert.Equal(40, len(signature))
+	assert.Equal(64, len(signature))
 }
 
 func TestRouterIdentityIsCorrect(t *testing.T) {
@@ -189,3 +192,212 @@ func TestRouterIdentityIsCorrect(t *testing.T) {
 		),
 	)
 }
+
+func buildSignedRouterInfo() (RouterInfo, error) {
+	public_key, private_key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	router_info_data := make([]byte, 256+128-len(public_key))
+	router_info_data = append(router_info_data, public_key...)
+	router_info_data = append(router_info_data, []byte{0x05, 0x00, 0x04, 0x00, 0x07, 0x00, 0x00}...)
+	router_info_data = append(router_info_data, buildDate()...)
+	router_info_data = append(router_info_data, 0x01)
+	router_info_data = append(router_info_data, buildRouterAddress("foo")...)
+	router_info_data = append(router_info_data, 0x00)
+	router_info_data = append(router_info_data, buildMapping()...)
+	router_info_data = append(router_info_data, ed25519.Sign(private_key, router_info_data)...)
+	return RouterInfo(router_info_data), nil
+}
+
+func TestVerifyWithValidEd25519Signature(t *testing.T) {
+	assert := assert.New(t)
+
+	router_info, err := buildSignedRouterInfo()
+	if assert.Nil(err) {
+		assert.Nil(router_info.Verify())
+	}
+}
+
+func TestVerifyWithValidDSASignature(t *testing.T) {
+	assert := assert.New(t)
+
+	var sk crypto.DSAPrivateKey
+	sk, err := sk.Generate()
+	if !assert.Nil(err) {
+		return
+	}
+	pk, err := sk.Public()
+	if !assert.Nil(err) {
+		return
+	}
+	router_info_data := make([]byte, 256)
+	router_info_data = append(router_info_data, pk[:]...)
+	router_info_data = append(router_info_data, []byte{0x00, 0x00, 0x00}...)
+	router_info_data = append(router_info_data, buildDate()...)
+	router_info_data = append(router_info_data, 0x00)
+	router_info_data = append(router_info_data, 0x00)
+	router_info_data = append(router_info_data, buildMapping()...)
+	signer, err := sk.NewSigner()
+	if !assert.Nil(err) {
+		return
+	}
+	sig, err := signer.Sign(router_info_data)
+	if assert.Nil(err) {
+		router_info := RouterInfo(append(router_info_data, sig...))
+		assert.Equal(40, len(router_info.Signature()))
+		assert.Nil(router_info.Verify())
+	}
+}
+
+func TestVerifyReturnsErrorWithForgedData(t *testing.T) {
+	assert := assert.New(t)
+
+	router_info, err := buildSignedRouterInfo()
+	if assert.Nil(err) {
+		router_info[391+1] ^= 0xff
+		assert.Equal(crypto.ErrInvalidSignature, router_info.Verify())
+	}
+}
+
+func TestVerifyReturnsErrorWithForgedSignature(t *testing.T) {
+	assert := assert.New(t)
+
+	router_info, err := buildSignedRouterInfo()
+	if assert.Nil(err) {
+		router_info[len(router_info)-1] ^= 0xff
+		assert.Equal(crypto.ErrInvalidSignature, router_info.Verify())
+	}
+}
+
+func TestVerifyReturnsErrorWithTruncatedSignature(t *testing.T) {
+	assert := assert.New(t)
+
+	router_info, err := buildSignedRouterInfo()
+	if assert.Nil(err) {
+		router_info = router_info[:len(router_info)-10]
+		err = router_info.Verify()
+		if assert.NotNil(err) {
+			assert.Equal("error verifying router info: not enough data", err.Error())
+		}
+	}
+}
+
+func TestVerifyReturnsErrorWithDataAfterSignature(t *testing.T) {
+	assert := assert.New(t)
+
+	router_info, err := buildSignedRouterInfo()
+	if assert.Nil(err) {
+		router_info = append(router_info, 0x00)
+		err = router_info.Verify()
+		if assert.NotNil(err) {
+			assert.Equal("error verifying router info: data after signature", err.Error())
+		}
+	}
+}
+
+func TestNewRouterInfoIsSignedAndParses(t *testing.T) {
+	assert := assert.New(t)
+
+	var sk crypto.DSAPrivateKey
+	sk, err := sk.Generate()
+	if !assert.Nil(err) {
+		return
+	}
+	pk, err := sk.Public()
+	if !assert.Nil(err) {
+		return
+	}
+	signer, err := sk.NewSigner()
+	if !assert.Nil(err) {
+		return
+	}
+	router_identity_data := make([]byte, 256)
+	router_identity_data = append(router_identity_data, pk[:]...)
+	router_identity_data = append(router_identity_data, []byte{0x00, 0x00, 0x00}...)
+	router_identity := RouterIdentity(router_identity_data)
+	var published Date
+	copy(published[:], buildDate())
+	router_addresses := []RouterAddress{buildRouterAddress("foo0"), buildRouterAddress("foo1")}
+
+	router_info, err := NewRouterInfo(router_identity, published, router_addresses, buildMapping(), signer)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Nil(router_info.Verify())
+	parsed_identity, err := router_info.RouterIdentity()
+	assert.Nil(err)
+	assert.Equal(router_identity, parsed_identity)
+	parsed_published, err := router_info.Published()
+	assert.Nil(err)
+	assert.Equal(published, parsed_published)
+	parsed_addresses, err := router_info.RouterAddresses()
+	if assert.Nil(err) && assert.Equal(2, len(parsed_addresses)) {
+		assert.Equal(router_addresses[0], parsed_addresses[0])
+		assert.Equal(router_addresses[1], parsed_addresses[1])
+	}
+	assert.Equal(buildMapping(), router_info.Options())
+	assert.Equal(ROUTER_INFO_SIG_SIZE, len(router_info.Signature()))
+}
+
+func TestNewRouterInfoWithMismatchedSigner(t *testing.T) {
+	assert := assert.New(t)
+
+	var sk crypto.DSAPrivateKey
+	sk, err := sk.Generate()
+	if !assert.Nil(err) {
+		return
+	}
+	signer, err := sk.NewSigner()
+	if !assert.Nil(err) {
+		return
+	}
+	var published Date
+	_, err = NewRouterInfo(buildRouterIdentity(), published, nil, buildMapping(), signer)
+	if assert.NotNil(err) {
+		assert.Equal("error building router info: signature size does not match router identity", err.Error())
+	}
+}
+
+func TestNewRouterInfoWithSignerForAnotherKey(t *testing.T) {
+	assert := assert.New(t)
+
+	var sk crypto.DSAPrivateKey
+	sk, err := sk.Generate()
+	if !assert.Nil(err) {
+		return
+	}
+	pk, err := sk.Public()
+	if !assert.Nil(err) {
+		return
+	}
+	var other_sk crypto.DSAPrivateKey
+	other_sk, err = other_sk.Generate()
+	if !assert.Nil(err) {
+		return
+	}
+	signer, err := other_sk.NewSigner()
+	if !assert.Nil(err) {
+		return
+	}
+	router_identity_data := make([]byte, 256)
+	router_identity_data = append(router_identity_data, pk[:]...)
+	router_identity_data = append(router_identity_data, []byte{0x00, 0x00, 0x00}...)
+	var published Date
+	router_info, err := NewRouterInfo(RouterIdentity(router_identity_data), published, nil, buildMapping(), signer)
+	if assert.NotNil(err) {
+		assert.Equal("error building router info: signer does not match router identity", err.Error())
+	}
+	assert.Nil(router_info)
+}
+
+func TestNewRouterInfoWithTooManyAddresses(t *testing.T) {
+	assert := assert.New(t)
+
+	var published Date
+	router_addresses := make([]RouterAddress, ROUTER_INFO_MAX_ADDRESSES+1)
+	_, err := NewRouterInfo(buildRouterIdentity(), published, router_addresses, buildMapping(), nil)
+	if assert.NotNil(err) {
+		assert.Equal("error building router info: too many router addresses", err.Error())
+	}
+}
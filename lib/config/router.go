@@ -1,15 +1,27 @@
 package config
 
+import (
+	"path/filepath"
+)
+
 // router.config options
 type RouterConfig struct {
+	// path to the directory holding the router's keys and other private state
+	BaseDir string
 	// netdb configuration
 	NetDb *NetDbConfig
 	// configuration for bootstrapping into the network
 	Bootstrap *BootstrapConfig
 }
 
+// path to the router.keys.dat file holding the router's identity and private keys
+func (cfg *RouterConfig) RouterKeysPath() string {
+	return filepath.Join(cfg.BaseDir, "router.keys.dat")
+}
+
 // defaults for router
 var DefaultRouterConfig = &RouterConfig{
+	BaseDir:   ".",
 	NetDb:     &DefaultNetDbConfig,
 	Bootstrap: &DefaultBootstrapConfig,
 }
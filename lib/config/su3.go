@@ -1,9 +1,15 @@
 package config
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
 	"errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/hkparker/go-i2p/lib/common"
+	"github.com/hkparker/go-i2p/lib/crypto"
 	"strings"
 	"unicode/utf8"
 )
@@ -17,6 +23,7 @@ const SU3_MAGIC_BYTE_LEN = 6
 const SU3_SIGNATURE_TYPE_LEN = 2
 const SU3_SIGNATURE_LENGTH_LEN = 2
 const SU3_CONTENT_LENGTH_LEN = 8
+const SU3_HEADER_LEN = 40
 
 var SU3_SIGNATURE_TYPE_DSA_SHA1 = "DSA-SHA1"
 var SU3_SIGNATURE_TYPE_ECDSA_SHA256_P256 = "ECDSA-SHA256-P256"
@@ -78,6 +85,9 @@ var ERR_SU3_FILE_TYPE_UNKNOWN = errors.New("unknown file type")
 var ERR_SU3_CONTENT_TYPE_UNKNOWN = errors.New("unknown content type")
 var ERR_SU3_VERSION_NOT_UTF8 = errors.New("version not utf8")
 var ERR_SU3_SIGNER_ID_NOT_UTF8 = errors.New("version not utf8")
+var ERR_SU3_SIGNATURE_INVALID = errors.New("invalid su3 signature")
+var ERR_SU3_SIGNATURE_TYPE_UNSUPPORTED = errors.New("unsupported signature type for certificate")
+var ERR_SU3_CERTIFICATE_KEY_MISMATCH = errors.New("certificate key does not match signature type")
 
 type SU3 struct {
 	Raw               []byte
@@ -97,6 +107,106 @@ type SU3 struct {
 
 func OpenSU3() {}
 
+// verify the signature over the su3 header and content with the signer's public key
+func (su3 SU3) Verify(signing_public_key crypto.SigningPublicKey) error {
+	signed_len := SU3_HEADER_LEN + su3.VersionLength + su3.SignerIDLength + su3.ContentLength
+	if len(su3.Raw) < signed_len {
+		return ERR_NOT_ENOUGH_SU3_DATA
+	}
+	verifier, err := signing_public_key.NewVerifier()
+	if err != nil {
+		return err
+	}
+	if err := verifier.Verify(su3.Raw[:signed_len], su3.Signature); err != nil {
+		log.WithFields(log.Fields{
+			"at":        "(SU3) Verify",
+			"signer_id": su3.SignerID,
+			"reason":    err.Error(),
+		}).Debug(ERR_SU3_SIGNATURE_INVALID)
+		return ERR_SU3_SIGNATURE_INVALID
+	}
+	return nil
+}
+
+//
+// verify the signature with the public key in the signer's certificate, as
+// distributed with routers for each reseed and update signer
+//
+func (su3 SU3) VerifyWithCertificate(cert *x509.Certificate) error {
+	signing_public_key, err := certificateSigningPublicKey(su3.SignatureType, cert)
+	if err != nil {
+		return err
+	}
+	return su3.Verify(signing_public_key)
+}
+
+// convert the public key of an x509 certificate to the key for an su3 signature type
+func certificateSigningPublicKey(signature_type string, cert *x509.Certificate) (crypto.SigningPublicKey, error) {
+	switch signature_type {
+	case SU3_SIGNATURE_TYPE_RSA_SHA256_2048:
+		var k crypto.RSA2048PublicKey
+		err := fillRSAPublicKey(k[:], cert)
+		return k, err
+	case SU3_SIGNATURE_TYPE_RSA_SHA384_3072:
+		var k crypto.RSA3072PublicKey
+		err := fillRSAPublicKey(k[:], cert)
+		return k, err
+	case SU3_SIGNATURE_TYPE_RSA_SHA512_4096:
+		var k crypto.RSA4096PublicKey
+		err := fillRSAPublicKey(k[:], cert)
+		return k, err
+	case SU3_SIGNATURE_TYPE_ECDSA_SHA256_P256:
+		var k crypto.ECP256PublicKey
+		err := fillECDSAPublicKey(k[:], elliptic.P256(), cert)
+		return k, err
+	case SU3_SIGNATURE_TYPE_ECDSA_SHA384_P384:
+		var k crypto.ECP384PublicKey
+		err := fillECDSAPublicKey(k[:], elliptic.P384(), cert)
+		return k, err
+	case SU3_SIGNATURE_TYPE_ECDSA_SHA512_P521:
+		var k crypto.ECP521PublicKey
+		err := fillECDSAPublicKey(k[:], elliptic.P521(), cert)
+		return k, err
+	case SU3_SIGNATURE_TYPE_EdDSA_SHA512_Ed25519ph:
+		var k crypto.Ed25519phPublicKey
+		err := fillEd25519PublicKey(k[:], cert)
+		return k, err
+	}
+	return nil, ERR_SU3_SIGNATURE_TYPE_UNSUPPORTED
+}
+
+// store the modulus of a certificate's rsa key, which must match the key size
+func fillRSAPublicKey(k []byte, cert *x509.Certificate) error {
+	rsa_key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok || rsa_key.N.BitLen() != len(k)*8 || rsa_key.E != crypto.RSA_PUBLIC_EXPONENT {
+		return ERR_SU3_CERTIFICATE_KEY_MISMATCH
+	}
+	rsa_key.N.FillBytes(k)
+	return nil
+}
+
+// store the point of a certificate's ecdsa key, which must be on the expected curve
+func fillECDSAPublicKey(k []byte, curve elliptic.Curve, cert *x509.Certificate) error {
+	ec_key, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || ec_key.Curve != curve {
+		return ERR_SU3_CERTIFICATE_KEY_MISMATCH
+	}
+	size := len(k) / 2
+	ec_key.X.FillBytes(k[:size])
+	ec_key.Y.FillBytes(k[size:])
+	return nil
+}
+
+// store a certificate's ed25519 key, which Ed25519ph uses to sign the sha512 hash of the file
+func fillEd25519PublicKey(k []byte, cert *x509.Certificate) error {
+	ed_key, ok := cert.PublicKey.(ed25519.PublicKey)
+	if !ok || len(ed_key) != len(k) {
+		return ERR_SU3_CERTIFICATE_KEY_MISMATCH
+	}
+	copy(k, ed_key)
+	return nil
+}
+
 func ReadSU3(data []byte) (SU3, error) {
 	su3 := SU3{
 		Raw: data,
@@ -1,8 +1,21 @@
 package config
 
 import (
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"github.com/hkparker/go-i2p/lib/crypto"
 	"github.com/stretchr/testify/assert"
+	"math/big"
 	"testing"
+	"time"
 )
 
 func TestCheckMagicBytes(t *testing.T) {
@@ -456,3 +469,133 @@ func TestGetSignature(t *testing.T) {
 	assert.Equal(ERR_NOT_ENOUGH_SU3_DATA, err)
 	assert.Equal([]byte{}, signature)
 }
+
+// build the signed portion of an su3 file: the header, version, signer id and content
+func unsignedTestSU3(signature_type []byte, signature_length int, content []byte) []byte {
+	version := []byte("1700000000\x00\x00\x00\x00\x00\x00")
+	signer_id := []byte("test@mail.i2p")
+	data := []byte("I2Psu3")
+	data = append(data, 0x00, 0x00)
+	data = append(data, signature_type...)
+	data = append(data, byte(signature_length>>8), byte(signature_length))
+	data = append(data, 0x00, byte(len(version)))
+	data = append(data, 0x00, byte(len(signer_id)))
+	content_length := make([]byte, 8)
+	binary.BigEndian.PutUint64(content_length, uint64(len(content)))
+	data = append(data, content_length...)
+	data = append(data, 0x00, 0x00, 0x00, 0x03)
+	data = append(data, make([]byte, 12)...)
+	data = append(data, version...)
+	data = append(data, signer_id...)
+	data = append(data, content...)
+	return data
+}
+
+// build a signed su3 file with a self-signed RSA-SHA256-2048 certificate for the signer
+func signedTestSU3(t *testing.T, content []byte) ([]byte, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test@mail.i2p"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := unsignedTestSU3([]byte{0x00, 0x04}, 256, content)
+	digest := sha256.Sum256(data)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, stdcrypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, signature...)
+	return data, cert
+}
+
+func TestSU3VerifyWithCertificate(t *testing.T) {
+	assert := assert.New(t)
+
+	data, cert := signedTestSU3(t, []byte("reseed data"))
+	su3, err := ReadSU3(data)
+	assert.Nil(err)
+	assert.Equal(SU3_SIGNATURE_TYPE_RSA_SHA256_2048, su3.SignatureType)
+	assert.Nil(su3.VerifyWithCertificate(cert))
+}
+
+func TestSU3VerifyRejectsTamperedContent(t *testing.T) {
+	assert := assert.New(t)
+
+	data, cert := signedTestSU3(t, []byte("reseed data"))
+	data[SU3_HEADER_LEN+16+13] ^= 0xff
+	su3, err := ReadSU3(data)
+	assert.Nil(err)
+	assert.Equal(ERR_SU3_SIGNATURE_INVALID, su3.VerifyWithCertificate(cert))
+}
+
+func TestSU3VerifyRejectsMismatchedCertificate(t *testing.T) {
+	assert := assert.New(t)
+
+	data, _ := signedTestSU3(t, []byte("reseed data"))
+	su3, err := ReadSU3(data)
+	assert.Nil(err)
+
+	ec_key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(err)
+	cert := &x509.Certificate{PublicKey: &ec_key.PublicKey}
+	assert.Equal(ERR_SU3_CERTIFICATE_KEY_MISMATCH, su3.VerifyWithCertificate(cert))
+
+	su3.SignatureType = SU3_SIGNATURE_TYPE_DSA_SHA1
+	assert.Equal(ERR_SU3_SIGNATURE_TYPE_UNSUPPORTED, su3.VerifyWithCertificate(cert))
+}
+
+func TestSU3VerifyEd25519phWithCertificate(t *testing.T) {
+	assert := assert.New(t)
+
+	public_key, private_key, err := ed25519.GenerateKey(rand.Reader)
+	if !assert.Nil(err) {
+		return
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test@mail.i2p"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, public_key, private_key)
+	if !assert.Nil(err) {
+		return
+	}
+	cert, err := x509.ParseCertificate(der)
+	if !assert.Nil(err) {
+		return
+	}
+
+	data := unsignedTestSU3([]byte{0x00, 0x08}, 64, []byte("reseed data"))
+	var signing_private_key crypto.Ed25519phPrivateKey
+	copy(signing_private_key[:], private_key.Seed())
+	signer, _ := signing_private_key.NewSigner()
+	signature, err := signer.Sign(data)
+	assert.Nil(err)
+	su3, err := ReadSU3(append(data, signature...))
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal(SU3_SIGNATURE_TYPE_EdDSA_SHA512_Ed25519ph, su3.SignatureType)
+	assert.Nil(su3.VerifyWithCertificate(cert))
+
+	su3.Raw[SU3_HEADER_LEN+16+13] ^= 0xff
+	assert.Equal(ERR_SU3_SIGNATURE_INVALID, su3.VerifyWithCertificate(cert))
+
+	_, rsa_cert := signedTestSU3(t, []byte("reseed data"))
+	assert.Equal(ERR_SU3_CERTIFICATE_KEY_MISMATCH, su3.VerifyWithCertificate(rsa_cert))
+}
@@ -1 +1,38 @@
 package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+)
+
+var ErrAESBadDataSize = errors.New("aes data is not a multiple of the block size")
+
+// encrypt data in place with aes-256-cbc, the data must be a multiple of the block size
+func AESEncryptCBC(key, iv, data []byte) (err error) {
+	block, err := aesCBCBlock(key, iv, data)
+	if err == nil {
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	}
+	return
+}
+
+// decrypt data in place with aes-256-cbc, the data must be a multiple of the block size
+func AESDecryptCBC(key, iv, data []byte) (err error) {
+	block, err := aesCBCBlock(key, iv, data)
+	if err == nil {
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+	}
+	return
+}
+
+func aesCBCBlock(key, iv, data []byte) (block cipher.Block, err error) {
+	if len(key) != 32 || len(iv) != aes.BlockSize {
+		err = ErrInvalidKeyFormat
+	} else if len(data)%aes.BlockSize != 0 {
+		err = ErrAESBadDataSize
+	} else {
+		block, err = aes.NewCipher(key)
+	}
+	return
+}
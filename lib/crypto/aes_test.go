@@ -0,0 +1,43 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func TestAESCBCRoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	iv := make([]byte, 16)
+	io.ReadFull(rand.Reader, key)
+	io.ReadFull(rand.Reader, iv)
+	data := make([]byte, 64)
+	io.ReadFull(rand.Reader, data)
+	plaintext := append([]byte{}, data...)
+
+	if err := AESEncryptCBC(key, iv, data); err != nil {
+		t.Fatalf("failed to encrypt: %s", err.Error())
+	}
+	if bytes.Equal(data, plaintext) {
+		t.Fatalf("data was not encrypted in place")
+	}
+	if err := AESDecryptCBC(key, iv, data); err != nil {
+		t.Fatalf("failed to decrypt: %s", err.Error())
+	}
+	if !bytes.Equal(data, plaintext) {
+		t.Logf("decrypted data does not match")
+		t.Fail()
+	}
+}
+
+func TestAESCBCBadSizes(t *testing.T) {
+	if AESEncryptCBC(make([]byte, 32), make([]byte, 16), make([]byte, 15)) != ErrAESBadDataSize {
+		t.Logf("partial block was not rejected")
+		t.Fail()
+	}
+	if AESDecryptCBC(make([]byte, 16), make([]byte, 16), make([]byte, 16)) != ErrInvalidKeyFormat {
+		t.Logf("aes-128 key was not rejected")
+		t.Fail()
+	}
+}
@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"errors"
+	"filippo.io/edwards25519"
+)
+
+var ErrBadBlindingFactor = errors.New("bad blinding factor")
+
+//
+// reduce 64 bytes of uniformly random data modulo the order of the ed25519 group,
+// returning the 32 byte little-endian scalar used to blind keys
+//
+func Ed25519BlindingFactor(seed []byte) (alpha []byte, err error) {
+	s, err := edwards25519.NewScalar().SetUniformBytes(seed)
+	if err == nil {
+		alpha = s.Bytes()
+	} else {
+		err = ErrBadBlindingFactor
+	}
+	return
+}
+
+// blind an ed25519 public key, computing A' = A + [alpha]B
+func (k Ed25519PublicKey) Blind(alpha []byte) (blinded Ed25519PublicKey, err error) {
+	a, err := edwards25519.NewScalar().SetCanonicalBytes(alpha)
+	if err != nil {
+		err = ErrBadBlindingFactor
+		return
+	}
+	A, err := new(edwards25519.Point).SetBytes(k[:])
+	if err != nil {
+		err = ErrInvalidKeyFormat
+		return
+	}
+	aB := new(edwards25519.Point).ScalarBaseMult(a)
+	copy(blinded[:], new(edwards25519.Point).Add(A, aB).Bytes())
+	return
+}
+
+// blind an ed25519 private key, computing a' = a + alpha mod L from the expanded seed
+// and returning a RedDSA signer for the blinded key
+func (k Ed25519PrivateKey) Blind(alpha []byte) (signer *RedDSASigner, err error) {
+	blinded, err := k.RedDSA().Blind(alpha)
+	if err == nil {
+		signer, err = blinded.newRedDSASigner()
+	}
+	return
+}
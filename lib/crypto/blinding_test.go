@@ -0,0 +1,99 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func generateBlindingFactor(t *testing.T) []byte {
+	seed := make([]byte, 64)
+	io.ReadFull(rand.Reader, seed)
+	alpha, err := Ed25519BlindingFactor(seed)
+	if err != nil {
+		t.Fatalf("failed to generate blinding factor: %s", err.Error())
+	}
+	return alpha
+}
+
+func TestEd25519BlindingFactor(t *testing.T) {
+	_, err := Ed25519BlindingFactor(make([]byte, 32))
+	if err != ErrBadBlindingFactor {
+		t.Logf("short seed did not fail: %v", err)
+		t.Fail()
+	}
+	alpha, err := Ed25519BlindingFactor(make([]byte, 64))
+	if err != nil || len(alpha) != 32 {
+		t.Logf("failed to reduce 64 byte seed: %v", err)
+		t.Fail()
+	}
+}
+
+func TestEd25519Blinding(t *testing.T) {
+	public_key, private_key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	var pk Ed25519PublicKey
+	var sk Ed25519PrivateKey
+	copy(pk[:], public_key)
+	copy(sk[:], private_key.Seed())
+	alpha := generateBlindingFactor(t)
+
+	blinded, err := pk.Blind(alpha)
+	if err != nil {
+		t.Fatalf("failed to blind public key: %s", err.Error())
+	}
+	if bytes.Equal(blinded[:], pk[:]) {
+		t.Logf("blinded public key is unchanged")
+		t.Fail()
+	}
+	signer, err := sk.Blind(alpha)
+	if err != nil {
+		t.Fatalf("failed to blind private key: %s", err.Error())
+	}
+	if signer.Public() != blinded {
+		t.Logf("blinded private key does not match blinded public key")
+		t.Fail()
+	}
+
+	data := make([]byte, 512)
+	io.ReadFull(rand.Reader, data)
+	sig, err := signer.Sign(data)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+	other_sig, _ := signer.Sign(data)
+	if bytes.Equal(sig, other_sig) {
+		t.Logf("RedDSA signatures did not use random nonces")
+		t.Fail()
+	}
+	v, _ := blinded.NewVerifier()
+	err = v.Verify(data, sig)
+	if err != nil {
+		t.Logf("blinded signature did not verify: %s", err.Error())
+		t.Fail()
+	}
+	data[0] ^= 0xff
+	err = v.Verify(data, sig)
+	if err == nil {
+		t.Logf("verified invalid blinded signature")
+		t.Fail()
+	}
+}
+
+func TestEd25519BlindingWithNonCanonicalFactor(t *testing.T) {
+	alpha := bytes.Repeat([]byte{0xff}, 32)
+	var pk Ed25519PublicKey
+	var sk Ed25519PrivateKey
+	if _, err := pk.Blind(alpha); err != ErrBadBlindingFactor {
+		t.Logf("blinded public key with non-canonical factor: %v", err)
+		t.Fail()
+	}
+	if _, err := sk.Blind(alpha); err != ErrBadBlindingFactor {
+		t.Logf("blinded private key with non-canonical factor: %v", err)
+		t.Fail()
+	}
+}
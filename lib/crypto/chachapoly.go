@@ -0,0 +1,98 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"math"
+)
+
+const (
+	CHACHAPOLY_KEY_SIZE   = chacha20poly1305.KeySize
+	CHACHAPOLY_MAC_SIZE   = 16
+	CHACHAPOLY_NONCE_SIZE = chacha20poly1305.NonceSize
+	// the largest nonce, reserved by noise and never used to encrypt
+	CHACHAPOLY_MAX_NONCE = math.MaxUint64
+)
+
+var ErrChaChaPolyNonceExhausted = errors.New("chacha20-poly1305 nonce exhausted")
+var ErrChaChaPolyDecryptionFailed = errors.New("chacha20-poly1305 decryption failed")
+
+//
+// chacha20-poly1305 with the nonce counter used by noise and every i2p protocol built on it,
+// the 12 byte nonce is four zero bytes followed by the little-endian 64 bit counter.  Seal and
+// Open append to dst like cipher.AEAD and do not allocate when dst has room.
+//
+type ChaChaPoly struct {
+	aead  cipher.AEAD
+	n     uint64
+	nonce [CHACHAPOLY_NONCE_SIZE]byte
+}
+
+// create a cipher for a 32 byte key with the nonce counter at zero
+func NewChaChaPoly(key []byte) (c *ChaChaPoly, err error) {
+	c = new(ChaChaPoly)
+	err = c.setKey(key)
+	if err != nil {
+		c = nil
+	}
+	return
+}
+
+func (c *ChaChaPoly) setKey(key []byte) (err error) {
+	if len(key) != CHACHAPOLY_KEY_SIZE {
+		return ErrInvalidKeyFormat
+	}
+	c.aead, err = chacha20poly1305.New(key)
+	c.n = 0
+	return
+}
+
+// the nonce the next Seal or Open will use
+func (c *ChaChaPoly) Nonce() uint64 {
+	return c.n
+}
+
+// set the nonce the next Seal or Open will use, for protocols that send it with each message
+func (c *ChaChaPoly) SetNonce(n uint64) {
+	c.n = n
+}
+
+// encrypt with the next nonce and append the ciphertext and mac to dst
+func (c *ChaChaPoly) Seal(dst, plaintext, ad []byte) ([]byte, error) {
+	if c.n == CHACHAPOLY_MAX_NONCE {
+		return dst, ErrChaChaPolyNonceExhausted
+	}
+	dst = c.SealWithNonce(dst, c.n, plaintext, ad)
+	c.n++
+	return dst, nil
+}
+
+// decrypt with the next nonce and append the plaintext to dst, the nonce only advances on success
+func (c *ChaChaPoly) Open(dst, ciphertext, ad []byte) ([]byte, error) {
+	if c.n == CHACHAPOLY_MAX_NONCE {
+		return dst, ErrChaChaPolyNonceExhausted
+	}
+	dst, err := c.OpenWithNonce(dst, c.n, ciphertext, ad)
+	if err == nil {
+		c.n++
+	}
+	return dst, err
+}
+
+// encrypt with an explicit nonce, leaving the counter unchanged
+func (c *ChaChaPoly) SealWithNonce(dst []byte, n uint64, plaintext, ad []byte) []byte {
+	binary.LittleEndian.PutUint64(c.nonce[4:], n)
+	return c.aead.Seal(dst, c.nonce[:], plaintext, ad)
+}
+
+// decrypt with an explicit nonce, leaving the counter unchanged
+func (c *ChaChaPoly) OpenWithNonce(dst []byte, n uint64, ciphertext, ad []byte) ([]byte, error) {
+	binary.LittleEndian.PutUint64(c.nonce[4:], n)
+	out, err := c.aead.Open(dst, c.nonce[:], ciphertext, ad)
+	if err != nil {
+		return dst, ErrChaChaPolyDecryptionFailed
+	}
+	return out, nil
+}
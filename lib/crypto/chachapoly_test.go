@@ -0,0 +1,107 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"golang.org/x/crypto/chacha20poly1305"
+	"io"
+	"testing"
+)
+
+func newTestChaChaPoly(t *testing.T) (*ChaChaPoly, *ChaChaPoly, []byte) {
+	key := make([]byte, CHACHAPOLY_KEY_SIZE)
+	io.ReadFull(rand.Reader, key)
+	sender, err := NewChaChaPoly(key)
+	if err != nil {
+		t.Fatalf("failed to create cipher: %s", err.Error())
+	}
+	receiver, _ := NewChaChaPoly(key)
+	return sender, receiver, key
+}
+
+func TestChaChaPolyNonceCounter(t *testing.T) {
+	sender, receiver, key := newTestChaChaPoly(t)
+	ad := []byte("associated data")
+	for i := 0; i < 3; i++ {
+		plaintext := []byte{byte(i), 0x01, 0x02}
+		ciphertext, err := sender.Seal(nil, plaintext, ad)
+		if err != nil {
+			t.Fatalf("failed to seal: %s", err.Error())
+		}
+		if len(ciphertext) != len(plaintext)+CHACHAPOLY_MAC_SIZE {
+			t.Fatalf("unexpected ciphertext length %d", len(ciphertext))
+		}
+		// four zero bytes followed by the little-endian counter
+		aead, _ := chacha20poly1305.New(key)
+		nonce := make([]byte, CHACHAPOLY_NONCE_SIZE)
+		nonce[4] = byte(i)
+		if !bytes.Equal(ciphertext, aead.Seal(nil, nonce, plaintext, ad)) {
+			t.Fatalf("ciphertext %d was not sealed with nonce %d", i, i)
+		}
+		opened, err := receiver.Open(nil, ciphertext, ad)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Fatalf("failed to open message %d", i)
+		}
+	}
+	if sender.Nonce() != 3 || receiver.Nonce() != 3 {
+		t.Fatalf("nonces did not advance")
+	}
+}
+
+func TestChaChaPolyOpenFailureKeepsNonce(t *testing.T) {
+	sender, receiver, _ := newTestChaChaPoly(t)
+	ciphertext, _ := sender.Seal(nil, []byte("hello"), nil)
+	ciphertext[0] ^= 0xff
+	if _, err := receiver.Open(nil, ciphertext, nil); err != ErrChaChaPolyDecryptionFailed {
+		t.Fatalf("tampered ciphertext was opened")
+	}
+	if receiver.Nonce() != 0 {
+		t.Fatalf("nonce advanced after failed open")
+	}
+	ciphertext[0] ^= 0xff
+	if _, err := receiver.Open(nil, ciphertext, nil); err != nil {
+		t.Fatalf("failed to open: %s", err.Error())
+	}
+}
+
+func TestChaChaPolyExplicitNonce(t *testing.T) {
+	sender, receiver, _ := newTestChaChaPoly(t)
+	ciphertext := sender.SealWithNonce(nil, 1234, []byte("hello"), nil)
+	if _, err := receiver.OpenWithNonce(nil, 1233, ciphertext, nil); err == nil {
+		t.Fatalf("opened with the wrong nonce")
+	}
+	receiver.SetNonce(1234)
+	if _, err := receiver.Open(nil, ciphertext, nil); err != nil {
+		t.Fatalf("failed to open: %s", err.Error())
+	}
+	if sender.Nonce() != 0 {
+		t.Fatalf("explicit nonce changed the counter")
+	}
+}
+
+func TestChaChaPolyNonceExhausted(t *testing.T) {
+	sender, _, _ := newTestChaChaPoly(t)
+	sender.SetNonce(CHACHAPOLY_MAX_NONCE)
+	if _, err := sender.Seal(nil, []byte("hello"), nil); err != ErrChaChaPolyNonceExhausted {
+		t.Fatalf("sealed with the reserved nonce")
+	}
+}
+
+func TestChaChaPolyBadKey(t *testing.T) {
+	if _, err := NewChaChaPoly(make([]byte, 31)); err != ErrInvalidKeyFormat {
+		t.Fatalf("created cipher with a short key")
+	}
+}
+
+func TestChaChaPolyInPlaceDoesNotAllocate(t *testing.T) {
+	sender, receiver, _ := newTestChaChaPoly(t)
+	buf := make([]byte, 1024+CHACHAPOLY_MAC_SIZE)
+	ad := make([]byte, 32)
+	allocs := testing.AllocsPerRun(100, func() {
+		ciphertext, _ := sender.Seal(buf[:0], buf[:1024], ad)
+		receiver.Open(ciphertext[:0], ciphertext, ad)
+	})
+	if allocs != 0 {
+		t.Fatalf("in place seal and open allocated %v times", allocs)
+	}
+}
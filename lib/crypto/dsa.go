@@ -117,6 +117,10 @@ func (k DSAPublicKey) Len() int {
 	return len(k)
 }
 
+func (k DSAPublicKey) Bytes() []byte {
+	return k[:]
+}
+
 type DSASigner struct {
 	k *dsa.PrivateKey
 }
@@ -175,3 +179,7 @@ func (ds *DSASigner) SignHash(h []byte) (sig []byte, err error) {
 func (k DSAPrivateKey) Len() int {
 	return len(k)
 }
+
+func (k DSAPrivateKey) Bytes() []byte {
+	return k[:]
+}
@@ -4,6 +4,8 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
 )
 
 type ECDSAVerifier struct {
@@ -12,10 +14,17 @@ type ECDSAVerifier struct {
 	h crypto.Hash
 }
 
-// verify a signature given the hash
+// verify a signature given the hash, the signature is r and s concatenated
+// each padded to the size of the curve
 func (v *ECDSAVerifier) VerifyHash(h, sig []byte) (err error) {
-	r, s := elliptic.Unmarshal(v.c, sig)
-	if r == nil || s == nil || !ecdsa.Verify(v.k, h, r, s) {
+	size := (v.c.Params().BitSize + 7) / 8
+	if len(sig) != size*2 {
+		err = ErrBadSignatureSize
+		return
+	}
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size:])
+	if !ecdsa.Verify(v.k, h, r, s) {
 		err = ErrInvalidSignature
 	}
 	return
@@ -24,14 +33,18 @@ func (v *ECDSAVerifier) VerifyHash(h, sig []byte) (err error) {
 // verify a block of data by hashing it and comparing the hash against the signature
 func (v *ECDSAVerifier) Verify(data, sig []byte) (err error) {
 	// sum the data and get the hash
-	h := v.h.New().Sum(data)[len(data):]
+	hasher := v.h.New()
+	hasher.Write(data)
+	h := hasher.Sum(nil)
 	// verify
 	err = v.VerifyHash(h, sig)
 	return
 }
 
+// create an ecdsa verifier given the public key as x and y concatenated
 func createECVerifier(c elliptic.Curve, h crypto.Hash, k []byte) (ev *ECDSAVerifier, err error) {
-	x, y := elliptic.Unmarshal(c, k[:])
+	// i2p stores the uncompressed point without the leading 0x04 byte
+	x, y := elliptic.Unmarshal(c, append([]byte{0x04}, k...))
 	if x == nil {
 		err = ErrInvalidKeyFormat
 	} else {
@@ -39,7 +52,78 @@ func createECVerifier(c elliptic.Curve, h crypto.Hash, k []byte) (ev *ECDSAVerif
 			c: c,
 			h: h,
 		}
-		ev.k = &ecdsa.PublicKey{c, x, y}
+		ev.k = &ecdsa.PublicKey{
+			Curve: c,
+			X:     x,
+			Y:     y,
+		}
+	}
+	return
+}
+
+type ECDSASigner struct {
+	k *ecdsa.PrivateKey
+	h crypto.Hash
+}
+
+// sign a block of data by hashing it and signing the hash
+func (s *ECDSASigner) Sign(data []byte) (sig []byte, err error) {
+	hasher := s.h.New()
+	hasher.Write(data)
+	sig, err = s.SignHash(hasher.Sum(nil))
+	return
+}
+
+// sign a hash, the signature is r and s concatenated each padded to the size of the curve
+func (s *ECDSASigner) SignHash(h []byte) (sig []byte, err error) {
+	r, ss, err := ecdsa.Sign(rand.Reader, s.k, h)
+	if err != nil {
+		return
+	}
+	size := (s.k.Curve.Params().BitSize + 7) / 8
+	sig = make([]byte, size*2)
+	r.FillBytes(sig[:size])
+	ss.FillBytes(sig[size:])
+	return
+}
+
+// create an ecdsa signer given the private scalar padded to the size of the curve
+func createECSigner(c elliptic.Curve, h crypto.Hash, k []byte) (es *ECDSASigner, err error) {
+	d := new(big.Int).SetBytes(k)
+	if d.Sign() == 0 || d.Cmp(c.Params().N) >= 0 {
+		err = ErrInvalidKeyFormat
+		return
+	}
+	priv := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: c,
+		},
+		D: d,
+	}
+	priv.X, priv.Y = c.ScalarBaseMult(k)
+	es = &ECDSASigner{
+		k: priv,
+		h: h,
+	}
+	return
+}
+
+// get the public key of a private scalar as x and y concatenated
+func ecPublicKey(c elliptic.Curve, k, pk []byte) (err error) {
+	es, err := createECSigner(c, crypto.SHA256, k)
+	if err == nil {
+		size := len(pk) / 2
+		es.k.X.FillBytes(pk[:size])
+		es.k.Y.FillBytes(pk[size:])
+	}
+	return
+}
+
+// generate a new private scalar for a curve
+func generateECKey(c elliptic.Curve, k []byte) (err error) {
+	priv, err := ecdsa.GenerateKey(c, rand.Reader)
+	if err == nil {
+		priv.D.FillBytes(k)
 	}
 	return
 }
@@ -51,10 +135,44 @@ func (k ECP256PublicKey) Len() int {
 	return len(k)
 }
 
+func (k ECP256PublicKey) Bytes() []byte {
+	return k[:]
+}
+
 func (k ECP256PublicKey) NewVerifier() (Verifier, error) {
 	return createECVerifier(elliptic.P256(), crypto.SHA256, k[:])
 }
 
+func (k ECP256PrivateKey) NewSigner() (Signer, error) {
+	return createECSigner(elliptic.P256(), crypto.SHA256, k[:])
+}
+
+func (k ECP256PrivateKey) Len() int {
+	return len(k)
+}
+
+func (k ECP256PrivateKey) Bytes() []byte {
+	return k[:]
+}
+
+func (k ECP256PrivateKey) Public() (SigningPublicKey, error) {
+	var pk ECP256PublicKey
+	err := ecPublicKey(elliptic.P256(), k[:], pk[:])
+	if err != nil {
+		return nil, err
+	}
+	return pk, nil
+}
+
+// generate a new ecdsa private key
+func (k ECP256PrivateKey) Generate() (SigningPrivateKey, error) {
+	err := generateECKey(elliptic.P256(), k[:])
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
 type ECP384PublicKey [96]byte
 type ECP384PrivateKey [48]byte
 
@@ -62,10 +180,44 @@ func (k ECP384PublicKey) Len() int {
 	return len(k)
 }
 
+func (k ECP384PublicKey) Bytes() []byte {
+	return k[:]
+}
+
 func (k ECP384PublicKey) NewVerifier() (Verifier, error) {
 	return createECVerifier(elliptic.P384(), crypto.SHA384, k[:])
 }
 
+func (k ECP384PrivateKey) NewSigner() (Signer, error) {
+	return createECSigner(elliptic.P384(), crypto.SHA384, k[:])
+}
+
+func (k ECP384PrivateKey) Len() int {
+	return len(k)
+}
+
+func (k ECP384PrivateKey) Bytes() []byte {
+	return k[:]
+}
+
+func (k ECP384PrivateKey) Public() (SigningPublicKey, error) {
+	var pk ECP384PublicKey
+	err := ecPublicKey(elliptic.P384(), k[:], pk[:])
+	if err != nil {
+		return nil, err
+	}
+	return pk, nil
+}
+
+// generate a new ecdsa private key
+func (k ECP384PrivateKey) Generate() (SigningPrivateKey, error) {
+	err := generateECKey(elliptic.P384(), k[:])
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
 type ECP521PublicKey [132]byte
 type ECP521PrivateKey [66]byte
 
@@ -73,6 +225,40 @@ func (k ECP521PublicKey) Len() int {
 	return len(k)
 }
 
+func (k ECP521PublicKey) Bytes() []byte {
+	return k[:]
+}
+
 func (k ECP521PublicKey) NewVerifier() (Verifier, error) {
 	return createECVerifier(elliptic.P521(), crypto.SHA512, k[:])
 }
+
+func (k ECP521PrivateKey) NewSigner() (Signer, error) {
+	return createECSigner(elliptic.P521(), crypto.SHA512, k[:])
+}
+
+func (k ECP521PrivateKey) Len() int {
+	return len(k)
+}
+
+func (k ECP521PrivateKey) Bytes() []byte {
+	return k[:]
+}
+
+func (k ECP521PrivateKey) Public() (SigningPublicKey, error) {
+	var pk ECP521PublicKey
+	err := ecPublicKey(elliptic.P521(), k[:], pk[:])
+	if err != nil {
+		return nil, err
+	}
+	return pk, nil
+}
+
+// generate a new ecdsa private key
+func (k ECP521PrivateKey) Generate() (SigningPrivateKey, error) {
+	err := generateECKey(elliptic.P521(), k[:])
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
@@ -0,0 +1,129 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"testing"
+)
+
+func TestECP256Verify(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	var pk ECP256PublicKey
+	priv.X.FillBytes(pk[:32])
+	priv.Y.FillBytes(pk[32:])
+	data := make([]byte, 512)
+	io.ReadFull(rand.Reader, data)
+	h := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, priv, h[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	v, err := pk.NewVerifier()
+	if err != nil {
+		t.Fatalf("failed to create verifier: %s", err.Error())
+	}
+	err = v.Verify(data, sig)
+	if err != nil {
+		t.Logf("valid signature did not verify: %s", err.Error())
+		t.Fail()
+	}
+	data[0] ^= 0xff
+	if v.Verify(data, sig) != ErrInvalidSignature {
+		t.Logf("invalid signature verified")
+		t.Fail()
+	}
+}
+
+func testECDSASigning(t *testing.T, k SigningPrivateKey, sig_size int) {
+	k, err := k.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	pk, err := k.Public()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err.Error())
+	}
+	s, err := k.NewSigner()
+	if err != nil {
+		t.Fatalf("failed to create signer: %s", err.Error())
+	}
+	v, err := pk.NewVerifier()
+	if err != nil {
+		t.Fatalf("failed to create verifier: %s", err.Error())
+	}
+	data := make([]byte, 512)
+	io.ReadFull(rand.Reader, data)
+	sig, err := s.Sign(data)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+	if len(sig) != sig_size {
+		t.Fatalf("signature is %d bytes, expected %d", len(sig), sig_size)
+	}
+	err = v.Verify(data, sig)
+	if err != nil {
+		t.Logf("valid signature did not verify: %s", err.Error())
+		t.Fail()
+	}
+	data[0] ^= 0xff
+	if v.Verify(data, sig) != ErrInvalidSignature {
+		t.Logf("invalid signature verified")
+		t.Fail()
+	}
+}
+
+func TestECP256Signing(t *testing.T) {
+	testECDSASigning(t, ECP256PrivateKey{}, 64)
+}
+
+func TestECP384Signing(t *testing.T) {
+	testECDSASigning(t, ECP384PrivateKey{}, 96)
+}
+
+func TestECP521Signing(t *testing.T) {
+	testECDSASigning(t, ECP521PrivateKey{}, 132)
+}
+
+func TestECP256PublicMatchesStdlib(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	var k ECP256PrivateKey
+	priv.D.FillBytes(k[:])
+	pk, err := k.Public()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err.Error())
+	}
+	var expected ECP256PublicKey
+	priv.X.FillBytes(expected[:32])
+	priv.Y.FillBytes(expected[32:])
+	if pk != expected {
+		t.Logf("public key does not match the stdlib public key")
+		t.Fail()
+	}
+}
+
+func TestECSignerRejectsInvalidKey(t *testing.T) {
+	var zero ECP256PrivateKey
+	if _, err := zero.NewSigner(); err != ErrInvalidKeyFormat {
+		t.Logf("zero scalar accepted")
+		t.Fail()
+	}
+	var order ECP256PrivateKey
+	elliptic.P256().Params().N.FillBytes(order[:])
+	if _, err := order.NewSigner(); err != ErrInvalidKeyFormat {
+		t.Logf("scalar equal to the curve order accepted")
+		t.Fail()
+	}
+}
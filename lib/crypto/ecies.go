@@ -0,0 +1,485 @@
+package crypto
+
+/*
+ECIES-X25519-AEAD-Ratchet
+https://geti2p.net/spec/ecies
+
+New Session messages use the Noise IK pattern with elligator2 encoded ephemeral keys,
+New Session Reply messages complete the handshake, and Existing Session messages are
+sent with a session tag and key from the tag and symmetric key ratchets.  Payloads are
+the already formatted garlic message blocks.
+*/
+
+import (
+	"errors"
+	"sync"
+)
+
+const ECIES_PROTOCOL_NAME = "Noise_IKelg2+hs2_25519_ChaChaPoly_SHA256"
+
+const (
+	ECIES_KEY_SIZE                   = 32
+	ECIES_TAG_SIZE                   = 8
+	ECIES_MAC_SIZE                   = 16
+	ECIES_NEW_SESSION_OVERHEAD       = ECIES_KEY_SIZE*2 + ECIES_MAC_SIZE*2
+	ECIES_NEW_SESSION_REPLY_OVERHEAD = ECIES_TAG_SIZE + ECIES_KEY_SIZE + ECIES_MAC_SIZE*2
+	ECIES_EXISTING_SESSION_OVERHEAD  = ECIES_TAG_SIZE + ECIES_MAC_SIZE
+)
+
+const (
+	// number of tags generated ahead of the last received tag in each inbound tagset
+	ECIES_TAG_LOOKAHEAD = 24
+	// number of New Sessions to one destination that may await a reply
+	ECIES_MAX_PENDING_SESSIONS = 8
+	// highest message index in a tagset
+	RATCHET_TAGSET_MAX_INDEX = 65535
+)
+
+var ErrECIESMessageTooShort = errors.New("ecies message too short")
+var ErrECIESDecryptionFailed = errors.New("ecies decryption failed")
+var ErrRatchetTagSetExhausted = errors.New("ratchet tagset exhausted")
+
+// the noise handshake of a New Session, written to a remote static key or read with ours
+func eciesHandshake(initiator bool, static *X25519PrivateKey, remote *X25519PublicKey) (*NoiseHandshakeState, error) {
+	return NewNoiseHandshakeState(NoiseConfig{
+		Pattern:         NoisePatternIK,
+		ProtocolName:    NOISE_PROTOCOL_ECIES,
+		Initiator:       initiator,
+		StaticKey:       static,
+		RemoteStaticKey: remote,
+		Ephemeral:       NoiseElligator2Ephemeral,
+		AllowUnbound:    true,
+	})
+}
+
+// split the final chaining key into a tagset for each direction and the reply payload key
+func eciesSplit(hs *NoiseHandshakeState) (ab, ba *RatchetTagSet, payload_key [ECIES_KEY_SIZE]byte) {
+	ck := hs.ChainingKey()
+	k_ab, k_ba := hs.symmetric.Split()
+	ab = NewRatchetTagSet(ck[:], k_ab[:])
+	ba = NewRatchetTagSet(ck[:], k_ba[:])
+	HKDF(k_ba[:], nil, "AttachPayloadKDF", payload_key[:])
+	return
+}
+
+// encrypt with a key used outside the handshake, for reply payloads and Existing Sessions
+func eciesEncrypt(dst, k []byte, n uint64, plaintext, ad []byte) []byte {
+	aead, _ := NewChaChaPoly(k)
+	return aead.SealWithNonce(dst, n, plaintext, ad)
+}
+
+func eciesDecrypt(k []byte, n uint64, ciphertext, ad []byte) (plaintext []byte, err error) {
+	aead, _ := NewChaChaPoly(k)
+	plaintext, err = aead.OpenWithNonce(nil, n, ciphertext, ad)
+	if err != nil {
+		err = ErrECIESDecryptionFailed
+	}
+	return
+}
+
+// the tag and key for one message of a tagset
+type RatchetTagSetEntry struct {
+	Index int
+	Tag   [ECIES_TAG_SIZE]byte
+	Key   [ECIES_KEY_SIZE]byte
+}
+
+// a session tag ratchet and symmetric key ratchet advancing together, one entry per message
+type RatchetTagSet struct {
+	next_root_key [32]byte
+	tag_chain_key [32]byte
+	tag_constant  [32]byte
+	key_chain_key [32]byte
+	index         int
+}
+
+// create a tagset from a root key and a key, DH_INITIALIZE in the spec
+func NewRatchetTagSet(root_key, k []byte) (ts *RatchetTagSet) {
+	var chain_key, session_tag_key [32]byte
+	ts = new(RatchetTagSet)
+	HKDF(root_key, k, "KDFDHRatchetStep", ts.next_root_key[:], chain_key[:])
+	HKDF(chain_key[:], nil, "TagAndKeyGenKeys", session_tag_key[:], ts.key_chain_key[:])
+	HKDF(session_tag_key[:], nil, "STInitialization", ts.tag_chain_key[:], ts.tag_constant[:])
+	return
+}
+
+// ratchet the session tag and symmetric key chains to get the next entry
+func (ts *RatchetTagSet) Next() (entry RatchetTagSetEntry, err error) {
+	if ts.index > RATCHET_TAGSET_MAX_INDEX {
+		err = ErrRatchetTagSetExhausted
+		return
+	}
+	var tag_data [32]byte
+	HKDF(ts.tag_chain_key[:], ts.tag_constant[:], "SessionTagKeyGen", ts.tag_chain_key[:], tag_data[:])
+	copy(entry.Tag[:], tag_data[:])
+	HKDF(ts.key_chain_key[:], nil, "SymmetricRatchet", ts.key_chain_key[:], entry.Key[:])
+	entry.Index = ts.index
+	ts.index++
+	return
+}
+
+//
+// the handshake state after a New Session message, copied for each New Session Reply since
+// a New Session may be answered more than once
+//
+type eciesNewSession struct {
+	hs            *NoiseHandshakeState
+	remote_static X25519PublicKey
+}
+
+//
+// encrypt a New Session message to a remote static key, bound to our static key so the
+// remote can reply, or a one-time message when static is nil
+//
+func eciesEncryptNewSession(remote X25519PublicKey, static *X25519PrivateKey, payload []byte) (msg []byte, ns eciesNewSession, err error) {
+	hs, err := eciesHandshake(true, static, &remote)
+	if err != nil {
+		return
+	}
+	msg, err = hs.WriteMessage(make([]byte, 0, ECIES_NEW_SESSION_OVERHEAD+len(payload)), payload)
+	if err != nil {
+		return
+	}
+	ns.hs = hs
+	return
+}
+
+//
+// decrypt a New Session message with our static key, the remote static key in the
+// returned state is all zeros for one-time messages
+//
+func eciesDecryptNewSession(static X25519PrivateKey, msg []byte) (payload []byte, ns eciesNewSession, err error) {
+	if len(msg) < ECIES_NEW_SESSION_OVERHEAD {
+		err = ErrECIESMessageTooShort
+		return
+	}
+	hs, err := eciesHandshake(false, &static, nil)
+	if err != nil {
+		return
+	}
+	payload, err = hs.ReadMessage(nil, msg)
+	if err != nil {
+		err = ErrECIESDecryptionFailed
+		return
+	}
+	ns.hs = hs
+	ns.remote_static, _ = hs.RemoteStaticKey()
+	return
+}
+
+// the tagset of the tags for replies to this New Session
+func (ns *eciesNewSession) replyTagSet() *RatchetTagSet {
+	var tagset_key [32]byte
+	ck := ns.hs.ChainingKey()
+	HKDF(ck[:], nil, "SessionReplyTags", tagset_key[:])
+	return NewRatchetTagSet(ck[:], tagset_key[:])
+}
+
+//
+// encrypt a New Session Reply with the next reply tag, returning the tagsets Bob sends and
+// receives Existing Session messages with.  The tag is mixed into the handshake hash before
+// the second IK message, and the payload follows it encrypted with its own key.
+//
+func (ns *eciesNewSession) encryptReply(entry RatchetTagSetEntry, payload []byte) (msg []byte, send, recv *RatchetTagSet, err error) {
+	hs := ns.hs.clone()
+	hs.MixHash(entry.Tag[:])
+	msg = append(make([]byte, 0, ECIES_NEW_SESSION_REPLY_OVERHEAD+len(payload)), entry.Tag[:]...)
+	msg, err = hs.WriteMessage(msg, nil)
+	if err != nil {
+		return
+	}
+	ab, ba, payload_key := eciesSplit(hs)
+	h := hs.HandshakeHash()
+	msg = eciesEncrypt(msg, payload_key[:], 0, payload, h[:])
+	send, recv = ba, ab
+	return
+}
+
+//
+// decrypt a New Session Reply to this New Session, returning the tagsets Alice sends and
+// receives Existing Session messages with
+//
+func (ns *eciesNewSession) decryptReply(msg []byte) (payload []byte, send, recv *RatchetTagSet, err error) {
+	if len(msg) < ECIES_NEW_SESSION_REPLY_OVERHEAD {
+		err = ErrECIESMessageTooShort
+		return
+	}
+	hs := ns.hs.clone()
+	hs.MixHash(msg[:ECIES_TAG_SIZE])
+	key_section_end := ECIES_NEW_SESSION_REPLY_OVERHEAD - ECIES_MAC_SIZE
+	_, err = hs.ReadMessage(nil, msg[ECIES_TAG_SIZE:key_section_end])
+	if err != nil {
+		err = ErrECIESDecryptionFailed
+		return
+	}
+	ab, ba, payload_key := eciesSplit(hs)
+	h := hs.HandshakeHash()
+	payload, err = eciesDecrypt(payload_key[:], 0, msg[key_section_end:], h[:])
+	if err != nil {
+		return
+	}
+	send, recv = ab, ba
+	return
+}
+
+// encrypts one-time ECIES-X25519 New Session messages, which cannot be replied to
+type ECIESEncrypter struct {
+	k X25519PublicKey
+}
+
+func (e *ECIESEncrypter) Encrypt(data []byte) (enc []byte, err error) {
+	enc, _, err = eciesEncryptNewSession(e.k, nil, data)
+	return
+}
+
+// decrypts ECIES-X25519 New Session messages, discarding the handshake state
+type ECIESDecrypter struct {
+	k X25519PrivateKey
+}
+
+func (d *ECIESDecrypter) Decrypt(data []byte) (dec []byte, err error) {
+	dec, _, err = eciesDecryptNewSession(d.k, data)
+	return
+}
+
+// an inbound tagset with its looked ahead entries
+type eciesInboundTags struct {
+	tagset  *RatchetTagSet
+	entries map[[ECIES_TAG_SIZE]byte]RatchetTagSetEntry
+	session *eciesSession
+}
+
+//
+// one ratchet session with a remote static key.  Alice's session awaits a New Session Reply
+// until send is set, and each New Session Reply Bob sends creates a session that is
+// unconfirmed until the first Existing Session message arrives on it.
+//
+type eciesSession struct {
+	remote      X25519PublicKey
+	new_session eciesNewSession
+	send        *RatchetTagSet
+	inbound     *eciesInboundTags
+	unconfirmed bool
+	// Bob's state for replying to a New Session
+	reply_tags *RatchetTagSet
+	replies    []*eciesSession
+}
+
+// encrypt an Existing Session message with the next entry of the outbound tagset
+func (s *eciesSession) encryptExisting(payload []byte) (msg []byte, err error) {
+	entry, err := s.send.Next()
+	if err != nil {
+		return
+	}
+	msg = append(make([]byte, 0, ECIES_EXISTING_SESSION_OVERHEAD+len(payload)), entry.Tag[:]...)
+	msg = eciesEncrypt(msg, entry.Key[:], uint64(entry.Index), payload, entry.Tag[:])
+	return
+}
+
+//
+// tracks the ratchet sessions of a destination's static x25519 key and finds the session of
+// each inbound message by its session tag
+//
+type ECIESSessionManager struct {
+	mutex    sync.Mutex
+	static   X25519PrivateKey
+	tags     map[[ECIES_TAG_SIZE]byte]*eciesInboundTags
+	sessions map[X25519PublicKey]*eciesSession
+	pending  map[X25519PublicKey][]*eciesSession
+	replying map[X25519PublicKey]*eciesSession
+}
+
+func NewECIESSessionManager(static X25519PrivateKey) *ECIESSessionManager {
+	return &ECIESSessionManager{
+		static:   static,
+		tags:     make(map[[ECIES_TAG_SIZE]byte]*eciesInboundTags),
+		sessions: make(map[X25519PublicKey]*eciesSession),
+		pending:  make(map[X25519PublicKey][]*eciesSession),
+		replying: make(map[X25519PublicKey]*eciesSession),
+	}
+}
+
+//
+// encrypt a payload to a remote static key, as a New Session Reply while a New Session from
+// the remote has not been confirmed, an Existing Session message once a session is
+// established, and otherwise as a New Session
+//
+func (m *ECIESSessionManager) Encrypt(remote X25519PublicKey, payload []byte) (msg []byte, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if s, ok := m.replying[remote]; ok {
+		return m.encryptReply(s, payload)
+	}
+	if s, ok := m.sessions[remote]; ok {
+		return s.encryptExisting(payload)
+	}
+	return m.encryptNewSession(remote, payload)
+}
+
+//
+// decrypt an inbound message, finding its session by tag or decrypting it as a New Session,
+// and return the remote static key of the session.  The remote key is all zeros for
+// one-time messages.
+//
+func (m *ECIESSessionManager) Decrypt(msg []byte) (remote X25519PublicKey, payload []byte, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if len(msg) >= ECIES_TAG_SIZE {
+		var tag [ECIES_TAG_SIZE]byte
+		copy(tag[:], msg)
+		if inbound, ok := m.tags[tag]; ok {
+			if inbound.session.send == nil {
+				return m.decryptReply(inbound.session, msg)
+			}
+			return m.decryptExisting(inbound, tag, msg)
+		}
+	}
+	return m.decryptNewSession(msg)
+}
+
+func (m *ECIESSessionManager) encryptNewSession(remote X25519PublicKey, payload []byte) (msg []byte, err error) {
+	msg, ns, err := eciesEncryptNewSession(remote, &m.static, payload)
+	if err != nil {
+		return
+	}
+	s := &eciesSession{
+		remote:      remote,
+		new_session: ns,
+	}
+	m.register(s, ns.replyTagSet())
+	pending := append(m.pending[remote], s)
+	if len(pending) > ECIES_MAX_PENDING_SESSIONS {
+		m.unregister(pending[0])
+		pending = pending[1:]
+	}
+	m.pending[remote] = pending
+	return
+}
+
+func (m *ECIESSessionManager) encryptReply(parent *eciesSession, payload []byte) (msg []byte, err error) {
+	entry, err := parent.reply_tags.Next()
+	if err != nil {
+		return
+	}
+	msg, send, recv, err := parent.new_session.encryptReply(entry, payload)
+	if err != nil {
+		return
+	}
+	s := &eciesSession{
+		remote:      parent.remote,
+		send:        send,
+		unconfirmed: true,
+	}
+	m.register(s, recv)
+	parent.replies = append(parent.replies, s)
+	return
+}
+
+func (m *ECIESSessionManager) decryptNewSession(msg []byte) (remote X25519PublicKey, payload []byte, err error) {
+	payload, ns, err := eciesDecryptNewSession(m.static, msg)
+	if err != nil {
+		return
+	}
+	remote = ns.remote_static
+	if remote == (X25519PublicKey{}) {
+		return
+	}
+	s := &eciesSession{
+		remote:      remote,
+		new_session: ns,
+		reply_tags:  ns.replyTagSet(),
+	}
+	// replies to an earlier New Session may still be confirmed
+	if previous, ok := m.replying[remote]; ok {
+		s.replies = previous.replies
+	}
+	m.replying[remote] = s
+	return
+}
+
+func (m *ECIESSessionManager) decryptReply(s *eciesSession, msg []byte) (remote X25519PublicKey, payload []byte, err error) {
+	payload, send, recv, err := s.new_session.decryptReply(msg)
+	if err != nil {
+		return
+	}
+	remote = s.remote
+	for _, pending := range m.pending[remote] {
+		m.unregister(pending)
+	}
+	delete(m.pending, remote)
+	s.send = send
+	s.new_session = eciesNewSession{}
+	m.register(s, recv)
+	m.establish(s)
+	return
+}
+
+func (m *ECIESSessionManager) decryptExisting(inbound *eciesInboundTags, tag [ECIES_TAG_SIZE]byte, msg []byte) (remote X25519PublicKey, payload []byte, err error) {
+	if len(msg) < ECIES_EXISTING_SESSION_OVERHEAD {
+		err = ErrECIESMessageTooShort
+		return
+	}
+	entry := inbound.entries[tag]
+	payload, err = eciesDecrypt(entry.Key[:], uint64(entry.Index), msg[ECIES_TAG_SIZE:], tag[:])
+	if err != nil {
+		return
+	}
+	delete(inbound.entries, tag)
+	delete(m.tags, tag)
+	m.lookahead(inbound)
+	s := inbound.session
+	remote = s.remote
+	// the first Existing Session message confirms which reply Alice received, and one on an
+	// established session means any New Session still being replied to is stale
+	if s.unconfirmed || m.sessions[remote] == s {
+		s.unconfirmed = false
+		if parent, ok := m.replying[remote]; ok {
+			for _, reply := range parent.replies {
+				if reply != s {
+					m.unregister(reply)
+				}
+			}
+			delete(m.replying, remote)
+		}
+		m.establish(s)
+	}
+	return
+}
+
+// make a session the established session with its remote, replacing any earlier one
+func (m *ECIESSessionManager) establish(s *eciesSession) {
+	if previous, ok := m.sessions[s.remote]; ok && previous != s {
+		m.unregister(previous)
+	}
+	m.sessions[s.remote] = s
+}
+
+// set the inbound tagset of a session and look ahead its first tags
+func (m *ECIESSessionManager) register(s *eciesSession, tagset *RatchetTagSet) {
+	m.unregister(s)
+	s.inbound = &eciesInboundTags{
+		tagset:  tagset,
+		entries: make(map[[ECIES_TAG_SIZE]byte]RatchetTagSetEntry),
+		session: s,
+	}
+	for i := 0; i < ECIES_TAG_LOOKAHEAD; i++ {
+		m.lookahead(s.inbound)
+	}
+}
+
+func (m *ECIESSessionManager) lookahead(inbound *eciesInboundTags) {
+	entry, err := inbound.tagset.Next()
+	if err == nil {
+		inbound.entries[entry.Tag] = entry
+		m.tags[entry.Tag] = inbound
+	}
+}
+
+func (m *ECIESSessionManager) unregister(s *eciesSession) {
+	if s.inbound != nil {
+		for tag := range s.inbound.entries {
+			delete(m.tags, tag)
+		}
+		s.inbound = nil
+	}
+}
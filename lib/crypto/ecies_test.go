@@ -0,0 +1,227 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func generateX25519Key(t *testing.T) (X25519PrivateKey, X25519PublicKey) {
+	var k X25519PrivateKey
+	k, err := k.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	pk, _ := k.Public()
+	return k, pk
+}
+
+func TestECIESOneTimeMessage(t *testing.T) {
+	k, pk := generateX25519Key(t)
+	enc, err := pk.NewEncrypter()
+	if err != nil {
+		t.Fatalf("failed to create encrypter: %s", err.Error())
+	}
+	dec, _ := k.NewDecrypter()
+	payload := []byte("one-time garlic payload")
+	msg, err := enc.Encrypt(payload)
+	if err != nil {
+		t.Fatalf("failed to encrypt: %s", err.Error())
+	}
+	if len(msg) != len(payload)+ECIES_NEW_SESSION_OVERHEAD {
+		t.Fatalf("new session message is %d bytes, expected %d", len(msg), len(payload)+ECIES_NEW_SESSION_OVERHEAD)
+	}
+	decrypted, err := dec.Decrypt(msg)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %s", err.Error())
+	}
+	if !bytes.Equal(payload, decrypted) {
+		t.Logf("decrypted payload does not match")
+		t.Fail()
+	}
+	msg[len(msg)-1] ^= 0xff
+	if _, err := dec.Decrypt(msg); err != ErrECIESDecryptionFailed {
+		t.Logf("tampered message decrypted: %v", err)
+		t.Fail()
+	}
+	other, _ := generateX25519Key(t)
+	other_dec, _ := other.NewDecrypter()
+	msg[len(msg)-1] ^= 0xff
+	if _, err := other_dec.Decrypt(msg); err != ErrECIESDecryptionFailed {
+		t.Logf("message decrypted with the wrong key: %v", err)
+		t.Fail()
+	}
+	if _, err := dec.Decrypt(msg[:ECIES_NEW_SESSION_OVERHEAD-1]); err != ErrECIESMessageTooShort {
+		t.Logf("short message was not rejected: %v", err)
+		t.Fail()
+	}
+}
+
+func TestRatchetTagSetIsDeterministic(t *testing.T) {
+	root_key := bytes.Repeat([]byte{0x01}, 32)
+	k := bytes.Repeat([]byte{0x02}, 32)
+	a := NewRatchetTagSet(root_key, k)
+	b := NewRatchetTagSet(root_key, k)
+	seen := make(map[[ECIES_TAG_SIZE]byte]bool)
+	for i := 0; i < 100; i++ {
+		ea, _ := a.Next()
+		eb, _ := b.Next()
+		if ea != eb {
+			t.Fatalf("tagsets from the same keys diverged at %d", i)
+		}
+		if ea.Index != i {
+			t.Fatalf("entry has index %d, expected %d", ea.Index, i)
+		}
+		if seen[ea.Tag] {
+			t.Fatalf("tag repeated at %d", i)
+		}
+		seen[ea.Tag] = true
+	}
+	other := NewRatchetTagSet(root_key, bytes.Repeat([]byte{0x03}, 32))
+	eo, _ := other.Next()
+	if seen[eo.Tag] {
+		t.Logf("tagset from a different key produced the same tag")
+		t.Fail()
+	}
+}
+
+func TestRatchetTagSetExhausted(t *testing.T) {
+	ts := NewRatchetTagSet(make([]byte, 32), make([]byte, 32))
+	ts.index = RATCHET_TAGSET_MAX_INDEX
+	if _, err := ts.Next(); err != nil {
+		t.Fatalf("last entry was not produced: %s", err.Error())
+	}
+	if _, err := ts.Next(); err != ErrRatchetTagSetExhausted {
+		t.Logf("entry produced past the end of the tagset")
+		t.Fail()
+	}
+}
+
+func eciesRoundTrip(t *testing.T, from *ECIESSessionManager, to *ECIESSessionManager, to_public, from_public X25519PublicKey, payload []byte, overhead int) []byte {
+	msg, err := from.Encrypt(to_public, payload)
+	if err != nil {
+		t.Fatalf("failed to encrypt: %s", err.Error())
+	}
+	if len(msg) != len(payload)+overhead {
+		t.Fatalf("message is %d bytes, expected %d", len(msg), len(payload)+overhead)
+	}
+	remote, decrypted, err := to.Decrypt(msg)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %s", err.Error())
+	}
+	if remote != from_public {
+		t.Fatalf("message was not attributed to the sender")
+	}
+	if !bytes.Equal(payload, decrypted) {
+		t.Fatalf("decrypted payload does not match")
+	}
+	return msg
+}
+
+func TestECIESSessionHandshake(t *testing.T) {
+	alice_key, alice_public := generateX25519Key(t)
+	bob_key, bob_public := generateX25519Key(t)
+	alice := NewECIESSessionManager(alice_key)
+	bob := NewECIESSessionManager(bob_key)
+
+	eciesRoundTrip(t, alice, bob, bob_public, alice_public, []byte("new session"), ECIES_NEW_SESSION_OVERHEAD)
+	eciesRoundTrip(t, bob, alice, alice_public, bob_public, []byte("new session reply"), ECIES_NEW_SESSION_REPLY_OVERHEAD)
+	eciesRoundTrip(t, alice, bob, bob_public, alice_public, []byte("first existing session"), ECIES_EXISTING_SESSION_OVERHEAD)
+	for i := 0; i < ECIES_TAG_LOOKAHEAD*2; i++ {
+		eciesRoundTrip(t, bob, alice, alice_public, bob_public, []byte("bob to alice"), ECIES_EXISTING_SESSION_OVERHEAD)
+		eciesRoundTrip(t, alice, bob, bob_public, alice_public, []byte("alice to bob"), ECIES_EXISTING_SESSION_OVERHEAD)
+	}
+}
+
+func TestECIESSessionRejectsReplay(t *testing.T) {
+	alice_key, alice_public := generateX25519Key(t)
+	bob_key, bob_public := generateX25519Key(t)
+	alice := NewECIESSessionManager(alice_key)
+	bob := NewECIESSessionManager(bob_key)
+
+	eciesRoundTrip(t, alice, bob, bob_public, alice_public, []byte("new session"), ECIES_NEW_SESSION_OVERHEAD)
+	eciesRoundTrip(t, bob, alice, alice_public, bob_public, []byte("new session reply"), ECIES_NEW_SESSION_REPLY_OVERHEAD)
+	msg := eciesRoundTrip(t, alice, bob, bob_public, alice_public, []byte("existing session"), ECIES_EXISTING_SESSION_OVERHEAD)
+	if _, _, err := bob.Decrypt(msg); err == nil {
+		t.Logf("replayed existing session message decrypted")
+		t.Fail()
+	}
+}
+
+func TestECIESSessionOutOfOrder(t *testing.T) {
+	alice_key, alice_public := generateX25519Key(t)
+	bob_key, bob_public := generateX25519Key(t)
+	alice := NewECIESSessionManager(alice_key)
+	bob := NewECIESSessionManager(bob_key)
+
+	eciesRoundTrip(t, alice, bob, bob_public, alice_public, []byte("new session"), ECIES_NEW_SESSION_OVERHEAD)
+	eciesRoundTrip(t, bob, alice, alice_public, bob_public, []byte("new session reply"), ECIES_NEW_SESSION_REPLY_OVERHEAD)
+	var msgs [][]byte
+	for i := 0; i < 5; i++ {
+		msg, _ := alice.Encrypt(bob_public, []byte{byte(i)})
+		msgs = append(msgs, msg)
+	}
+	for i := len(msgs) - 1; i >= 0; i-- {
+		_, payload, err := bob.Decrypt(msgs[i])
+		if err != nil {
+			t.Fatalf("failed to decrypt message %d: %s", i, err.Error())
+		}
+		if payload[0] != byte(i) {
+			t.Fatalf("message %d decrypted to the wrong payload", i)
+		}
+	}
+}
+
+func TestECIESSessionMultipleReplies(t *testing.T) {
+	alice_key, alice_public := generateX25519Key(t)
+	bob_key, bob_public := generateX25519Key(t)
+	alice := NewECIESSessionManager(alice_key)
+	bob := NewECIESSessionManager(bob_key)
+
+	eciesRoundTrip(t, alice, bob, bob_public, alice_public, []byte("new session"), ECIES_NEW_SESSION_OVERHEAD)
+	// the first reply is lost, alice receives the second
+	if _, err := bob.Encrypt(alice_public, []byte("lost reply")); err != nil {
+		t.Fatalf("failed to encrypt: %s", err.Error())
+	}
+	eciesRoundTrip(t, bob, alice, alice_public, bob_public, []byte("second reply"), ECIES_NEW_SESSION_REPLY_OVERHEAD)
+	eciesRoundTrip(t, alice, bob, bob_public, alice_public, []byte("confirm"), ECIES_EXISTING_SESSION_OVERHEAD)
+	eciesRoundTrip(t, bob, alice, alice_public, bob_public, []byte("established"), ECIES_EXISTING_SESSION_OVERHEAD)
+}
+
+func TestECIESSessionMultipleNewSessions(t *testing.T) {
+	alice_key, alice_public := generateX25519Key(t)
+	bob_key, bob_public := generateX25519Key(t)
+	alice := NewECIESSessionManager(alice_key)
+	bob := NewECIESSessionManager(bob_key)
+
+	// alice sends a second new session before the first is answered
+	first, _ := alice.Encrypt(bob_public, []byte("first new session"))
+	eciesRoundTrip(t, alice, bob, bob_public, alice_public, []byte("second new session"), ECIES_NEW_SESSION_OVERHEAD)
+	eciesRoundTrip(t, bob, alice, alice_public, bob_public, []byte("reply to second"), ECIES_NEW_SESSION_REPLY_OVERHEAD)
+	eciesRoundTrip(t, alice, bob, bob_public, alice_public, []byte("confirm"), ECIES_EXISTING_SESSION_OVERHEAD)
+	// the late first new session starts a new handshake with bob
+	if _, _, err := bob.Decrypt(first); err != nil {
+		t.Fatalf("failed to decrypt late new session: %s", err.Error())
+	}
+	reply, _ := bob.Encrypt(alice_public, []byte("reply to first"))
+	if _, _, err := alice.Decrypt(reply); err == nil {
+		t.Logf("reply to an abandoned new session decrypted")
+		t.Fail()
+	}
+	eciesRoundTrip(t, alice, bob, bob_public, alice_public, []byte("still established"), ECIES_EXISTING_SESSION_OVERHEAD)
+	eciesRoundTrip(t, bob, alice, alice_public, bob_public, []byte("stale new session dropped"), ECIES_EXISTING_SESSION_OVERHEAD)
+}
+
+func TestECIESSessionOneTimeMessage(t *testing.T) {
+	bob_key, bob_public := generateX25519Key(t)
+	bob := NewECIESSessionManager(bob_key)
+	enc, _ := bob_public.NewEncrypter()
+	msg, _ := enc.Encrypt([]byte("one-time"))
+	remote, payload, err := bob.Decrypt(msg)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %s", err.Error())
+	}
+	if remote != (X25519PublicKey{}) || !bytes.Equal(payload, []byte("one-time")) {
+		t.Logf("one-time message was not decrypted without a remote key")
+		t.Fail()
+	}
+}
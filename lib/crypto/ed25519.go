@@ -1,93 +1,209 @@
 package crypto
 
-/*
-#cgo pkg-config: libsodium
-#include <sodium.h>
-#include <stdint.h>
-*/
-import "C"
-
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	"crypto/sha512"
+	"crypto/subtle"
 	"errors"
-	"fmt"
+	"io"
 )
 
+var Ed25519BadPrivateKeyLength = errors.New("bad ed25519 private key length")
+
 type Ed25519PublicKey [32]byte
 
 type Ed25519Verifier struct {
-	k [32]C.uchar
+	k ed25519.PublicKey
 }
 
 func (k Ed25519PublicKey) NewVerifier() (v Verifier, err error) {
-	ev := new(Ed25519Verifier)
-	for i, b := range k {
-		ev.k[i] = C.uchar(b)
+	v = &Ed25519Verifier{
+		k: ed25519.PublicKey(k[:]),
 	}
-	v = ev
 	return
 }
 
+func (k Ed25519PublicKey) Len() int {
+	return len(k)
+}
+
+func (k Ed25519PublicKey) Bytes() []byte {
+	return k[:]
+}
+
+// verify a signature over a prehashed message, ed25519 signs the hash as the message
 func (v *Ed25519Verifier) VerifyHash(h, sig []byte) (err error) {
-	if len(sig) == C.crypto_sign_BYTES {
-		// valid size of sig
-		// copy signature and hash
-		var csig, ch [32]C.uchar
-		for i, b := range h {
-			ch[i] = C.uchar(b)
-		}
-		for i, b := range sig {
-			csig[i] = C.uchar(b)
-		}
-		// verify
-		if C.crypto_sign_verify_detached(&csig[0], &ch[0], C.ulonglong(32), &v.k[0]) == 0 {
-			// valid signature
-		} else {
-			// bad signature
-			err = ErrInvalidSignature
-		}
-	} else {
+	if len(sig) != ed25519.SignatureSize {
 		// bad size of sig
 		err = ErrBadSignatureSize
+	} else if !ed25519.Verify(v.k, h, sig) {
+		// bad signature
+		err = ErrInvalidSignature
 	}
 	return
 }
 
+// verify a signature over a block of data, ed25519 does its own sha512 hashing internally
 func (v *Ed25519Verifier) Verify(data, sig []byte) (err error) {
+	err = v.VerifyHash(data, sig)
+	return
+}
+
+// an ed25519 public key for the Ed25519ph signature type, which signs the sha512 hash
+// of the data as the message
+type Ed25519phPublicKey [32]byte
+
+type Ed25519phVerifier struct {
+	Ed25519Verifier
+}
+
+func (k Ed25519phPublicKey) NewVerifier() (v Verifier, err error) {
+	v = &Ed25519phVerifier{
+		Ed25519Verifier{
+			k: ed25519.PublicKey(k[:]),
+		},
+	}
+	return
+}
+
+func (k Ed25519phPublicKey) Len() int {
+	return len(k)
+}
+
+func (k Ed25519phPublicKey) Bytes() []byte {
+	return k[:]
+}
+
+// verify a signature over the sha512 hash of a block of data
+func (v *Ed25519phVerifier) Verify(data, sig []byte) (err error) {
 	h := sha512.Sum512(data)
 	err = v.VerifyHash(h[:], sig)
 	return
 }
 
+// the 32 byte seed of an ed25519 private key
 type Ed25519PrivateKey [32]byte
 
+//
+// create an ed25519 private key from either the 32 byte seed or the 64 byte expanded form
+// of the seed followed by the public key, checking that the public key matches the seed
+//
+func NewEd25519PrivateKey(data []byte) (k Ed25519PrivateKey, err error) {
+	switch len(data) {
+	case ed25519.SeedSize:
+		copy(k[:], data)
+	case ed25519.PrivateKeySize:
+		copy(k[:], data[:ed25519.SeedSize])
+		if subtle.ConstantTimeCompare(k.Expanded(), data) != 1 {
+			err = ErrInvalidKeyFormat
+		}
+	default:
+		err = Ed25519BadPrivateKeyLength
+	}
+	return
+}
+
+// the signer holds the expanded private key, the seed followed by the public key
 type Ed25519Signer struct {
-	k [32]C.uchar
+	k ed25519.PrivateKey
 }
 
 func (s *Ed25519Signer) Sign(data []byte) (sig []byte, err error) {
+	sig, err = s.SignHash(data)
+	return
+}
+
+func (s *Ed25519Signer) SignHash(h []byte) (sig []byte, err error) {
+	if len(s.k) != ed25519.PrivateKeySize {
+		err = Ed25519BadPrivateKeyLength
+		return
+	}
+	sig = ed25519.Sign(s.k, h)
+	return
+}
+
+func (k Ed25519PrivateKey) NewSigner() (s Signer, err error) {
+	s = &Ed25519Signer{
+		k: k.Expanded(),
+	}
+	return
+}
+
+func (k Ed25519PrivateKey) Len() int {
+	return len(k)
+}
+
+func (k Ed25519PrivateKey) Bytes() []byte {
+	return k[:]
+}
+
+// get the 64 byte expanded private key, the seed followed by the public key
+func (k Ed25519PrivateKey) Expanded() []byte {
+	return ed25519.NewKeyFromSeed(k[:])
+}
+
+// get the public key derived from this seed
+func (k Ed25519PrivateKey) Public() (pk SigningPublicKey, err error) {
+	var public_key Ed25519PublicKey
+	copy(public_key[:], k.Expanded()[ed25519.SeedSize:])
+	pk = public_key
+	return
+}
+
+// generate a new random seed
+func (k Ed25519PrivateKey) Generate() (s SigningPrivateKey, err error) {
+	_, err = io.ReadFull(rand.Reader, k[:])
+	if err == nil {
+		s = k
+	}
+	return
+}
+
+// the 32 byte seed of an ed25519 private key for the Ed25519ph signature type
+type Ed25519phPrivateKey [32]byte
+
+type Ed25519phSigner struct {
+	Ed25519Signer
+}
+
+// sign the sha512 hash of a block of data
+func (s *Ed25519phSigner) Sign(data []byte) (sig []byte, err error) {
 	h := sha512.Sum512(data)
 	sig, err = s.SignHash(h[:])
 	return
 }
 
-func (s *Ed25519Signer) SignHash(h []byte) (sig []byte, err error) {
-	var ch [32]C.uchar
-	for i, b := range h {
-		ch[i] = C.uchar(b)
+func (k Ed25519phPrivateKey) NewSigner() (s Signer, err error) {
+	s = &Ed25519phSigner{
+		Ed25519Signer{
+			k: Ed25519PrivateKey(k).Expanded(),
+		},
 	}
-	var csig [32]C.uchar
-	var smlen_p C.ulonglong
-	res := C.crypto_sign_detached(&csig[0], &smlen_p, &ch[0], C.ulonglong(32), &s.k[0])
-	if res == 0 {
-		// success signing
-		sig = make([]byte, 32)
-		for i, b := range csig {
-			sig[i] = byte(b)
-		}
-	} else {
-		// failed signing
-		err = errors.New(fmt.Sprintf("failed to sign: crypto_sign_detached exit code %d", int(res)))
+	return
+}
+
+func (k Ed25519phPrivateKey) Len() int {
+	return len(k)
+}
+
+func (k Ed25519phPrivateKey) Bytes() []byte {
+	return k[:]
+}
+
+// get the public key derived from this seed
+func (k Ed25519phPrivateKey) Public() (pk SigningPublicKey, err error) {
+	var public_key Ed25519phPublicKey
+	copy(public_key[:], Ed25519PrivateKey(k).Expanded()[ed25519.SeedSize:])
+	pk = public_key
+	return
+}
+
+// generate a new random seed
+func (k Ed25519phPrivateKey) Generate() (s SigningPrivateKey, err error) {
+	_, err = io.ReadFull(rand.Reader, k[:])
+	if err == nil {
+		s = k
 	}
 	return
 }
@@ -1,9 +1,188 @@
 package crypto
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"io"
 	"testing"
 )
 
 func TestEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	var pk Ed25519PublicKey
+	copy(pk[:], pub)
+	data := make([]byte, 512)
+	io.ReadFull(rand.Reader, data)
+	sig := ed25519.Sign(priv, data)
 
+	v, err := pk.NewVerifier()
+	if err != nil {
+		t.Fatalf("failed to create verifier: %s", err.Error())
+	}
+	err = v.Verify(data, sig)
+	if err != nil {
+		t.Logf("valid signature did not verify: %s", err.Error())
+		t.Fail()
+	}
+	data[0] ^= 0xff
+	if v.Verify(data, sig) != ErrInvalidSignature {
+		t.Logf("invalid signature verified")
+		t.Fail()
+	}
+	if v.Verify(data, sig[:32]) != ErrBadSignatureSize {
+		t.Logf("short signature was not rejected")
+		t.Fail()
+	}
+}
+
+func TestEd25519PrivateKeySigning(t *testing.T) {
+	var sk Ed25519PrivateKey
+	generated, err := sk.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	pk, err := generated.Public()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err.Error())
+	}
+	signer, err := generated.NewSigner()
+	if err != nil {
+		t.Fatalf("failed to create signer: %s", err.Error())
+	}
+	data := make([]byte, 512)
+	io.ReadFull(rand.Reader, data)
+	sig, err := signer.Sign(data)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+	v, _ := pk.NewVerifier()
+	err = v.Verify(data, sig)
+	if err != nil {
+		t.Logf("signature from generated key did not verify: %s", err.Error())
+		t.Fail()
+	}
+	if generated.Len() != 32 || len(generated.Bytes()) != 32 {
+		t.Logf("generated key has wrong length")
+		t.Fail()
+	}
+}
+
+func TestEd25519phVerify(t *testing.T) {
+	public_key, private_key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	var pk Ed25519phPublicKey
+	copy(pk[:], public_key)
+	data := make([]byte, 512)
+	io.ReadFull(rand.Reader, data)
+	h := sha512.Sum512(data)
+	sig := ed25519.Sign(private_key, h[:])
+
+	v, _ := pk.NewVerifier()
+	err = v.Verify(data, sig)
+	if err != nil {
+		t.Logf("valid prehashed signature did not verify: %s", err.Error())
+		t.Fail()
+	}
+	if v.Verify(data, ed25519.Sign(private_key, data)) == nil {
+		t.Logf("verified signature over data that was not prehashed")
+		t.Fail()
+	}
+}
+
+// RFC 8032 section 7.1, test 1
+func TestEd25519PrivateKeyVector(t *testing.T) {
+	seed, _ := hex.DecodeString("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	public_key, _ := hex.DecodeString("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+	expected_sig, _ := hex.DecodeString("e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e06522490155" +
+		"5fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b")
+
+	sk, err := NewEd25519PrivateKey(seed)
+	if err != nil {
+		t.Fatalf("failed to create key from seed: %s", err.Error())
+	}
+	pk, _ := sk.Public()
+	if !bytes.Equal(pk.Bytes(), public_key) {
+		t.Logf("public key does not match test vector")
+		t.Fail()
+	}
+	if !bytes.Equal(sk.Expanded(), append(seed, public_key...)) {
+		t.Logf("expanded key is not the seed followed by the public key")
+		t.Fail()
+	}
+	signer, _ := sk.NewSigner()
+	sig, err := signer.Sign([]byte{})
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+	if !bytes.Equal(sig, expected_sig) {
+		t.Logf("signature does not match test vector")
+		t.Fail()
+	}
+}
+
+func TestNewEd25519PrivateKeyFromExpanded(t *testing.T) {
+	_, expanded, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	sk, err := NewEd25519PrivateKey(expanded)
+	if err != nil {
+		t.Fatalf("failed to create key from expanded key: %s", err.Error())
+	}
+	if !bytes.Equal(sk.Bytes(), expanded.Seed()) {
+		t.Logf("seed was not taken from the expanded key")
+		t.Fail()
+	}
+	expanded[63] ^= 0xff
+	if _, err := NewEd25519PrivateKey(expanded); err != ErrInvalidKeyFormat {
+		t.Logf("expanded key with mismatched public key accepted")
+		t.Fail()
+	}
+	if _, err := NewEd25519PrivateKey(expanded[:48]); err != Ed25519BadPrivateKeyLength {
+		t.Logf("key with bad length accepted")
+		t.Fail()
+	}
+}
+
+func TestEd25519phPrivateKeySigning(t *testing.T) {
+	var sk Ed25519phPrivateKey
+	generated, err := sk.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	pk, err := generated.Public()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err.Error())
+	}
+	if _, ok := pk.(Ed25519phPublicKey); !ok {
+		t.Fatalf("public key is not an Ed25519ph public key")
+	}
+	signer, _ := generated.NewSigner()
+	data := make([]byte, 512)
+	io.ReadFull(rand.Reader, data)
+	sig, err := signer.Sign(data)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+	v, _ := pk.NewVerifier()
+	err = v.Verify(data, sig)
+	if err != nil {
+		t.Logf("Ed25519ph signature did not verify: %s", err.Error())
+		t.Fail()
+	}
+	var plain Ed25519PublicKey
+	copy(plain[:], pk.Bytes())
+	pv, _ := plain.NewVerifier()
+	if pv.Verify(data, sig) == nil {
+		t.Logf("Ed25519ph signature verified as a plain Ed25519 signature")
+		t.Fail()
+	}
 }
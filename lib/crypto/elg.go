@@ -61,6 +61,14 @@ func (elg *elgDecrypter) Decrypt(data []byte) (dec []byte, err error) {
 
 // decrypt an elgamal encrypted message, i2p style
 func elgamalDecrypt(priv *elgamal.PrivateKey, data []byte, zeroPadding bool) (decrypted []byte, err error) {
+	size := 512
+	if zeroPadding {
+		size = 514
+	}
+	if len(data) != size {
+		err = ElgDecryptFail
+		return
+	}
 	a := new(big.Int)
 	b := new(big.Int)
 	idx := 0
@@ -75,6 +83,11 @@ func elgamalDecrypt(priv *elgamal.PrivateKey, data []byte, zeroPadding bool) (de
 
 	// decrypt
 	m := new(big.Int).Mod(new(big.Int).Mul(b, new(big.Int).Exp(a, new(big.Int).Sub(new(big.Int).Sub(priv.P, priv.X), one), priv.P)), priv.P).Bytes()
+	if len(m) != 255 {
+		// a valid block starts with the nonzero byte 0xFF
+		err = ElgDecryptFail
+		return
+	}
 
 	// check digest
 	d := sha256.Sum256(m[33:255])
@@ -113,21 +126,22 @@ func (elg *ElgamalEncryption) EncryptPadding(data []byte, zeroPadding bool) (enc
 	mbytes := make([]byte, 255)
 	mbytes[0] = 0xFF
 	copy(mbytes[33:], data)
-	// do sha256 of payload
-	d := sha256.Sum256(mbytes[33 : len(data)+33])
+	// do sha256 of the zero padded payload
+	d := sha256.Sum256(mbytes[33:])
 	copy(mbytes[1:], d[:])
 	m := new(big.Int).SetBytes(mbytes)
 	// do encryption
-	b := new(big.Int).Mod(new(big.Int).Mul(elg.b1, m), elg.p).Bytes()
+	b := new(big.Int).Mod(new(big.Int).Mul(elg.b1, m), elg.p)
 
+	// a and b are right aligned in their 256 bytes
 	if zeroPadding {
 		encrypted = make([]byte, 514)
-		copy(encrypted[1:], elg.a.Bytes())
-		copy(encrypted[258:], b)
+		elg.a.FillBytes(encrypted[1:257])
+		b.FillBytes(encrypted[258:])
 	} else {
 		encrypted = make([]byte, 512)
-		copy(encrypted, elg.a.Bytes())
-		copy(encrypted[256:], b)
+		elg.a.FillBytes(encrypted[:256])
+		b.FillBytes(encrypted[256:])
 	}
 	return
 }
@@ -190,6 +204,10 @@ func (elg ElgPublicKey) Len() int {
 	return len(elg)
 }
 
+func (elg ElgPublicKey) Bytes() []byte {
+	return elg[:]
+}
+
 func (elg ElgPublicKey) NewEncrypter() (enc Encrypter, err error) {
 	k := createElgamalPublicKey(elg[:])
 	enc, err = createElgamalEncryption(k, rand.Reader)
@@ -206,3 +224,30 @@ func (elg ElgPrivateKey) NewDecrypter() (dec Decrypter, err error) {
 	}
 	return
 }
+
+func (elg ElgPrivateKey) Bytes() []byte {
+	return elg[:]
+}
+
+// get the public key of this private key
+func (elg ElgPrivateKey) Public() (pk ElgPublicKey, err error) {
+	k := createElgamalPrivateKey(elg[:])
+	if k == nil {
+		err = ErrInvalidKeyFormat
+	} else {
+		y := k.Y.Bytes()
+		copy(pk[len(pk)-len(y):], y)
+	}
+	return
+}
+
+// generate a new elgamal private key
+func (elg ElgPrivateKey) Generate() (k ElgPrivateKey, err error) {
+	priv := new(elgamal.PrivateKey)
+	err = ElgamalGenerate(priv, rand.Reader)
+	if err == nil {
+		x := priv.X.Bytes()
+		copy(k[len(k)-len(x):], x)
+	}
+	return
+}
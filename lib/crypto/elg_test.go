@@ -113,3 +113,50 @@ func TestElg(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestElgPrivateKeyGenerate(t *testing.T) {
+	var sk ElgPrivateKey
+	sk, err := sk.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	pk, err := sk.Public()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err.Error())
+	}
+	msg := make([]byte, 222)
+	io.ReadFull(rand.Reader, msg)
+	enc, err := pk.NewEncrypter()
+	if err != nil {
+		t.Fatalf("failed to create encrypter: %s", err.Error())
+	}
+	emsg, err := enc.Encrypt(msg)
+	if err != nil {
+		t.Fatalf("failed to encrypt: %s", err.Error())
+	}
+	dec, _ := sk.NewDecrypter()
+	dmsg, err := dec.Decrypt(emsg)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %s", err.Error())
+	}
+	if !bytes.Equal(dmsg, msg) {
+		t.Logf("decrypted message does not match")
+		t.Fail()
+	}
+}
+
+func TestElgDecryptMalformed(t *testing.T) {
+	var sk ElgPrivateKey
+	sk, _ = sk.Generate()
+	dec, _ := sk.NewDecrypter()
+	if _, err := dec.Decrypt(make([]byte, 100)); err != ElgDecryptFail {
+		t.Logf("short data was not rejected: %v", err)
+		t.Fail()
+	}
+	garbage := make([]byte, 514)
+	io.ReadFull(rand.Reader, garbage)
+	if _, err := dec.Decrypt(garbage); err != ElgDecryptFail {
+		t.Logf("random data was not rejected: %v", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,109 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"filippo.io/edwards25519/field"
+	"io"
+)
+
+var ErrNotElligator2Encodable = errors.New("x25519 public key has no elligator2 representative")
+
+// the montgomery curve constant A = 486662
+var curve25519A, _ = new(field.Element).SetBytes([]byte{
+	0x06, 0x6d, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+})
+
+//
+// map a 32 byte elligator2 representative to the x25519 public key it encodes, using the
+// non-square 2 and ignoring the two random high bits
+//
+func Elligator2Decode(representative []byte) (k X25519PublicKey) {
+	var buf [32]byte
+	copy(buf[:], representative)
+	buf[31] &= 0x3f
+	r, _ := new(field.Element).SetBytes(buf[:])
+	one := new(field.Element).One()
+	// w = -A / (1 + 2r^2)
+	d := new(field.Element).Square(r)
+	d.Add(d, d)
+	d.Add(d, one)
+	w := new(field.Element).Invert(d)
+	w.Multiply(w, curve25519A)
+	w.Negate(w)
+	// u = w if w^3 + Aw^2 + w is square, otherwise -w - A
+	e := new(field.Element).Multiply(curve25519A, w)
+	e.Add(e, new(field.Element).Square(w))
+	e.Add(e, one)
+	e.Multiply(e, w)
+	_, is_square := new(field.Element).SqrtRatio(e, one)
+	other := new(field.Element).Negate(w)
+	other.Subtract(other, curve25519A)
+	u := new(field.Element).Select(w, other, is_square)
+	copy(k[:], u.Bytes())
+	return
+}
+
+//
+// encode this public key as an elligator2 representative indistinguishable from random
+// bytes, about half of all public keys have no representative
+//
+func (k X25519PublicKey) Elligator2Encode() (representative [32]byte, err error) {
+	var tweak [1]byte
+	_, err = io.ReadFull(rand.Reader, tweak[:])
+	if err != nil {
+		return
+	}
+	u, err := new(field.Element).SetBytes(k[:])
+	if err != nil {
+		err = ErrInvalidKeyFormat
+		return
+	}
+	zero := new(field.Element).Zero()
+	u_plus_a := new(field.Element).Add(u, curve25519A)
+	if u.Equal(zero) == 1 || u_plus_a.Equal(zero) == 1 {
+		err = ErrNotElligator2Encodable
+		return
+	}
+	// r = sqrt(-u / 2(u + A)) or r = sqrt(-(u + A) / 2u), both decode to u
+	num := new(field.Element).Negate(u)
+	den := new(field.Element).Add(u_plus_a, u_plus_a)
+	alt_num := new(field.Element).Negate(u_plus_a)
+	alt_den := new(field.Element).Add(u, u)
+	choice := int(tweak[0] & 1)
+	num.Select(alt_num, num, choice)
+	den.Select(alt_den, den, choice)
+	r, was_square := new(field.Element).SqrtRatio(num, den)
+	if was_square == 0 {
+		err = ErrNotElligator2Encodable
+		return
+	}
+	// use the root below (p-1)/2 so the top two bits are free, 2r wraps to odd above it
+	above_half := new(field.Element).Add(r, r).IsNegative()
+	r.Select(new(field.Element).Negate(r), r, above_half)
+	copy(representative[:], r.Bytes())
+	representative[31] |= tweak[0] & 0xc0
+	return
+}
+
+// generate an x25519 private key whose public key has an elligator2 representative
+func GenerateElligator2X25519PrivateKey() (k X25519PrivateKey, representative [32]byte, err error) {
+	for {
+		k, err = k.Generate()
+		if err != nil {
+			return
+		}
+		var pk X25519PublicKey
+		pk, err = k.Public()
+		if err != nil {
+			return
+		}
+		representative, err = pk.Elligator2Encode()
+		if err != ErrNotElligator2Encodable {
+			return
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package crypto
+
+import (
+	"testing"
+)
+
+func TestElligator2RoundTrip(t *testing.T) {
+	for i := 0; i < 32; i++ {
+		k, representative, err := GenerateElligator2X25519PrivateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %s", err.Error())
+		}
+		pk, _ := k.Public()
+		if Elligator2Decode(representative[:]) != pk {
+			t.Fatalf("representative did not decode to the public key")
+		}
+		// the two high bits are random padding
+		representative[31] ^= 0xc0
+		if Elligator2Decode(representative[:]) != pk {
+			t.Fatalf("high bits of the representative changed the public key")
+		}
+	}
+}
+
+func TestElligator2EncodableKeys(t *testing.T) {
+	encodable := 0
+	for i := 0; i < 64; i++ {
+		var k X25519PrivateKey
+		k, _ = k.Generate()
+		pk, _ := k.Public()
+		representative, err := pk.Elligator2Encode()
+		if err == ErrNotElligator2Encodable {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("failed to encode: %s", err.Error())
+		}
+		encodable++
+		if Elligator2Decode(representative[:]) != pk {
+			t.Fatalf("representative did not decode to the public key")
+		}
+	}
+	if encodable == 0 || encodable == 64 {
+		t.Logf("expected about half of all keys to be encodable, got %d of 64", encodable)
+		t.Fail()
+	}
+}
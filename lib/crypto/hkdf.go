@@ -0,0 +1,18 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"golang.org/x/crypto/hkdf"
+	"io"
+)
+
+//
+// hkdf-sha256 as used by noise and i2p, filling each output in turn.  The salt is the chaining
+// key in noise handshakes and ratchets, and a nil ikm is the zero length input key material.
+//
+func HKDF(salt, ikm []byte, info string, outputs ...[]byte) {
+	reader := hkdf.New(sha256.New, ikm, salt, []byte(info))
+	for _, out := range outputs {
+		io.ReadFull(reader, out)
+	}
+}
@@ -0,0 +1,22 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// RFC 5869 test case 1, read as two outputs
+func TestHKDFVector(t *testing.T) {
+	ikm := bytes.Repeat([]byte{0x0b}, 22)
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	expected, _ := hex.DecodeString("3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865")
+
+	first := make([]byte, 32)
+	second := make([]byte, 10)
+	HKDF(salt, ikm, string(info), first, second)
+	if !bytes.Equal(append(first, second...), expected) {
+		t.Fatalf("hkdf output does not match")
+	}
+}
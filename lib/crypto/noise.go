@@ -0,0 +1,444 @@
+package crypto
+
+/*
+Noise Protocol Framework
+https://noiseprotocol.org/noise.html
+
+The handshake state of the patterns I2P uses, with 25519, ChaChaPoly and SHA256.  NTCP2 and
+SSU2 use XK with obfuscated ephemeral keys, the ECIES ratchet uses IK with elligator2 encoded
+ephemeral keys, and ECIES tunnel build records use N.  Protocol specific data such as options
+blocks and session tags is mixed in between messages with MixHash.
+*/
+
+import (
+	"errors"
+)
+
+const (
+	NOISE_PROTOCOL_NTCP2        = "Noise_XKaesobfse+hs2+hs3_25519_ChaChaPoly_SHA256"
+	NOISE_PROTOCOL_SSU2         = "Noise_XKchaobfse+hs1+hs2+hs3_25519_ChaChaPoly_SHA256"
+	NOISE_PROTOCOL_ECIES        = ECIES_PROTOCOL_NAME
+	NOISE_PROTOCOL_TUNNEL_BUILD = "Noise_N_25519_ChaChaPoly_SHA256"
+)
+
+// the length of an x25519 public key on the wire
+const NOISE_DH_LEN = 32
+
+var ErrNoiseWrongTurn = errors.New("noise message out of turn")
+var ErrNoiseHandshakeComplete = errors.New("noise handshake already complete")
+var ErrNoiseHandshakeIncomplete = errors.New("noise handshake not complete")
+var ErrNoiseMissingKey = errors.New("noise key required by pattern is missing")
+var ErrNoiseMessageTooShort = errors.New("noise message too short")
+var ErrNoiseUnsupportedPattern = errors.New("noise pattern not supported")
+
+type NoiseToken int
+
+const (
+	NOISE_TOKEN_E NoiseToken = iota
+	NOISE_TOKEN_S
+	NOISE_TOKEN_EE
+	NOISE_TOKEN_ES
+	NOISE_TOKEN_SE
+	NOISE_TOKEN_SS
+)
+
+//
+// a handshake pattern, the pre-messages list the keys each side knows in advance and the
+// messages alternate starting with the initiator
+//
+type NoisePattern struct {
+	Name                 string
+	InitiatorPreMessages []NoiseToken
+	ResponderPreMessages []NoiseToken
+	Messages             [][]NoiseToken
+}
+
+var NoisePatternN = NoisePattern{
+	Name:                 "N",
+	ResponderPreMessages: []NoiseToken{NOISE_TOKEN_S},
+	Messages: [][]NoiseToken{
+		{NOISE_TOKEN_E, NOISE_TOKEN_ES},
+	},
+}
+
+var NoisePatternIK = NoisePattern{
+	Name:                 "IK",
+	ResponderPreMessages: []NoiseToken{NOISE_TOKEN_S},
+	Messages: [][]NoiseToken{
+		{NOISE_TOKEN_E, NOISE_TOKEN_ES, NOISE_TOKEN_S, NOISE_TOKEN_SS},
+		{NOISE_TOKEN_E, NOISE_TOKEN_EE, NOISE_TOKEN_SE},
+	},
+}
+
+var NoisePatternXK = NoisePattern{
+	Name:                 "XK",
+	ResponderPreMessages: []NoiseToken{NOISE_TOKEN_S},
+	Messages: [][]NoiseToken{
+		{NOISE_TOKEN_E, NOISE_TOKEN_ES},
+		{NOISE_TOKEN_E, NOISE_TOKEN_EE},
+		{NOISE_TOKEN_S, NOISE_TOKEN_SE},
+	},
+}
+
+//
+// how ephemeral keys are generated and sent.  The handshake hash always covers the plain
+// public key, only its NOISE_DH_LEN bytes on the wire are changed, as with elligator2 in
+// the ECIES ratchet or the AES obfuscation of NTCP2.
+//
+type NoiseEphemeralCodec struct {
+	Generate func() (X25519PrivateKey, error)
+	Encode   func(X25519PublicKey) ([]byte, error)
+	Decode   func([]byte) (X25519PublicKey, error)
+}
+
+// ephemeral keys sent as plain public keys
+var NoisePlainEphemeral = NoiseEphemeralCodec{
+	Generate: func() (X25519PrivateKey, error) {
+		return X25519PrivateKey{}.Generate()
+	},
+	Encode: func(k X25519PublicKey) ([]byte, error) {
+		return k[:], nil
+	},
+	Decode: func(data []byte) (k X25519PublicKey, err error) {
+		copy(k[:], data)
+		return
+	},
+}
+
+// ephemeral keys sent as elligator2 representatives, as in the ECIES ratchet
+var NoiseElligator2Ephemeral = NoiseEphemeralCodec{
+	Generate: func() (k X25519PrivateKey, err error) {
+		k, _, err = GenerateElligator2X25519PrivateKey()
+		return
+	},
+	Encode: func(k X25519PublicKey) ([]byte, error) {
+		representative, err := k.Elligator2Encode()
+		return representative[:], err
+	},
+	Decode: func(data []byte) (X25519PublicKey, error) {
+		return Elligator2Decode(data), nil
+	},
+}
+
+type NoiseConfig struct {
+	Pattern      NoisePattern
+	ProtocolName string
+	Prologue     []byte
+	Initiator    bool
+	// our static key, required when the pattern sends or uses it
+	StaticKey *X25519PrivateKey
+	// the remote static key, required when it is a pre-message
+	RemoteStaticKey *X25519PublicKey
+	// a fixed ephemeral key for test vectors, generated by the codec when nil
+	EphemeralKey *X25519PrivateKey
+	// the plain codec when Generate is nil
+	Ephemeral NoiseEphemeralCodec
+	//
+	// allow an initiator without a static key to send an all zero static key, as the
+	// unbound one-time messages of the ECIES ratchet do.  The ss token is then skipped
+	// and the payload is encrypted with the next nonce of the es key.
+	//
+	AllowUnbound bool
+}
+
+//
+// the state of one side of a noise handshake.  WriteMessage and ReadMessage are called in
+// turn until Complete, then Split gives the cipher for each direction.
+//
+type NoiseHandshakeState struct {
+	symmetric        SymmetricState
+	pattern          NoisePattern
+	initiator        bool
+	static           *X25519PrivateKey
+	ephemeral        *X25519PrivateKey
+	remote_static    *X25519PublicKey
+	remote_ephemeral *X25519PublicKey
+	codec            NoiseEphemeralCodec
+	allow_unbound    bool
+	message          int
+}
+
+// initialize a handshake, mixing in the prologue and the pre-message public keys
+func NewNoiseHandshakeState(config NoiseConfig) (hs *NoiseHandshakeState, err error) {
+	hs = &NoiseHandshakeState{
+		symmetric:     NewSymmetricState(config.ProtocolName),
+		pattern:       config.Pattern,
+		initiator:     config.Initiator,
+		static:        config.StaticKey,
+		ephemeral:     config.EphemeralKey,
+		remote_static: config.RemoteStaticKey,
+		codec:         config.Ephemeral,
+		allow_unbound: config.AllowUnbound,
+	}
+	if hs.codec.Generate == nil {
+		hs.codec = NoisePlainEphemeral
+	}
+	hs.symmetric.MixHash(config.Prologue)
+	err = hs.mixPreMessages(config.Pattern.InitiatorPreMessages, true)
+	if err == nil {
+		err = hs.mixPreMessages(config.Pattern.ResponderPreMessages, false)
+	}
+	if err != nil {
+		hs = nil
+	}
+	return
+}
+
+func (hs *NoiseHandshakeState) mixPreMessages(tokens []NoiseToken, initiator bool) error {
+	for _, token := range tokens {
+		var key *X25519PublicKey
+		if token != NOISE_TOKEN_S {
+			return ErrNoiseUnsupportedPattern
+		}
+		if initiator == hs.initiator {
+			if hs.static == nil {
+				return ErrNoiseMissingKey
+			}
+			public, err := hs.static.Public()
+			if err != nil {
+				return err
+			}
+			key = &public
+		} else {
+			key = hs.remote_static
+		}
+		if key == nil {
+			return ErrNoiseMissingKey
+		}
+		hs.symmetric.MixHash(key[:])
+	}
+	return nil
+}
+
+// mix protocol specific data into the handshake hash between messages
+func (hs *NoiseHandshakeState) MixHash(data []byte) {
+	hs.symmetric.MixHash(data)
+}
+
+// true once every message of the pattern has been written or read
+func (hs *NoiseHandshakeState) Complete() bool {
+	return hs.message >= len(hs.pattern.Messages)
+}
+
+func (hs *NoiseHandshakeState) HandshakeHash() [32]byte {
+	return hs.symmetric.HandshakeHash()
+}
+
+func (hs *NoiseHandshakeState) ChainingKey() [32]byte {
+	return hs.symmetric.ChainingKey()
+}
+
+// the remote static key, known after it is received or given as a pre-message
+func (hs *NoiseHandshakeState) RemoteStaticKey() (k X25519PublicKey, ok bool) {
+	if hs.remote_static != nil {
+		k, ok = *hs.remote_static, true
+	}
+	return
+}
+
+func (hs *NoiseHandshakeState) RemoteEphemeralKey() (k X25519PublicKey, ok bool) {
+	if hs.remote_ephemeral != nil {
+		k, ok = *hs.remote_ephemeral, true
+	}
+	return
+}
+
+// the tokens of the next message if it is ours to send or receive
+func (hs *NoiseHandshakeState) nextMessage(writing bool) ([]NoiseToken, error) {
+	if hs.Complete() {
+		return nil, ErrNoiseHandshakeComplete
+	}
+	if (hs.message%2 == 0) != (hs.initiator == writing) {
+		return nil, ErrNoiseWrongTurn
+	}
+	return hs.pattern.Messages[hs.message], nil
+}
+
+// write the next handshake message with its payload, appending it to dst
+func (hs *NoiseHandshakeState) WriteMessage(dst, payload []byte) (out []byte, err error) {
+	tokens, err := hs.nextMessage(true)
+	if err != nil {
+		return dst, err
+	}
+	out = dst
+	for _, token := range tokens {
+		switch token {
+		case NOISE_TOKEN_E:
+			if hs.ephemeral == nil {
+				var ephemeral X25519PrivateKey
+				ephemeral, err = hs.codec.Generate()
+				if err != nil {
+					return dst, err
+				}
+				hs.ephemeral = &ephemeral
+			}
+			var public X25519PublicKey
+			public, err = hs.ephemeral.Public()
+			if err != nil {
+				return dst, err
+			}
+			var encoded []byte
+			encoded, err = hs.codec.Encode(public)
+			if err != nil {
+				return dst, err
+			}
+			out = append(out, encoded...)
+			hs.symmetric.MixHash(public[:])
+		case NOISE_TOKEN_S:
+			var public X25519PublicKey
+			if hs.static != nil {
+				public, err = hs.static.Public()
+				if err != nil {
+					return dst, err
+				}
+			} else if !hs.unbound() {
+				return dst, ErrNoiseMissingKey
+			}
+			out, err = hs.symmetric.EncryptAndHash(out, public[:])
+			if err != nil {
+				return dst, err
+			}
+		default:
+			err = hs.mixDH(token)
+			if err != nil {
+				return dst, err
+			}
+		}
+	}
+	out, err = hs.symmetric.EncryptAndHash(out, payload)
+	if err != nil {
+		return dst, err
+	}
+	hs.message++
+	return
+}
+
+// read the next handshake message, appending its decrypted payload to dst
+func (hs *NoiseHandshakeState) ReadMessage(dst, message []byte) (payload []byte, err error) {
+	tokens, err := hs.nextMessage(false)
+	if err != nil {
+		return dst, err
+	}
+	for _, token := range tokens {
+		switch token {
+		case NOISE_TOKEN_E:
+			if len(message) < NOISE_DH_LEN {
+				return dst, ErrNoiseMessageTooShort
+			}
+			var public X25519PublicKey
+			public, err = hs.codec.Decode(message[:NOISE_DH_LEN])
+			if err != nil {
+				return dst, err
+			}
+			message = message[NOISE_DH_LEN:]
+			hs.remote_ephemeral = &public
+			hs.symmetric.MixHash(public[:])
+		case NOISE_TOKEN_S:
+			size := NOISE_DH_LEN
+			if hs.symmetric.has_key {
+				size += CHACHAPOLY_MAC_SIZE
+			}
+			if len(message) < size {
+				return dst, ErrNoiseMessageTooShort
+			}
+			var public X25519PublicKey
+			_, err = hs.symmetric.DecryptAndHash(public[:0], message[:size])
+			if err != nil {
+				return dst, err
+			}
+			message = message[size:]
+			hs.remote_static = &public
+		default:
+			err = hs.mixDH(token)
+			if err != nil {
+				return dst, err
+			}
+		}
+	}
+	if hs.symmetric.has_key && len(message) < CHACHAPOLY_MAC_SIZE {
+		return dst, ErrNoiseMessageTooShort
+	}
+	payload, err = hs.symmetric.DecryptAndHash(dst, message)
+	if err != nil {
+		return dst, err
+	}
+	hs.message++
+	return
+}
+
+//
+// true when unbound handshakes are allowed and the initiator has no static key, or sent
+// an all zero one
+//
+func (hs *NoiseHandshakeState) unbound() bool {
+	if !hs.allow_unbound {
+		return false
+	}
+	if hs.initiator {
+		return hs.static == nil
+	}
+	return hs.remote_static != nil && *hs.remote_static == X25519PublicKey{}
+}
+
+// mix the shared secret of a DH token, the first letter is the initiator's key
+func (hs *NoiseHandshakeState) mixDH(token NoiseToken) error {
+	if token == NOISE_TOKEN_SS && hs.unbound() {
+		return nil
+	}
+	var local *X25519PrivateKey
+	var remote *X25519PublicKey
+	initiator_static := token == NOISE_TOKEN_SE || token == NOISE_TOKEN_SS
+	responder_static := token == NOISE_TOKEN_ES || token == NOISE_TOKEN_SS
+	if hs.initiator {
+		local, remote = hs.ephemeral, hs.remote_ephemeral
+		if initiator_static {
+			local = hs.static
+		}
+		if responder_static {
+			remote = hs.remote_static
+		}
+	} else {
+		local, remote = hs.ephemeral, hs.remote_ephemeral
+		if responder_static {
+			local = hs.static
+		}
+		if initiator_static {
+			remote = hs.remote_static
+		}
+	}
+	if local == nil || remote == nil || (initiator_static && hs.unbound()) {
+		return ErrNoiseMissingKey
+	}
+	shared_secret, err := local.SharedSecret(*remote)
+	if err != nil {
+		return err
+	}
+	hs.symmetric.MixKey(shared_secret)
+	return nil
+}
+
+// a copy of the handshake to continue more than once, as with each reply to an ECIES New Session
+func (hs *NoiseHandshakeState) clone() *NoiseHandshakeState {
+	c := *hs
+	return &c
+}
+
+//
+// the ciphers for sending and receiving transport messages, derived from the final chaining
+// key once the handshake is complete
+//
+func (hs *NoiseHandshakeState) Split() (send, receive *ChaChaPoly, err error) {
+	if !hs.Complete() {
+		err = ErrNoiseHandshakeIncomplete
+		return
+	}
+	k1, k2 := hs.symmetric.Split()
+	if !hs.initiator {
+		k1, k2 = k2, k1
+	}
+	send, err = NewChaChaPoly(k1[:])
+	if err == nil {
+		receive, err = NewChaChaPoly(k2[:])
+	}
+	return
+}
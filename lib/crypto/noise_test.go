@@ -0,0 +1,610 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// a deterministic x25519 key for vectors, every byte set to b
+func noiseTestKey(b byte) *X25519PrivateKey {
+	var k X25519PrivateKey
+	for i := range k {
+		k[i] = b
+	}
+	return &k
+}
+
+func noiseTestPublic(k *X25519PrivateKey) *X25519PublicKey {
+	public, _ := k.Public()
+	return &public
+}
+
+type noiseTestVector struct {
+	pattern       NoisePattern
+	protocol_name string
+	prologue      []byte
+	payloads      [][]byte
+}
+
+var noiseTestVectors = []noiseTestVector{
+	{
+		pattern:       NoisePatternN,
+		protocol_name: NOISE_PROTOCOL_TUNNEL_BUILD,
+		payloads:      [][]byte{[]byte("build request record")},
+	},
+	{
+		pattern:       NoisePatternIK,
+		protocol_name: NOISE_PROTOCOL_ECIES,
+		payloads:      [][]byte{[]byte("new session"), nil},
+	},
+	{
+		pattern:       NoisePatternXK,
+		protocol_name: NOISE_PROTOCOL_NTCP2,
+		prologue:      []byte("ntcp2 test prologue"),
+		payloads:      [][]byte{nil, []byte("session created"), []byte("session confirmed")},
+	},
+}
+
+// run a handshake with fixed keys, returning both sides and the messages sent
+func runNoiseHandshake(t *testing.T, vector noiseTestVector, initiator_config, responder_config NoiseConfig) (initiator, responder *NoiseHandshakeState, messages [][]byte) {
+	initiator, err := NewNoiseHandshakeState(initiator_config)
+	if err != nil {
+		t.Fatalf("failed to create initiator: %s", err.Error())
+	}
+	responder, err = NewNoiseHandshakeState(responder_config)
+	if err != nil {
+		t.Fatalf("failed to create responder: %s", err.Error())
+	}
+	writer, reader := initiator, responder
+	for i, payload := range vector.payloads {
+		msg, err := writer.WriteMessage(nil, payload)
+		if err != nil {
+			t.Fatalf("%s: failed to write message %d: %s", vector.pattern.Name, i, err.Error())
+		}
+		received, err := reader.ReadMessage(nil, msg)
+		if err != nil {
+			t.Fatalf("%s: failed to read message %d: %s", vector.pattern.Name, i, err.Error())
+		}
+		if !bytes.Equal(received, payload) {
+			t.Fatalf("%s: payload %d does not match", vector.pattern.Name, i)
+		}
+		messages = append(messages, msg)
+		writer, reader = reader, writer
+	}
+	return
+}
+
+func noiseTestConfigs(vector noiseTestVector) (initiator, responder NoiseConfig) {
+	initiator = NoiseConfig{
+		Pattern:         vector.pattern,
+		ProtocolName:    vector.protocol_name,
+		Prologue:        vector.prologue,
+		Initiator:       true,
+		StaticKey:       noiseTestKey(0x01),
+		RemoteStaticKey: noiseTestPublic(noiseTestKey(0x02)),
+		EphemeralKey:    noiseTestKey(0x03),
+	}
+	responder = NoiseConfig{
+		Pattern:      vector.pattern,
+		ProtocolName: vector.protocol_name,
+		Prologue:     vector.prologue,
+		StaticKey:    noiseTestKey(0x02),
+		EphemeralKey: noiseTestKey(0x04),
+	}
+	return
+}
+
+//
+// the Noise_N, Noise_IK and Noise_XK 25519 ChaChaPoly SHA256 vectors of the cacophony format
+// vectors.txt in github.com/flynn/noise v1.1.0, with and without a prologue and payloads.
+// messages after the handshake are transport messages, sent with the first split key when
+// their index from the end of the handshake is even and with the second when it is odd.
+//
+var (
+	noiseVectorInitiatorStatic    = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	noiseVectorResponderStatic    = "0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20"
+	noiseVectorInitiatorEphemeral = "202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f"
+	noiseVectorResponderEphemeral = "4142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f60"
+)
+
+type noiseVector struct {
+	pattern  NoisePattern
+	prologue string
+	payloads []string
+	messages []string
+}
+
+var noiseVectors = []noiseVector{
+	{
+		pattern:  NoisePatternXK,
+		prologue: "",
+		payloads: []string{
+			"",
+			"",
+			"",
+			"79656c6c6f777375626d6172696e65",
+			"7375626d6172696e6579656c6c6f77",
+		},
+		messages: []string{
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254" +
+				"9963aa4003cb0f60f51f7f8b1c0e6a9c",
+			"64b101b1d0be5a8704bd078f9895001fc03e8e9f9522f188dd128d9846d48466" +
+				"30166c893dafe95f71d102a8ac640a52",
+			"24a819b832ab7a11dd1464c2baf72f2c49e0665757911662ab11495a5fd4437e" +
+				"0abe01f5c07176e776e02716c4cb98a005ec4c884c4dc7500d2d9b99e9670ab3",
+			"e8b0f2fc220f7edc287a91ba45c76f6da1327405789dc61e31a649f57d6d93",
+			"ed6901a7cd973e880242b047fc86da03b498e8ed8e9838d6f3d107420dfcd9",
+		},
+	},
+	{
+		pattern:  NoisePatternXK,
+		prologue: "",
+		payloads: []string{
+			"746573745f6d73675f30",
+			"746573745f6d73675f31",
+			"746573745f6d73675f32",
+			"79656c6c6f777375626d6172696e65",
+			"7375626d6172696e6579656c6c6f77",
+		},
+		messages: []string{
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254" +
+				"0c4e6c2fa1de96ff5794a3f905720f0fa07aa85019d1138cfb87",
+			"64b101b1d0be5a8704bd078f9895001fc03e8e9f9522f188dd128d9846d48466" +
+				"e3186922b93e29c4a8f4d1c4e448467cbed9a9a4dd2ec38c1ab4",
+			"24a819b832ab7a11dd1464c2baf72f2c49e0665757911662ab11495a5fd4437e" +
+				"2a7ab6eac00781f1afcc98a4a72d60deed954c511b2929288a7115b740e75dd4" +
+				"c4eb940229b3abb9dae6",
+			"e8b0f2fc220f7edc287a91ba45c76f6da1327405789dc61e31a649f57d6d93",
+			"ed6901a7cd973e880242b047fc86da03b498e8ed8e9838d6f3d107420dfcd9",
+		},
+	},
+	{
+		pattern:  NoisePatternXK,
+		prologue: "6e6f74736563726574",
+		payloads: []string{
+			"",
+			"",
+			"",
+			"79656c6c6f777375626d6172696e65",
+			"7375626d6172696e6579656c6c6f77",
+		},
+		messages: []string{
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254" +
+				"8d6383ab07befc895d34bfab2c20bb25",
+			"64b101b1d0be5a8704bd078f9895001fc03e8e9f9522f188dd128d9846d48466" +
+				"7607d670be43da5c6ecbd567171a0113",
+			"24a819b832ab7a11dd1464c2baf72f2c49e0665757911662ab11495a5fd4437e" +
+				"79e34779a2989c930f3b98e0fbc5dc1f059efd8983ccf4319dcdc99374e5d193",
+			"e8b0f2fc220f7edc287a91ba45c76f6da1327405789dc61e31a649f57d6d93",
+			"ed6901a7cd973e880242b047fc86da03b498e8ed8e9838d6f3d107420dfcd9",
+		},
+	},
+	{
+		pattern:  NoisePatternXK,
+		prologue: "6e6f74736563726574",
+		payloads: []string{
+			"746573745f6d73675f30",
+			"746573745f6d73675f31",
+			"746573745f6d73675f32",
+			"79656c6c6f777375626d6172696e65",
+			"7375626d6172696e6579656c6c6f77",
+		},
+		messages: []string{
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254" +
+				"0c4e6c2fa1de96ff57949c01e13796236098242159a3226d7efc",
+			"64b101b1d0be5a8704bd078f9895001fc03e8e9f9522f188dd128d9846d48466" +
+				"e3186922b93e29c4a8f481bf540b7b9425152ed77d3ac32b6d5f",
+			"24a819b832ab7a11dd1464c2baf72f2c49e0665757911662ab11495a5fd4437e" +
+				"0fe2fb0506b390ab1e1527e2765e53dbed954c511b2929288a71525a716ce72a" +
+				"a94bca5bb136a6e3f02a",
+			"e8b0f2fc220f7edc287a91ba45c76f6da1327405789dc61e31a649f57d6d93",
+			"ed6901a7cd973e880242b047fc86da03b498e8ed8e9838d6f3d107420dfcd9",
+		},
+	},
+	{
+		pattern:  NoisePatternIK,
+		prologue: "",
+		payloads: []string{
+			"",
+			"",
+			"79656c6c6f777375626d6172696e65",
+			"7375626d6172696e6579656c6c6f77",
+		},
+		messages: []string{
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254" +
+				"4f8445e5dc2467b1e32653192d05dee85c4781bf0dd8d33ceebb5905a7a069f0" +
+				"9e0d3f2cad1c842930a762eb75e52827f01d2c85189d527644b3221b4c3fc5cc",
+			"64b101b1d0be5a8704bd078f9895001fc03e8e9f9522f188dd128d9846d48466" +
+				"aabfe2e5b1650bbaa88e33679893fc77",
+			"226ca869f2777611f37350a7ab446f650c0cfe2855b7f020ce658bcf100f2d",
+			"90d84d69cd44829283b05d684879b53b8d714e51619b601438a1ae67caacd9",
+		},
+	},
+	{
+		pattern:  NoisePatternIK,
+		prologue: "",
+		payloads: []string{
+			"746573745f6d73675f30",
+			"746573745f6d73675f31",
+			"79656c6c6f777375626d6172696e65",
+			"7375626d6172696e6579656c6c6f77",
+		},
+		messages: []string{
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254" +
+				"4f8445e5dc2467b1e32653192d05dee85c4781bf0dd8d33ceebb5905a7a069f0" +
+				"9e0d3f2cad1c842930a762eb75e528270337527f958f92050deefa1892482d74" +
+				"328fee90d08201bba3cc",
+			"64b101b1d0be5a8704bd078f9895001fc03e8e9f9522f188dd128d9846d48466" +
+				"cb4a35db52355821787bb891112ba10f4d3dfe08b27d634db8af",
+			"226ca869f2777611f37350a7ab446f650c0cfe2855b7f020ce658bcf100f2d",
+			"90d84d69cd44829283b05d684879b53b8d714e51619b601438a1ae67caacd9",
+		},
+	},
+	{
+		pattern:  NoisePatternIK,
+		prologue: "6e6f74736563726574",
+		payloads: []string{
+			"",
+			"",
+			"79656c6c6f777375626d6172696e65",
+			"7375626d6172696e6579656c6c6f77",
+		},
+		messages: []string{
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254" +
+				"4f8445e5dc2467b1e32653192d05dee85c4781bf0dd8d33ceebb5905a7a069f0" +
+				"d6bc97dbce6f8f0ee33d49311a72d0f8c4ef8ef3bc70ccb18fd61ad67dde7eda",
+			"64b101b1d0be5a8704bd078f9895001fc03e8e9f9522f188dd128d9846d48466" +
+				"787857f66c036e974ef9d6335d2ccc5f",
+			"226ca869f2777611f37350a7ab446f650c0cfe2855b7f020ce658bcf100f2d",
+			"90d84d69cd44829283b05d684879b53b8d714e51619b601438a1ae67caacd9",
+		},
+	},
+	{
+		pattern:  NoisePatternIK,
+		prologue: "6e6f74736563726574",
+		payloads: []string{
+			"746573745f6d73675f30",
+			"746573745f6d73675f31",
+			"79656c6c6f777375626d6172696e65",
+			"7375626d6172696e6579656c6c6f77",
+		},
+		messages: []string{
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254" +
+				"4f8445e5dc2467b1e32653192d05dee85c4781bf0dd8d33ceebb5905a7a069f0" +
+				"d6bc97dbce6f8f0ee33d49311a72d0f80337527f958f92050deee33c19777fa1" +
+				"7306346367055751bb3f",
+			"64b101b1d0be5a8704bd078f9895001fc03e8e9f9522f188dd128d9846d48466" +
+				"cb4a35db52355821787bb67f33957e7809370c44d33538ad5a42",
+			"226ca869f2777611f37350a7ab446f650c0cfe2855b7f020ce658bcf100f2d",
+			"90d84d69cd44829283b05d684879b53b8d714e51619b601438a1ae67caacd9",
+		},
+	},
+	{
+		pattern:  NoisePatternN,
+		prologue: "",
+		payloads: []string{
+			"",
+			"79656c6c6f777375626d6172696e65",
+			"7375626d6172696e6579656c6c6f77",
+		},
+		messages: []string{
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254" +
+				"66758477eb5e8e0b273460a89ef8d8bb",
+			"2e89db912502b14e9dbf21dc062b494ac2e25f2010ba86f246759fdb8bd990",
+			"505ffc87ec9cca139162b049416af8ca811e7044897d399912f9a139ac65ec",
+		},
+	},
+	{
+		pattern:  NoisePatternN,
+		prologue: "",
+		payloads: []string{
+			"746573745f6d73675f30",
+			"79656c6c6f777375626d6172696e65",
+			"7375626d6172696e6579656c6c6f77",
+		},
+		messages: []string{
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254" +
+				"a703e3bfcc38dbdb465b7d5ded3686008b3ff4c92f20e9fe4b44",
+			"2e89db912502b14e9dbf21dc062b494ac2e25f2010ba86f246759fdb8bd990",
+			"505ffc87ec9cca139162b049416af8ca811e7044897d399912f9a139ac65ec",
+		},
+	},
+	{
+		pattern:  NoisePatternN,
+		prologue: "6e6f74736563726574",
+		payloads: []string{
+			"",
+			"79656c6c6f777375626d6172696e65",
+			"7375626d6172696e6579656c6c6f77",
+		},
+		messages: []string{
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254" +
+				"39e0d27ade0e68178eedc32a520154b9",
+			"2e89db912502b14e9dbf21dc062b494ac2e25f2010ba86f246759fdb8bd990",
+			"505ffc87ec9cca139162b049416af8ca811e7044897d399912f9a139ac65ec",
+		},
+	},
+	{
+		pattern:  NoisePatternN,
+		prologue: "6e6f74736563726574",
+		payloads: []string{
+			"746573745f6d73675f30",
+			"79656c6c6f777375626d6172696e65",
+			"7375626d6172696e6579656c6c6f77",
+		},
+		messages: []string{
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254" +
+				"a703e3bfcc38dbdb465bc83726dbcf8aa4764c684931d2985245",
+			"2e89db912502b14e9dbf21dc062b494ac2e25f2010ba86f246759fdb8bd990",
+			"505ffc87ec9cca139162b049416af8ca811e7044897d399912f9a139ac65ec",
+		},
+	},
+}
+
+func noiseVectorKey(s string) *X25519PrivateKey {
+	var k X25519PrivateKey
+	data, _ := hex.DecodeString(s)
+	copy(k[:], data)
+	return &k
+}
+
+func TestNoiseVectors(t *testing.T) {
+	for _, vector := range noiseVectors {
+		name := "Noise_" + vector.pattern.Name + "_25519_ChaChaPoly_SHA256"
+		prologue, _ := hex.DecodeString(vector.prologue)
+		initiator_config := NoiseConfig{
+			Pattern:         vector.pattern,
+			ProtocolName:    name,
+			Prologue:        prologue,
+			Initiator:       true,
+			RemoteStaticKey: noiseTestPublic(noiseVectorKey(noiseVectorResponderStatic)),
+			EphemeralKey:    noiseVectorKey(noiseVectorInitiatorEphemeral),
+		}
+		if vector.pattern.Name != "N" {
+			initiator_config.StaticKey = noiseVectorKey(noiseVectorInitiatorStatic)
+		}
+		responder_config := NoiseConfig{
+			Pattern:      vector.pattern,
+			ProtocolName: name,
+			Prologue:     prologue,
+			StaticKey:    noiseVectorKey(noiseVectorResponderStatic),
+			EphemeralKey: noiseVectorKey(noiseVectorResponderEphemeral),
+		}
+		initiator, _ := NewNoiseHandshakeState(initiator_config)
+		responder, _ := NewNoiseHandshakeState(responder_config)
+
+		handshake_len := len(vector.pattern.Messages)
+		writer, reader := initiator, responder
+		for i := 0; i < handshake_len; i++ {
+			payload, _ := hex.DecodeString(vector.payloads[i])
+			msg, err := writer.WriteMessage(nil, payload)
+			if err != nil {
+				t.Fatalf("%s: failed to write message %d: %s", name, i, err.Error())
+			}
+			if hex.EncodeToString(msg) != vector.messages[i] {
+				t.Fatalf("%s: message %d is %x", name, i, msg)
+			}
+			received, err := reader.ReadMessage(nil, msg)
+			if err != nil || !bytes.Equal(received, payload) {
+				t.Fatalf("%s: failed to read message %d", name, i)
+			}
+			writer, reader = reader, writer
+		}
+
+		initiator_send, initiator_receive, err := initiator.Split()
+		if err != nil {
+			t.Fatalf("%s: failed to split: %s", name, err.Error())
+		}
+		responder_send, responder_receive, _ := responder.Split()
+		for i := handshake_len; i < len(vector.messages); i++ {
+			send, receive := initiator_send, responder_receive
+			if (i-handshake_len)%2 != 0 {
+				send, receive = responder_send, initiator_receive
+			}
+			payload, _ := hex.DecodeString(vector.payloads[i])
+			msg, _ := send.Seal(nil, payload, nil)
+			if hex.EncodeToString(msg) != vector.messages[i] {
+				t.Fatalf("%s: transport message %d is %x", name, i, msg)
+			}
+			if received, err := receive.Open(nil, msg, nil); err != nil || !bytes.Equal(received, payload) {
+				t.Fatalf("%s: failed to open transport message %d", name, i)
+			}
+		}
+	}
+}
+
+func TestNoiseHandshakeRoundTrip(t *testing.T) {
+	for _, vector := range noiseTestVectors {
+		initiator_config, responder_config := noiseTestConfigs(vector)
+		initiator, responder, messages := runNoiseHandshake(t, vector, initiator_config, responder_config)
+		if !initiator.Complete() || !responder.Complete() {
+			t.Fatalf("%s: handshake not complete", vector.pattern.Name)
+		}
+		if initiator.HandshakeHash() != responder.HandshakeHash() {
+			t.Fatalf("%s: handshake hashes differ", vector.pattern.Name)
+		}
+
+		// fixed keys give the same messages every run
+		initiator_config, responder_config = noiseTestConfigs(vector)
+		_, _, again := runNoiseHandshake(t, vector, initiator_config, responder_config)
+		for i := range messages {
+			if !bytes.Equal(messages[i], again[i]) {
+				t.Fatalf("%s: message %d is not deterministic", vector.pattern.Name, i)
+			}
+		}
+
+		// the initiator's ephemeral key is the first thing on the wire
+		if !bytes.Equal(messages[0][:NOISE_DH_LEN], noiseTestPublic(noiseTestKey(0x03))[:]) {
+			t.Fatalf("%s: first message does not start with the ephemeral key", vector.pattern.Name)
+		}
+
+		if vector.pattern.Name != "N" {
+			if remote, ok := responder.RemoteStaticKey(); !ok || remote != *noiseTestPublic(noiseTestKey(0x01)) {
+				t.Fatalf("%s: responder did not learn the initiator static key", vector.pattern.Name)
+			}
+		}
+
+		initiator_send, initiator_receive, err := initiator.Split()
+		if err != nil {
+			t.Fatalf("%s: failed to split: %s", vector.pattern.Name, err.Error())
+		}
+		responder_send, responder_receive, _ := responder.Split()
+		msg, _ := initiator_send.Seal(nil, []byte("data"), nil)
+		if _, err := responder_receive.Open(nil, msg, nil); err != nil {
+			t.Fatalf("%s: initiator to responder transport failed", vector.pattern.Name)
+		}
+		msg, _ = responder_send.Seal(nil, []byte("data"), nil)
+		if _, err := initiator_receive.Open(nil, msg, nil); err != nil {
+			t.Fatalf("%s: responder to initiator transport failed", vector.pattern.Name)
+		}
+	}
+}
+
+func TestNoiseMessageSizes(t *testing.T) {
+	vector := noiseTestVectors[2]
+	initiator_config, responder_config := noiseTestConfigs(vector)
+	_, _, messages := runNoiseHandshake(t, vector, initiator_config, responder_config)
+	// XK: e, es | e, ee | s, se, each with a payload mac
+	expected := []int{32 + 16, 32 + 16 + len(vector.payloads[1]), 48 + 16 + len(vector.payloads[2])}
+	for i, size := range expected {
+		if len(messages[i]) != size {
+			t.Fatalf("message %d is %d bytes, expected %d", i, len(messages[i]), size)
+		}
+	}
+}
+
+func TestNoisePrologueMismatch(t *testing.T) {
+	vector := noiseTestVectors[2]
+	initiator_config, responder_config := noiseTestConfigs(vector)
+	responder_config.Prologue = []byte("another prologue")
+	initiator, _ := NewNoiseHandshakeState(initiator_config)
+	responder, _ := NewNoiseHandshakeState(responder_config)
+	msg, _ := initiator.WriteMessage(nil, nil)
+	if _, err := responder.ReadMessage(nil, msg); err != ErrChaChaPolyDecryptionFailed {
+		t.Fatalf("message read with a different prologue")
+	}
+}
+
+func TestNoiseStateErrors(t *testing.T) {
+	vector := noiseTestVectors[1]
+	initiator_config, responder_config := noiseTestConfigs(vector)
+	initiator, _ := NewNoiseHandshakeState(initiator_config)
+	responder, _ := NewNoiseHandshakeState(responder_config)
+
+	if _, err := responder.WriteMessage(nil, nil); err != ErrNoiseWrongTurn {
+		t.Fatalf("responder wrote out of turn")
+	}
+	if _, _, err := initiator.Split(); err != ErrNoiseHandshakeIncomplete {
+		t.Fatalf("split before the handshake completed")
+	}
+	msg, _ := initiator.WriteMessage(nil, []byte("payload"))
+	if _, err := responder.ReadMessage(nil, msg[:40]); err != ErrNoiseMessageTooShort {
+		t.Fatalf("short message was read")
+	}
+
+	missing_static := initiator_config
+	missing_static.RemoteStaticKey = nil
+	if _, err := NewNoiseHandshakeState(missing_static); err != ErrNoiseMissingKey {
+		t.Fatalf("created IK initiator without the remote static key")
+	}
+	missing_static = initiator_config
+	missing_static.StaticKey = nil
+	initiator, _ = NewNoiseHandshakeState(missing_static)
+	if _, err := initiator.WriteMessage(nil, nil); err != ErrNoiseMissingKey {
+		t.Fatalf("wrote IK message without a static key")
+	}
+}
+
+// xor the ephemeral key with a shared mask, standing in for NTCP2's AES obfuscation
+func TestNoiseObfuscatedEphemeral(t *testing.T) {
+	mask := bytes.Repeat([]byte{0xa5}, NOISE_DH_LEN)
+	codec := NoisePlainEphemeral
+	codec.Encode = func(k X25519PublicKey) ([]byte, error) {
+		for i := range k {
+			k[i] ^= mask[i]
+		}
+		return k[:], nil
+	}
+	codec.Decode = func(data []byte) (k X25519PublicKey, err error) {
+		for i := range k {
+			k[i] = data[i] ^ mask[i]
+		}
+		return
+	}
+	vector := noiseTestVectors[2]
+	initiator_config, responder_config := noiseTestConfigs(vector)
+	initiator_config.Ephemeral = codec
+	responder_config.Ephemeral = codec
+	_, responder, messages := runNoiseHandshake(t, vector, initiator_config, responder_config)
+	ephemeral := noiseTestPublic(noiseTestKey(0x03))
+	if bytes.Equal(messages[0][:NOISE_DH_LEN], ephemeral[:]) {
+		t.Fatalf("ephemeral key was sent in the clear")
+	}
+	if remote, _ := responder.RemoteEphemeralKey(); remote != *ephemeral {
+		t.Fatalf("responder did not recover the ephemeral key")
+	}
+}
+
+// the ECIES New Session and New Session Reply are IK messages with elligator2 ephemeral keys
+func TestNoiseUnboundHandshake(t *testing.T) {
+	prologue := []byte("unbound")
+	responder_static := noiseVectorKey(noiseVectorResponderStatic)
+	ephemeral := noiseVectorKey(noiseVectorInitiatorEphemeral)
+	initiator_config := NoiseConfig{
+		Pattern:         NoisePatternIK,
+		ProtocolName:    NOISE_PROTOCOL_ECIES,
+		Prologue:        prologue,
+		Initiator:       true,
+		RemoteStaticKey: noiseTestPublic(responder_static),
+		EphemeralKey:    ephemeral,
+	}
+	initiator, _ := NewNoiseHandshakeState(initiator_config)
+	if _, err := initiator.WriteMessage(nil, []byte("one-time")); err != ErrNoiseMissingKey {
+		t.Fatalf("initiator without a static key wrote a bound handshake")
+	}
+
+	initiator_config.AllowUnbound = true
+	initiator, _ = NewNoiseHandshakeState(initiator_config)
+	msg, err := initiator.WriteMessage(nil, []byte("one-time"))
+	if err != nil {
+		t.Fatalf("failed to write unbound message: %s", err.Error())
+	}
+
+	// e, es, then an all zero static key and no ss, with the payload at the next nonce of the es key
+	expected_state := NewSymmetricState(NOISE_PROTOCOL_ECIES)
+	expected_state.MixHash(prologue)
+	expected_state.MixHash(noiseTestPublic(responder_static)[:])
+	e := noiseTestPublic(ephemeral)
+	expected_state.MixHash(e[:])
+	es, _ := ephemeral.SharedSecret(*noiseTestPublic(responder_static))
+	expected_state.MixKey(es)
+	expected := append([]byte{}, e[:]...)
+	expected, _ = expected_state.EncryptAndHash(expected, make([]byte, NOISE_DH_LEN))
+	expected, _ = expected_state.EncryptAndHash(expected, []byte("one-time"))
+	if !bytes.Equal(msg, expected) {
+		t.Fatalf("unbound message is %x, expected %x", msg, expected)
+	}
+
+	responder, _ := NewNoiseHandshakeState(NoiseConfig{
+		Pattern:      NoisePatternIK,
+		ProtocolName: NOISE_PROTOCOL_ECIES,
+		Prologue:     prologue,
+		StaticKey:    responder_static,
+		AllowUnbound: true,
+	})
+	payload, err := responder.ReadMessage(nil, msg)
+	if err != nil || string(payload) != "one-time" {
+		t.Fatalf("responder could not read the unbound message")
+	}
+	if remote, ok := responder.RemoteStaticKey(); !ok || remote != (X25519PublicKey{}) {
+		t.Fatalf("remote static key of an unbound message is not all zeros")
+	}
+	if responder.HandshakeHash() != expected_state.HandshakeHash() {
+		t.Fatalf("unbound handshake hash does not match")
+	}
+	// there is no static key to reply to
+	if _, err := responder.WriteMessage(nil, nil); err != ErrNoiseMissingKey {
+		t.Fatalf("responder replied to an unbound message")
+	}
+}
@@ -0,0 +1,173 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"filippo.io/edwards25519"
+	"io"
+)
+
+// a RedDSA public key, an ed25519 point, signatures verify as standard ed25519 signatures
+type RedDSAPublicKey [32]byte
+
+func (k RedDSAPublicKey) NewVerifier() (v Verifier, err error) {
+	return Ed25519PublicKey(k).NewVerifier()
+}
+
+func (k RedDSAPublicKey) Len() int {
+	return len(k)
+}
+
+func (k RedDSAPublicKey) Bytes() []byte {
+	return k[:]
+}
+
+// blind a RedDSA public key, computing A' = A + [alpha]B
+func (k RedDSAPublicKey) Blind(alpha []byte) (blinded RedDSAPublicKey, err error) {
+	ed25519_key, err := Ed25519PublicKey(k).Blind(alpha)
+	if err == nil {
+		blinded = RedDSAPublicKey(ed25519_key)
+	}
+	return
+}
+
+// a RedDSA private key, the 32 byte little-endian scalar a reduced mod L
+type RedDSAPrivateKey [32]byte
+
+// get the RedDSA private key of an ed25519 seed, the clamped scalar from its expanded seed
+func (k Ed25519PrivateKey) RedDSA() (rk RedDSAPrivateKey) {
+	h := sha512.Sum512(k[:])
+	a, _ := edwards25519.NewScalar().SetBytesWithClamping(h[:32])
+	copy(rk[:], a.Bytes())
+	return
+}
+
+func (k RedDSAPrivateKey) scalar() (a *edwards25519.Scalar, err error) {
+	a, err = edwards25519.NewScalar().SetCanonicalBytes(k[:])
+	if err != nil {
+		err = ErrInvalidKeyFormat
+	}
+	return
+}
+
+func (k RedDSAPrivateKey) newRedDSASigner() (s *RedDSASigner, err error) {
+	a, err := k.scalar()
+	if err == nil {
+		s = newRedDSASigner(a)
+	}
+	return
+}
+
+func (k RedDSAPrivateKey) NewSigner() (s Signer, err error) {
+	rs, err := k.newRedDSASigner()
+	if err == nil {
+		s = rs
+	}
+	return
+}
+
+func (k RedDSAPrivateKey) Len() int {
+	return len(k)
+}
+
+func (k RedDSAPrivateKey) Bytes() []byte {
+	return k[:]
+}
+
+// get the public key A = [a]B
+func (k RedDSAPrivateKey) Public() (pk SigningPublicKey, err error) {
+	a, err := k.scalar()
+	if err != nil {
+		return
+	}
+	var public_key RedDSAPublicKey
+	copy(public_key[:], new(edwards25519.Point).ScalarBaseMult(a).Bytes())
+	pk = public_key
+	return
+}
+
+// generate a new private key by reducing 64 random bytes mod L
+func (k RedDSAPrivateKey) Generate() (s SigningPrivateKey, err error) {
+	seed := make([]byte, 64)
+	_, err = io.ReadFull(rand.Reader, seed)
+	if err != nil {
+		return
+	}
+	a, _ := edwards25519.NewScalar().SetUniformBytes(seed)
+	copy(k[:], a.Bytes())
+	s = k
+	return
+}
+
+// blind a RedDSA private key, computing a' = a + alpha mod L
+func (k RedDSAPrivateKey) Blind(alpha []byte) (blinded RedDSAPrivateKey, err error) {
+	blinding, err := edwards25519.NewScalar().SetCanonicalBytes(alpha)
+	if err != nil {
+		err = ErrBadBlindingFactor
+		return
+	}
+	a, err := k.scalar()
+	if err != nil {
+		return
+	}
+	copy(blinded[:], edwards25519.NewScalar().Add(a, blinding).Bytes())
+	return
+}
+
+//
+// signs data with a raw ed25519 scalar using randomized nonces as in RedDSA,
+// signatures verify with a standard ed25519 verifier.  the random bytes of each
+// nonce are read from Rand, or from crypto/rand if Rand is nil
+//
+type RedDSASigner struct {
+	a    *edwards25519.Scalar
+	A    []byte
+	Rand io.Reader
+}
+
+func newRedDSASigner(a *edwards25519.Scalar) *RedDSASigner {
+	return &RedDSASigner{
+		a: a,
+		A: new(edwards25519.Point).ScalarBaseMult(a).Bytes(),
+	}
+}
+
+// get the public key of this signer
+func (s *RedDSASigner) Public() (k Ed25519PublicKey) {
+	copy(k[:], s.A)
+	return
+}
+
+func (s *RedDSASigner) Sign(data []byte) (sig []byte, err error) {
+	sig, err = s.SignHash(data)
+	return
+}
+
+// sign a prehashed message, RedDSA signs the hash as the message
+func (s *RedDSASigner) SignHash(h []byte) (sig []byte, err error) {
+	// r = H*(T || A || M) with 80 random bytes T
+	random := s.Rand
+	if random == nil {
+		random = rand.Reader
+	}
+	t := make([]byte, 80)
+	_, err = io.ReadFull(random, t)
+	if err != nil {
+		return
+	}
+	rh := sha512.New()
+	rh.Write(t)
+	rh.Write(s.A)
+	rh.Write(h)
+	r, _ := edwards25519.NewScalar().SetUniformBytes(rh.Sum(nil))
+	R := new(edwards25519.Point).ScalarBaseMult(r).Bytes()
+	// S = r + H*(R || A || M) * a
+	kh := sha512.New()
+	kh.Write(R)
+	kh.Write(s.A)
+	kh.Write(h)
+	c, _ := edwards25519.NewScalar().SetUniformBytes(kh.Sum(nil))
+	S := edwards25519.NewScalar().MultiplyAdd(c, s.a, r)
+	sig = append(R, S.Bytes()...)
+	return
+}
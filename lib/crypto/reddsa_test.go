@@ -0,0 +1,186 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"filippo.io/edwards25519"
+	"io"
+	"testing"
+)
+
+func TestRedDSAPublicKeyOfScalarOne(t *testing.T) {
+	// the encoding of the ed25519 base point B
+	expected, _ := hex.DecodeString("5866666666666666666666666666666666666666666666666666666666666666")
+	sk := RedDSAPrivateKey{0: 0x01}
+	pk, err := sk.Public()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err.Error())
+	}
+	if !bytes.Equal(pk.Bytes(), expected) {
+		t.Logf("public key of scalar 1 is not the base point")
+		t.Fail()
+	}
+}
+
+// RFC 8032 section 7.1, test 1, signed with the scalar of the expanded seed
+func TestRedDSAFromEd25519Vector(t *testing.T) {
+	seed, _ := hex.DecodeString("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	public_key, _ := hex.DecodeString("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+	var ed25519_key Ed25519PrivateKey
+	copy(ed25519_key[:], seed)
+	sk := ed25519_key.RedDSA()
+	pk, err := sk.Public()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err.Error())
+	}
+	if !bytes.Equal(pk.Bytes(), public_key) {
+		t.Logf("public key does not match test vector")
+		t.Fail()
+	}
+
+	signer, _ := sk.NewSigner()
+	data := make([]byte, 512)
+	io.ReadFull(rand.Reader, data)
+	sig, err := signer.Sign(data)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+	var ed25519_public_key Ed25519PublicKey
+	copy(ed25519_public_key[:], public_key)
+	v, _ := ed25519_public_key.NewVerifier()
+	err = v.Verify(data, sig)
+	if err != nil {
+		t.Logf("RedDSA signature did not verify as ed25519: %s", err.Error())
+		t.Fail()
+	}
+}
+
+func TestRedDSAPrivateKeySigning(t *testing.T) {
+	var sk RedDSAPrivateKey
+	generated, err := sk.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	pk, _ := generated.Public()
+	signer, err := generated.NewSigner()
+	if err != nil {
+		t.Fatalf("failed to create signer: %s", err.Error())
+	}
+	data := make([]byte, 512)
+	io.ReadFull(rand.Reader, data)
+	sig, _ := signer.Sign(data)
+	other_sig, _ := signer.Sign(data)
+	if bytes.Equal(sig, other_sig) {
+		t.Logf("RedDSA signatures did not use random nonces")
+		t.Fail()
+	}
+	v, _ := pk.NewVerifier()
+	if v.Verify(data, sig) != nil || v.Verify(data, other_sig) != nil {
+		t.Logf("RedDSA signature did not verify")
+		t.Fail()
+	}
+	data[0] ^= 0xff
+	if v.Verify(data, sig) != ErrInvalidSignature {
+		t.Logf("invalid signature verified")
+		t.Fail()
+	}
+}
+
+func TestRedDSABlinding(t *testing.T) {
+	var sk RedDSAPrivateKey
+	generated, _ := sk.Generate()
+	sk = generated.(RedDSAPrivateKey)
+	public_key, _ := sk.Public()
+	pk := public_key.(RedDSAPublicKey)
+	alpha := generateBlindingFactor(t)
+
+	blinded_sk, err := sk.Blind(alpha)
+	if err != nil {
+		t.Fatalf("failed to blind private key: %s", err.Error())
+	}
+	blinded_pk, err := pk.Blind(alpha)
+	if err != nil {
+		t.Fatalf("failed to blind public key: %s", err.Error())
+	}
+	derived_pk, _ := blinded_sk.Public()
+	if derived_pk != blinded_pk {
+		t.Logf("blinded private key does not match blinded public key")
+		t.Fail()
+	}
+
+	signer, _ := blinded_sk.NewSigner()
+	data := make([]byte, 512)
+	io.ReadFull(rand.Reader, data)
+	sig, _ := signer.Sign(data)
+	v, _ := blinded_pk.NewVerifier()
+	if v.Verify(data, sig) != nil {
+		t.Logf("blinded signature did not verify")
+		t.Fail()
+	}
+	if _, err := sk.Blind(bytes.Repeat([]byte{0xff}, 32)); err != ErrBadBlindingFactor {
+		t.Logf("blinded private key with non-canonical factor: %v", err)
+		t.Fail()
+	}
+}
+
+func TestRedDSARejectsNonCanonicalScalar(t *testing.T) {
+	var sk RedDSAPrivateKey
+	copy(sk[:], bytes.Repeat([]byte{0xff}, 32))
+	if _, err := sk.NewSigner(); err != ErrInvalidKeyFormat {
+		t.Logf("non-canonical scalar accepted by signer")
+		t.Fail()
+	}
+	if _, err := sk.Public(); err != ErrInvalidKeyFormat {
+		t.Logf("non-canonical scalar accepted by public")
+		t.Fail()
+	}
+}
+
+func TestRedDSASignerRand(t *testing.T) {
+	// RFC 8032 section 7.1, test 1
+	seed, _ := hex.DecodeString("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	public_key, _ := hex.DecodeString("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+	var sk Ed25519PrivateKey
+	copy(sk[:], seed)
+	s, err := sk.RedDSA().newRedDSASigner()
+	if err != nil {
+		t.Fatalf("failed to create signer: %s", err.Error())
+	}
+	if !bytes.Equal(s.A, public_key) {
+		t.Fatalf("public key of the RedDSA scalar is %x", s.A)
+	}
+
+	random := make([]byte, 80)
+	for i := range random {
+		random[i] = byte(i)
+	}
+	message := []byte("RedDSA")
+	s.Rand = bytes.NewReader(random)
+	sig, err := s.Sign(message)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+	// R = [r]B with r = H*(T || A || M)
+	h := sha512.New()
+	h.Write(random)
+	h.Write(public_key)
+	h.Write(message)
+	r, _ := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	if !bytes.Equal(sig[:32], new(edwards25519.Point).ScalarBaseMult(r).Bytes()) {
+		t.Fatalf("nonce was not derived from the random bytes")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(public_key), message, sig) {
+		t.Fatalf("signature did not verify with crypto/ed25519")
+	}
+	s.Rand = bytes.NewReader(random)
+	if again, _ := s.Sign(message); !bytes.Equal(sig, again) {
+		t.Fatalf("signatures with the same random bytes differ")
+	}
+	s.Rand = bytes.NewReader(random[:79])
+	if _, err := s.Sign(message); err == nil {
+		t.Fatalf("signed with too few random bytes")
+	}
+}
@@ -0,0 +1,420 @@
+package crypto
+
+import (
+	"crypto"
+	"errors"
+	"sort"
+	"sync"
+)
+
+var ErrUnknownSignatureType = errors.New("unknown signature type")
+var ErrSignatureTypeDisabled = errors.New("signature type disabled by policy")
+var ErrUnknownEncryptionType = errors.New("unknown encryption type")
+var ErrEncryptionTypeDisabled = errors.New("encryption type disabled by policy")
+
+//
+// a signature type as numbered in key certificates, with its sizes, the hash its signatures
+// cover and constructors for its keys from raw bytes of the right size
+//
+type SignatureType struct {
+	Code           int
+	Name           string
+	PublicKeySize  int
+	PrivateKeySize int
+	SignatureSize  int
+	Hash           crypto.Hash
+	// deprecated types may be refused with RejectDeprecated
+	Deprecated   bool
+	NewPublicKey func(data []byte) (SigningPublicKey, error)
+	// nil when the private key does not implement SigningPrivateKey
+	NewPrivateKey func(data []byte) (SigningPrivateKey, error)
+}
+
+// an encryption type as numbered in key certificates
+type EncryptionType struct {
+	Code           int
+	Name           string
+	PublicKeySize  int
+	PrivateKeySize int
+	Deprecated     bool
+	NewPublicKey   func(data []byte) (PublicKey, error)
+}
+
+//
+// decides whether a registered type may be used, lookups of refused types fail with the
+// disabled errors so every parser built on the registry rejects them
+//
+type SignaturePolicy func(SignatureType) bool
+type EncryptionPolicy func(EncryptionType) bool
+
+// a policy allowing every signature type that is not deprecated, such as DSA_SHA1
+func RejectDeprecated(t SignatureType) bool {
+	return !t.Deprecated
+}
+
+// a policy allowing every encryption type that is not deprecated
+func RejectDeprecatedEncryption(t EncryptionType) bool {
+	return !t.Deprecated
+}
+
+var registry = struct {
+	mutex             sync.RWMutex
+	signature_types   map[int]SignatureType
+	encryption_types  map[int]EncryptionType
+	signature_policy  SignaturePolicy
+	encryption_policy EncryptionPolicy
+}{
+	signature_types:  make(map[int]SignatureType),
+	encryption_types: make(map[int]EncryptionType),
+}
+
+// add or replace a signature type
+func RegisterSignatureType(t SignatureType) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.signature_types[t.Code] = t
+}
+
+// add or replace an encryption type
+func RegisterEncryptionType(t EncryptionType) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.encryption_types[t.Code] = t
+}
+
+// remove a signature type, which is then unknown
+func UnregisterSignatureType(code int) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	delete(registry.signature_types, code)
+}
+
+// remove an encryption type, which is then unknown
+func UnregisterEncryptionType(code int) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	delete(registry.encryption_types, code)
+}
+
+// set the policy for signature types, nil allows every registered type
+func SetSignaturePolicy(policy SignaturePolicy) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.signature_policy = policy
+}
+
+// set the policy for encryption types, nil allows every registered type
+func SetEncryptionPolicy(policy EncryptionPolicy) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.encryption_policy = policy
+}
+
+func SignatureTypeByCode(code int) (t SignatureType, err error) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	t, ok := registry.signature_types[code]
+	if !ok {
+		err = ErrUnknownSignatureType
+		return
+	}
+	err = checkSignaturePolicy(t)
+	return
+}
+
+func SignatureTypeByName(name string) (t SignatureType, err error) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	for _, t = range registry.signature_types {
+		if t.Name == name {
+			err = checkSignaturePolicy(t)
+			return
+		}
+	}
+	err = ErrUnknownSignatureType
+	t = SignatureType{}
+	return
+}
+
+func EncryptionTypeByCode(code int) (t EncryptionType, err error) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	t, ok := registry.encryption_types[code]
+	if !ok {
+		err = ErrUnknownEncryptionType
+		return
+	}
+	err = checkEncryptionPolicy(t)
+	return
+}
+
+func EncryptionTypeByName(name string) (t EncryptionType, err error) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	for _, t = range registry.encryption_types {
+		if t.Name == name {
+			err = checkEncryptionPolicy(t)
+			return
+		}
+	}
+	err = ErrUnknownEncryptionType
+	t = EncryptionType{}
+	return
+}
+
+// the signature types allowed by the policy, ordered by code
+func SignatureTypes() (types []SignatureType) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	for _, t := range registry.signature_types {
+		if checkSignaturePolicy(t) == nil {
+			types = append(types, t)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Code < types[j].Code
+	})
+	return
+}
+
+// the encryption types allowed by the policy, ordered by code
+func EncryptionTypes() (types []EncryptionType) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	for _, t := range registry.encryption_types {
+		if checkEncryptionPolicy(t) == nil {
+			types = append(types, t)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Code < types[j].Code
+	})
+	return
+}
+
+func checkSignaturePolicy(t SignatureType) error {
+	if registry.signature_policy != nil && !registry.signature_policy(t) {
+		return ErrSignatureTypeDisabled
+	}
+	return nil
+}
+
+func checkEncryptionPolicy(t EncryptionType) error {
+	if registry.encryption_policy != nil && !registry.encryption_policy(t) {
+		return ErrEncryptionTypeDisabled
+	}
+	return nil
+}
+
+// build a fixed size key from data of exactly its size
+func copyKey(key, data []byte) error {
+	if len(data) != len(key) {
+		return ErrInvalidKeyFormat
+	}
+	copy(key, data)
+	return nil
+}
+
+func init() {
+	RegisterSignatureType(SignatureType{
+		Code:           0,
+		Name:           "DSA_SHA1",
+		PublicKeySize:  128,
+		PrivateKeySize: 20,
+		SignatureSize:  40,
+		Hash:           crypto.SHA1,
+		Deprecated:     true,
+		NewPublicKey: func(data []byte) (SigningPublicKey, error) {
+			var k DSAPublicKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+	})
+	RegisterSignatureType(SignatureType{
+		Code:           1,
+		Name:           "ECDSA_SHA256_P256",
+		PublicKeySize:  64,
+		PrivateKeySize: 32,
+		SignatureSize:  64,
+		Hash:           crypto.SHA256,
+		NewPublicKey: func(data []byte) (SigningPublicKey, error) {
+			var k ECP256PublicKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+		NewPrivateKey: func(data []byte) (SigningPrivateKey, error) {
+			var k ECP256PrivateKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+	})
+	RegisterSignatureType(SignatureType{
+		Code:           2,
+		Name:           "ECDSA_SHA384_P384",
+		PublicKeySize:  96,
+		PrivateKeySize: 48,
+		SignatureSize:  96,
+		Hash:           crypto.SHA384,
+		NewPublicKey: func(data []byte) (SigningPublicKey, error) {
+			var k ECP384PublicKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+		NewPrivateKey: func(data []byte) (SigningPrivateKey, error) {
+			var k ECP384PrivateKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+	})
+	RegisterSignatureType(SignatureType{
+		Code:           3,
+		Name:           "ECDSA_SHA512_P521",
+		PublicKeySize:  132,
+		PrivateKeySize: 66,
+		SignatureSize:  132,
+		Hash:           crypto.SHA512,
+		NewPublicKey: func(data []byte) (SigningPublicKey, error) {
+			var k ECP521PublicKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+		NewPrivateKey: func(data []byte) (SigningPrivateKey, error) {
+			var k ECP521PrivateKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+	})
+	RegisterSignatureType(SignatureType{
+		Code:           4,
+		Name:           "RSA_SHA256_2048",
+		PublicKeySize:  256,
+		PrivateKeySize: 512,
+		SignatureSize:  256,
+		Hash:           crypto.SHA256,
+		NewPublicKey: func(data []byte) (SigningPublicKey, error) {
+			var k RSA2048PublicKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+		NewPrivateKey: func(data []byte) (SigningPrivateKey, error) {
+			var k RSA2048PrivateKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+	})
+	RegisterSignatureType(SignatureType{
+		Code:           5,
+		Name:           "RSA_SHA384_3072",
+		PublicKeySize:  384,
+		PrivateKeySize: 768,
+		SignatureSize:  384,
+		Hash:           crypto.SHA384,
+		NewPublicKey: func(data []byte) (SigningPublicKey, error) {
+			var k RSA3072PublicKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+		NewPrivateKey: func(data []byte) (SigningPrivateKey, error) {
+			var k RSA3072PrivateKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+	})
+	RegisterSignatureType(SignatureType{
+		Code:           6,
+		Name:           "RSA_SHA512_4096",
+		PublicKeySize:  512,
+		PrivateKeySize: 1024,
+		SignatureSize:  512,
+		Hash:           crypto.SHA512,
+		NewPublicKey: func(data []byte) (SigningPublicKey, error) {
+			var k RSA4096PublicKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+		NewPrivateKey: func(data []byte) (SigningPrivateKey, error) {
+			var k RSA4096PrivateKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+	})
+	RegisterSignatureType(SignatureType{
+		Code:           7,
+		Name:           "EdDSA_SHA512_Ed25519",
+		PublicKeySize:  32,
+		PrivateKeySize: 32,
+		SignatureSize:  64,
+		Hash:           crypto.SHA512,
+		NewPublicKey: func(data []byte) (SigningPublicKey, error) {
+			var k Ed25519PublicKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+		NewPrivateKey: func(data []byte) (SigningPrivateKey, error) {
+			var k Ed25519PrivateKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+	})
+	RegisterSignatureType(SignatureType{
+		Code:           8,
+		Name:           "EdDSA_SHA512_Ed25519ph",
+		PublicKeySize:  32,
+		PrivateKeySize: 32,
+		SignatureSize:  64,
+		Hash:           crypto.SHA512,
+		NewPublicKey: func(data []byte) (SigningPublicKey, error) {
+			var k Ed25519phPublicKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+		NewPrivateKey: func(data []byte) (SigningPrivateKey, error) {
+			var k Ed25519phPrivateKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+	})
+	// 9 and 10 are reserved for GOST
+	RegisterSignatureType(SignatureType{
+		Code:           11,
+		Name:           "RedDSA_SHA512_Ed25519",
+		PublicKeySize:  32,
+		PrivateKeySize: 32,
+		SignatureSize:  64,
+		Hash:           crypto.SHA512,
+		NewPublicKey: func(data []byte) (SigningPublicKey, error) {
+			var k RedDSAPublicKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+		NewPrivateKey: func(data []byte) (SigningPrivateKey, error) {
+			var k RedDSAPrivateKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+	})
+
+	RegisterEncryptionType(EncryptionType{
+		Code:           0,
+		Name:           "ELGAMAL_2048",
+		PublicKeySize:  256,
+		PrivateKeySize: 256,
+		NewPublicKey: func(data []byte) (PublicKey, error) {
+			var k ElgPublicKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+	})
+	// 1 through 3 are reserved for ECIES on the NIST curves
+	RegisterEncryptionType(EncryptionType{
+		Code:           4,
+		Name:           "ECIES_X25519",
+		PublicKeySize:  32,
+		PrivateKeySize: 32,
+		NewPublicKey: func(data []byte) (PublicKey, error) {
+			var k X25519PublicKey
+			err := copyKey(k[:], data)
+			return k, err
+		},
+	})
+}
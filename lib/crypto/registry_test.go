@@ -0,0 +1,121 @@
+package crypto
+
+import (
+	"crypto"
+	"testing"
+)
+
+func TestSignatureTypeLookup(t *testing.T) {
+	by_code, err := SignatureTypeByCode(7)
+	if err != nil {
+		t.Fatalf("failed to look up signature type 7: %s", err.Error())
+	}
+	by_name, err := SignatureTypeByName("EdDSA_SHA512_Ed25519")
+	if err != nil {
+		t.Fatalf("failed to look up signature type by name: %s", err.Error())
+	}
+	if by_code.Code != by_name.Code || by_code.Hash != crypto.SHA512 || by_code.SignatureSize != 64 {
+		t.Fatalf("unexpected signature type %+v", by_name)
+	}
+	if _, err := SignatureTypeByCode(9); err != ErrUnknownSignatureType {
+		t.Fatalf("looked up reserved signature type 9")
+	}
+	if _, err := SignatureTypeByName("GOST"); err != ErrUnknownSignatureType {
+		t.Fatalf("looked up unknown signature type by name")
+	}
+	if _, err := EncryptionTypeByName("ECIES_X25519"); err != nil {
+		t.Fatalf("failed to look up encryption type by name: %s", err.Error())
+	}
+	if _, err := EncryptionTypeByCode(1); err != ErrUnknownEncryptionType {
+		t.Fatalf("looked up reserved encryption type 1")
+	}
+}
+
+// every registered constructor builds keys of the registered sizes
+func TestRegisteredKeySizes(t *testing.T) {
+	for _, signature_type := range SignatureTypes() {
+		public_key, err := signature_type.NewPublicKey(make([]byte, signature_type.PublicKeySize))
+		if err != nil || public_key.Len() != signature_type.PublicKeySize {
+			t.Fatalf("%s: public key does not match registered size", signature_type.Name)
+		}
+		if _, err := signature_type.NewPublicKey(make([]byte, signature_type.PublicKeySize+1)); err != ErrInvalidKeyFormat {
+			t.Fatalf("%s: built public key from data of the wrong size", signature_type.Name)
+		}
+		if signature_type.NewPrivateKey == nil {
+			continue
+		}
+		private_key, err := signature_type.NewPrivateKey(make([]byte, signature_type.PrivateKeySize))
+		if err != nil || private_key.Len() != signature_type.PrivateKeySize {
+			t.Fatalf("%s: private key does not match registered size", signature_type.Name)
+		}
+	}
+	for _, encryption_type := range EncryptionTypes() {
+		public_key, err := encryption_type.NewPublicKey(make([]byte, encryption_type.PublicKeySize))
+		if err != nil || public_key.Len() != encryption_type.PublicKeySize {
+			t.Fatalf("%s: public key does not match registered size", encryption_type.Name)
+		}
+	}
+}
+
+func TestRejectDeprecatedPolicy(t *testing.T) {
+	SetSignaturePolicy(RejectDeprecated)
+	defer SetSignaturePolicy(nil)
+
+	if _, err := SignatureTypeByCode(0); err != ErrSignatureTypeDisabled {
+		t.Fatalf("DSA_SHA1 was not disabled")
+	}
+	if _, err := SignatureTypeByName("DSA_SHA1"); err != ErrSignatureTypeDisabled {
+		t.Fatalf("DSA_SHA1 was not disabled by name")
+	}
+	for _, signature_type := range SignatureTypes() {
+		if signature_type.Code == 0 {
+			t.Fatalf("disabled type was listed")
+		}
+	}
+	if _, err := SignatureTypeByCode(7); err != nil {
+		t.Fatalf("Ed25519 was disabled")
+	}
+
+	SetSignaturePolicy(nil)
+	if _, err := SignatureTypeByCode(0); err != nil {
+		t.Fatalf("DSA_SHA1 was not enabled again")
+	}
+}
+
+func TestEncryptionPolicy(t *testing.T) {
+	SetEncryptionPolicy(func(encryption_type EncryptionType) bool {
+		return encryption_type.Name != "ELGAMAL_2048"
+	})
+	defer SetEncryptionPolicy(nil)
+
+	if _, err := EncryptionTypeByCode(0); err != ErrEncryptionTypeDisabled {
+		t.Fatalf("ElGamal was not disabled")
+	}
+	if types := EncryptionTypes(); len(types) != 1 || types[0].Code != 4 {
+		t.Fatalf("unexpected encryption types %+v", types)
+	}
+}
+
+func TestUnregisterTypes(t *testing.T) {
+	signature_type, _ := SignatureTypeByCode(7)
+	signature_type.Code = 65281
+	RegisterSignatureType(signature_type)
+	if _, err := SignatureTypeByCode(65281); err != nil {
+		t.Fatalf("registered type was not found")
+	}
+	UnregisterSignatureType(65281)
+	if _, err := SignatureTypeByCode(65281); err != ErrUnknownSignatureType {
+		t.Fatalf("unregistered type was still found")
+	}
+
+	encryption_type, _ := EncryptionTypeByCode(4)
+	encryption_type.Code = 65281
+	RegisterEncryptionType(encryption_type)
+	UnregisterEncryptionType(65281)
+	if _, err := EncryptionTypeByCode(65281); err != ErrUnknownEncryptionType {
+		t.Fatalf("unregistered type was still found")
+	}
+	if _, err := EncryptionTypeByCode(4); err != nil {
+		t.Fatalf("ECIES_X25519 was removed")
+	}
+}
@@ -1,10 +1,296 @@
 package crypto
 
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"math/big"
+)
+
+// public exponent of all rsa keys in i2p
+const RSA_PUBLIC_EXPONENT = 65537
+
+type RSAVerifier struct {
+	k *rsa.PublicKey
+	h crypto.Hash
+}
+
+// verify a PKCS#1 v1.5 signature given the hash
+func (v *RSAVerifier) VerifyHash(h, sig []byte) (err error) {
+	if len(sig) != v.k.Size() {
+		err = ErrBadSignatureSize
+	} else if rsa.VerifyPKCS1v15(v.k, v.h, h, sig) != nil {
+		err = ErrInvalidSignature
+	}
+	return
+}
+
+// verify a block of data by hashing it and comparing the hash against the signature
+func (v *RSAVerifier) Verify(data, sig []byte) (err error) {
+	hasher := v.h.New()
+	hasher.Write(data)
+	h := hasher.Sum(nil)
+	err = v.VerifyHash(h, sig)
+	return
+}
+
+// create an rsa verifier given the big-endian modulus of the public key
+func createRSAVerifier(h crypto.Hash, k []byte) (rv *RSAVerifier, err error) {
+	n := new(big.Int).SetBytes(k)
+	if n.BitLen() != len(k)*8 {
+		err = ErrInvalidKeyFormat
+		return
+	}
+	rv = &RSAVerifier{
+		k: &rsa.PublicKey{
+			N: n,
+			E: RSA_PUBLIC_EXPONENT,
+		},
+		h: h,
+	}
+	return
+}
+
+type RSASigner struct {
+	k *rsa.PrivateKey
+	h crypto.Hash
+}
+
+// sign a block of data by hashing it and signing the hash
+func (s *RSASigner) Sign(data []byte) (sig []byte, err error) {
+	hasher := s.h.New()
+	hasher.Write(data)
+	sig, err = s.SignHash(hasher.Sum(nil))
+	return
+}
+
+// sign a hash with PKCS#1 v1.5 padding
+func (s *RSASigner) SignHash(h []byte) (sig []byte, err error) {
+	sig, err = rsa.SignPKCS1v15(rand.Reader, s.k, s.h, h)
+	return
+}
+
+//
+// create an rsa signer given a private key stored as the big-endian modulus
+// followed by the private exponent, as i2p stores them
+//
+func createRSASigner(h crypto.Hash, k []byte) (rs *RSASigner, err error) {
+	size := len(k) / 2
+	priv := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{
+			N: new(big.Int).SetBytes(k[:size]),
+			E: RSA_PUBLIC_EXPONENT,
+		},
+		D: new(big.Int).SetBytes(k[size:]),
+	}
+	if priv.N.BitLen() != size*8 {
+		err = ErrInvalidKeyFormat
+		return
+	}
+	// i2p does not store the primes, recover them so signing can use the CRT
+	priv.Primes, err = recoverRSAPrimes(priv.N, priv.D)
+	if err == nil {
+		err = priv.Validate()
+	}
+	if err != nil {
+		err = ErrInvalidKeyFormat
+		return
+	}
+	priv.Precompute()
+	rs = &RSASigner{
+		k: priv,
+		h: h,
+	}
+	return
+}
+
+//
+// factor the modulus of an rsa key given its private exponent, following the
+// randomized algorithm in NIST SP 800-56B appendix C
+//
+func recoverRSAPrimes(n, d *big.Int) (primes []*big.Int, err error) {
+	one := big.NewInt(1)
+	n_minus_one := new(big.Int).Sub(n, one)
+	// write d*e - 1 = 2^t * r with r odd
+	k := new(big.Int).Mul(d, big.NewInt(RSA_PUBLIC_EXPONENT))
+	k.Sub(k, one)
+	t := 0
+	r := new(big.Int).Set(k)
+	for r.Bit(0) == 0 && r.Sign() > 0 {
+		r.Rsh(r, 1)
+		t++
+	}
+	if t == 0 {
+		err = ErrInvalidKeyFormat
+		return
+	}
+	for i := 0; i < 100; i++ {
+		var g *big.Int
+		g, err = rand.Int(rand.Reader, n_minus_one)
+		if err != nil {
+			return
+		}
+		if g.Cmp(one) <= 0 {
+			continue
+		}
+		y := new(big.Int).Exp(g, r, n)
+		if y.Cmp(one) == 0 || y.Cmp(n_minus_one) == 0 {
+			continue
+		}
+		for j := 1; j <= t; j++ {
+			x := new(big.Int).Exp(y, big.NewInt(2), n)
+			if x.Cmp(one) == 0 {
+				p := new(big.Int).GCD(nil, nil, new(big.Int).Sub(y, one), n)
+				q := new(big.Int).Div(n, p)
+				primes = []*big.Int{p, q}
+				return
+			}
+			if x.Cmp(n_minus_one) == 0 {
+				break
+			}
+			y = x
+		}
+	}
+	err = ErrInvalidKeyFormat
+	return
+}
+
+// generate a new rsa key and store it as the modulus followed by the private exponent
+func generateRSAKey(k []byte) (err error) {
+	size := len(k) / 2
+	priv, err := rsa.GenerateKey(rand.Reader, size*8)
+	if err == nil {
+		priv.N.FillBytes(k[:size])
+		priv.D.FillBytes(k[size:])
+	}
+	return
+}
+
 type RSA2048PublicKey [256]byte
 type RSA2048PrivateKey [512]byte
 
+func (k RSA2048PublicKey) Len() int {
+	return len(k)
+}
+
+func (k RSA2048PublicKey) Bytes() []byte {
+	return k[:]
+}
+
+func (k RSA2048PublicKey) NewVerifier() (Verifier, error) {
+	return createRSAVerifier(crypto.SHA256, k[:])
+}
+
+func (k RSA2048PrivateKey) NewSigner() (Signer, error) {
+	return createRSASigner(crypto.SHA256, k[:])
+}
+
+func (k RSA2048PrivateKey) Len() int {
+	return len(k)
+}
+
+func (k RSA2048PrivateKey) Bytes() []byte {
+	return k[:]
+}
+
+// get the public key, which is the modulus stored in the first half of the private key
+func (k RSA2048PrivateKey) Public() (SigningPublicKey, error) {
+	var pk RSA2048PublicKey
+	copy(pk[:], k[:len(pk)])
+	return pk, nil
+}
+
+// generate a new rsa private key
+func (k RSA2048PrivateKey) Generate() (SigningPrivateKey, error) {
+	err := generateRSAKey(k[:])
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
 type RSA3072PublicKey [384]byte
-type RSA3072PrivateKey [786]byte
+type RSA3072PrivateKey [768]byte
+
+func (k RSA3072PublicKey) Len() int {
+	return len(k)
+}
+
+func (k RSA3072PublicKey) Bytes() []byte {
+	return k[:]
+}
+
+func (k RSA3072PublicKey) NewVerifier() (Verifier, error) {
+	return createRSAVerifier(crypto.SHA384, k[:])
+}
+
+func (k RSA3072PrivateKey) NewSigner() (Signer, error) {
+	return createRSASigner(crypto.SHA384, k[:])
+}
+
+func (k RSA3072PrivateKey) Len() int {
+	return len(k)
+}
+
+func (k RSA3072PrivateKey) Bytes() []byte {
+	return k[:]
+}
+
+// get the public key, which is the modulus stored in the first half of the private key
+func (k RSA3072PrivateKey) Public() (SigningPublicKey, error) {
+	var pk RSA3072PublicKey
+	copy(pk[:], k[:len(pk)])
+	return pk, nil
+}
+
+// generate a new rsa private key
+func (k RSA3072PrivateKey) Generate() (SigningPrivateKey, error) {
+	err := generateRSAKey(k[:])
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
 
 type RSA4096PublicKey [512]byte
 type RSA4096PrivateKey [1024]byte
+
+func (k RSA4096PublicKey) Len() int {
+	return len(k)
+}
+
+func (k RSA4096PublicKey) Bytes() []byte {
+	return k[:]
+}
+
+func (k RSA4096PublicKey) NewVerifier() (Verifier, error) {
+	return createRSAVerifier(crypto.SHA512, k[:])
+}
+
+func (k RSA4096PrivateKey) NewSigner() (Signer, error) {
+	return createRSASigner(crypto.SHA512, k[:])
+}
+
+func (k RSA4096PrivateKey) Len() int {
+	return len(k)
+}
+
+func (k RSA4096PrivateKey) Bytes() []byte {
+	return k[:]
+}
+
+// get the public key, which is the modulus stored in the first half of the private key
+func (k RSA4096PrivateKey) Public() (SigningPublicKey, error) {
+	var pk RSA4096PublicKey
+	copy(pk[:], k[:len(pk)])
+	return pk, nil
+}
+
+// generate a new rsa private key
+func (k RSA4096PrivateKey) Generate() (SigningPrivateKey, error) {
+	err := generateRSAKey(k[:])
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
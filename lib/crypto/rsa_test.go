@@ -0,0 +1,123 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"testing"
+)
+
+func TestRSA2048Verify(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	var pk RSA2048PublicKey
+	priv.N.FillBytes(pk[:])
+	data := make([]byte, 512)
+	io.ReadFull(rand.Reader, data)
+	hasher := crypto.SHA256.New()
+	hasher.Write(data)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, hasher.Sum(nil))
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+
+	v, err := pk.NewVerifier()
+	if err != nil {
+		t.Fatalf("failed to create verifier: %s", err.Error())
+	}
+	err = v.Verify(data, sig)
+	if err != nil {
+		t.Logf("valid signature did not verify: %s", err.Error())
+		t.Fail()
+	}
+	err = v.Verify(data, sig[1:])
+	if err != ErrBadSignatureSize {
+		t.Logf("short signature did not fail with bad size: %v", err)
+		t.Fail()
+	}
+	data[0] ^= 0xff
+	err = v.Verify(data, sig)
+	if err != ErrInvalidSignature {
+		t.Logf("verified invalid signature: %v", err)
+		t.Fail()
+	}
+}
+
+func TestRSAVerifierRejectsShortModulus(t *testing.T) {
+	var pk RSA3072PublicKey
+	pk[len(pk)-1] = 0x01
+	_, err := pk.NewVerifier()
+	if err != ErrInvalidKeyFormat {
+		t.Logf("created verifier for key with short modulus: %v", err)
+		t.Fail()
+	}
+}
+
+func TestRSA2048PrivateKeySigning(t *testing.T) {
+	var sk RSA2048PrivateKey
+	generated, err := sk.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	signer, err := generated.NewSigner()
+	if err != nil {
+		t.Fatalf("failed to create signer: %s", err.Error())
+	}
+	data := make([]byte, 512)
+	io.ReadFull(rand.Reader, data)
+	sig, err := signer.Sign(data)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+	if len(sig) != 256 {
+		t.Logf("signature has wrong size %d", len(sig))
+		t.Fail()
+	}
+	pk, _ := generated.Public()
+	v, _ := pk.NewVerifier()
+	err = v.Verify(data, sig)
+	if err != nil {
+		t.Logf("signature by generated key did not verify: %s", err.Error())
+		t.Fail()
+	}
+}
+
+func TestRSA3072SignerWithStoredKey(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 3072)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	var sk RSA3072PrivateKey
+	priv.N.FillBytes(sk[:384])
+	priv.D.FillBytes(sk[384:])
+	signer, err := sk.NewSigner()
+	if err != nil {
+		t.Fatalf("failed to create signer from stored key: %s", err.Error())
+	}
+	data := []byte("signed with a key stored without its primes")
+	sig, err := signer.Sign(data)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+	h := crypto.SHA384.New()
+	h.Write(data)
+	err = rsa.VerifyPKCS1v15(&priv.PublicKey, crypto.SHA384, h.Sum(nil), sig)
+	if err != nil {
+		t.Logf("signature did not verify with the original key: %s", err.Error())
+		t.Fail()
+	}
+}
+
+func TestRSASignerRejectsInvalidKey(t *testing.T) {
+	var sk RSA2048PrivateKey
+	sk[0] = 0x80
+	sk[len(sk)-1] = 0x01
+	_, err := sk.NewSigner()
+	if err != ErrInvalidKeyFormat {
+		t.Logf("created signer from invalid key: %v", err)
+		t.Fail()
+	}
+}
@@ -24,10 +24,13 @@ type SigningPublicKey interface {
 	NewVerifier() (Verifier, error)
 	// get the size of this public key
 	Len() int
+	// get the raw bytes of this public key
+	Bytes() []byte
 }
 
 type PublicKey interface {
 	Len() int
+	Bytes() []byte
 	NewEncrypter() (Encrypter, error)
 }
 
@@ -49,6 +52,8 @@ type SigningPrivateKey interface {
 	NewSigner() (Signer, error)
 	// length of this private key
 	Len() int
+	// get the raw bytes of this private key
+	Bytes() []byte
 	// get public key or return nil and error if invalid key data in private key
 	Public() (SigningPublicKey, error)
 	// generate a new private key, put it into itself
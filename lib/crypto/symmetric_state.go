@@ -0,0 +1,102 @@
+package crypto
+
+import (
+	"crypto/sha256"
+)
+
+//
+// the noise SymmetricState for SHA256 and ChaChaPoly: the chaining key, the handshake hash and
+// the current cipher key.  It is a plain value, so copying it forks the handshake, as when
+// Bob answers one New Session with several New Session Replies.
+//
+type SymmetricState struct {
+	ck      [32]byte
+	h       [32]byte
+	cipher  ChaChaPoly
+	has_key bool
+}
+
+//
+// InitializeSymmetric, the protocol name is used as the handshake hash when it fits in 32
+// bytes and is hashed otherwise, and becomes the chaining key.  The prologue is mixed in
+// separately with MixHash.
+//
+func NewSymmetricState(protocol_name string) (s SymmetricState) {
+	if len(protocol_name) <= len(s.h) {
+		copy(s.h[:], protocol_name)
+	} else {
+		s.h = sha256.Sum256([]byte(protocol_name))
+	}
+	s.ck = s.h
+	return
+}
+
+// h = SHA256(h || data)
+func (s *SymmetricState) MixHash(data []byte) {
+	s.h = mixHash(s.h, data)
+}
+
+func mixHash(h [32]byte, data []byte) [32]byte {
+	hasher := sha256.New()
+	hasher.Write(h[:])
+	hasher.Write(data)
+	hasher.Sum(h[:0])
+	return h
+}
+
+// derive a new chaining key and cipher key from the chaining key and input key material
+func (s *SymmetricState) MixKey(ikm []byte) {
+	var k [32]byte
+	HKDF(s.ck[:], ikm, "", s.ck[:], k[:])
+	s.cipher.setKey(k[:])
+	s.has_key = true
+}
+
+//
+// encrypt with the handshake hash as associated data, append the result to dst and mix it
+// into the hash.  Before the first MixKey the plaintext is appended unencrypted.
+//
+func (s *SymmetricState) EncryptAndHash(dst, plaintext []byte) (out []byte, err error) {
+	if s.has_key {
+		out, err = s.cipher.Seal(dst, plaintext, s.h[:])
+		if err != nil {
+			return
+		}
+	} else {
+		out = append(dst, plaintext...)
+	}
+	s.MixHash(out[len(dst):])
+	return
+}
+
+//
+// decrypt with the handshake hash as associated data and mix the ciphertext into the hash, the
+// next hash is computed first so the ciphertext may be decrypted in place
+//
+func (s *SymmetricState) DecryptAndHash(dst, ciphertext []byte) (out []byte, err error) {
+	next_h := mixHash(s.h, ciphertext)
+	if s.has_key {
+		out, err = s.cipher.Open(dst, ciphertext, s.h[:])
+		if err != nil {
+			return
+		}
+	} else {
+		out = append(dst, ciphertext...)
+	}
+	s.h = next_h
+	return
+}
+
+// derive the keys of the initiator to responder and responder to initiator directions
+func (s *SymmetricState) Split() (k1, k2 [32]byte) {
+	HKDF(s.ck[:], nil, "", k1[:], k2[:])
+	return
+}
+
+func (s *SymmetricState) ChainingKey() [32]byte {
+	return s.ck
+}
+
+func (s *SymmetricState) HandshakeHash() [32]byte {
+	return s.h
+}
@@ -0,0 +1,77 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestSymmetricStateProtocolName(t *testing.T) {
+	short := NewSymmetricState("Noise_N_25519_ChaChaPoly_SHA256")
+	h := short.HandshakeHash()
+	if !bytes.Equal(h[:31], []byte("Noise_N_25519_ChaChaPoly_SHA256")) || h[31] != 0x00 {
+		t.Fatalf("short protocol name was not zero padded")
+	}
+	long := NewSymmetricState(ECIES_PROTOCOL_NAME)
+	h = long.HandshakeHash()
+	ck := long.ChainingKey()
+	if h != sha256.Sum256([]byte(ECIES_PROTOCOL_NAME)) || ck != h {
+		t.Fatalf("long protocol name was not hashed")
+	}
+	long.MixHash([]byte("prologue"))
+	if long.HandshakeHash() != sha256.Sum256(append(h[:], []byte("prologue")...)) {
+		t.Fatalf("mix hash does not match")
+	}
+}
+
+func TestSymmetricStateHandshake(t *testing.T) {
+	initiator := NewSymmetricState("Noise_N_25519_ChaChaPoly_SHA256")
+	responder := initiator
+
+	// before a key is mixed in messages are only hashed
+	msg, _ := initiator.EncryptAndHash(nil, []byte("cleartext"))
+	if !bytes.Equal(msg, []byte("cleartext")) {
+		t.Fatalf("message encrypted without a key")
+	}
+	responder.DecryptAndHash(nil, msg)
+
+	initiator.MixKey([]byte("shared secret"))
+	responder.MixKey([]byte("shared secret"))
+	for i := 0; i < 2; i++ {
+		msg, err := initiator.EncryptAndHash(nil, []byte("payload"))
+		if err != nil {
+			t.Fatalf("failed to encrypt: %s", err.Error())
+		}
+		if len(msg) != len("payload")+CHACHAPOLY_MAC_SIZE {
+			t.Fatalf("unexpected message length %d", len(msg))
+		}
+		// decrypt in place
+		payload, err := responder.DecryptAndHash(msg[:0], msg)
+		if err != nil || !bytes.Equal(payload, []byte("payload")) {
+			t.Fatalf("failed to decrypt message %d", i)
+		}
+	}
+	if initiator.HandshakeHash() != responder.HandshakeHash() {
+		t.Fatalf("handshake hashes differ")
+	}
+	k1, k2 := initiator.Split()
+	r1, r2 := responder.Split()
+	if k1 != r1 || k2 != r2 || k1 == k2 {
+		t.Fatalf("split keys do not match")
+	}
+}
+
+func TestSymmetricStateDecryptFailure(t *testing.T) {
+	initiator := NewSymmetricState("Noise_N_25519_ChaChaPoly_SHA256")
+	initiator.MixKey([]byte("shared secret"))
+	responder := initiator
+	msg, _ := initiator.EncryptAndHash(nil, []byte("payload"))
+	msg[len(msg)-1] ^= 0x01
+	before := responder.HandshakeHash()
+	if _, err := responder.DecryptAndHash(nil, msg); err != ErrChaChaPolyDecryptionFailed {
+		t.Fatalf("tampered message was decrypted")
+	}
+	if responder.HandshakeHash() != before {
+		t.Fatalf("handshake hash changed after failed decryption")
+	}
+}
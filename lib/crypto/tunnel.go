@@ -5,8 +5,14 @@ import (
 	"crypto/cipher"
 )
 
+// A tunnel message: 4 byte tunnel ID, 16 byte IV and 1008 bytes of encrypted data
 type TunnelData [1028]byte
 
+const (
+	TUNNEL_IV_OFFSET   = 4
+	TUNNEL_DATA_OFFSET = TUNNEL_IV_OFFSET + 16
+)
+
 // A symetric key for encrypting tunnel messages
 type TunnelKey [32]byte
 
@@ -33,19 +39,28 @@ func NewTunnelCrypto(layerKey, ivKey TunnelKey) (t *Tunnel, err error) {
 	return
 }
 
-// encrypt tunnel data in place
+//
+// encrypt a layer of tunnel data in place as a participant does, encrypting the IV with the
+// IV key, CBC encrypting the data with the layer key and that IV, and encrypting the IV again
+//
 func (t *Tunnel) Encrypt(td *TunnelData) {
-	data := *td
-	t.ivKey.Encrypt(data[16:1024], data[16:1024])
-	layerBlock := cipher.NewCBCEncrypter(t.layerKey, data[:16])
-	layerBlock.CryptBlocks(data[16:1024], data[16:1024])
-	t.ivKey.Encrypt(data[16:1024], data[16:1024])
+	iv := td[TUNNEL_IV_OFFSET:TUNNEL_DATA_OFFSET]
+	data := td[TUNNEL_DATA_OFFSET:]
+	t.ivKey.Encrypt(iv, iv)
+	layerBlock := cipher.NewCBCEncrypter(t.layerKey, iv)
+	layerBlock.CryptBlocks(data, data)
+	t.ivKey.Encrypt(iv, iv)
 }
 
+//
+// remove a layer of tunnel data encryption in place, as the outbound gateway does in advance
+// for each hop and the inbound endpoint does after the last hop
+//
 func (t *Tunnel) Decrypt(td *TunnelData) {
-	data := *td
-	t.ivKey.Decrypt(data[16:1024], data[16:1024])
-	layerBlock := cipher.NewCBCDecrypter(t.layerKey, data[:16])
-	layerBlock.CryptBlocks(data[16:1024], data[16:1024])
-	t.ivKey.Decrypt(data[16:1024], data[16:1024])
+	iv := td[TUNNEL_IV_OFFSET:TUNNEL_DATA_OFFSET]
+	data := td[TUNNEL_DATA_OFFSET:]
+	t.ivKey.Decrypt(iv, iv)
+	layerBlock := cipher.NewCBCDecrypter(t.layerKey, iv)
+	layerBlock.CryptBlocks(data, data)
+	t.ivKey.Decrypt(iv, iv)
 }
@@ -0,0 +1,162 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func newTestTunnelHops(t *testing.T, count int) []*Tunnel {
+	hops := make([]*Tunnel, count)
+	for i := range hops {
+		var layer_key, iv_key TunnelKey
+		io.ReadFull(rand.Reader, layer_key[:])
+		io.ReadFull(rand.Reader, iv_key[:])
+		hop, err := NewTunnelCrypto(layer_key, iv_key)
+		if err != nil {
+			t.Fatalf("failed to create tunnel crypto: %s", err.Error())
+		}
+		hops[i] = hop
+	}
+	return hops
+}
+
+func newTestTunnelData() (td TunnelData) {
+	io.ReadFull(rand.Reader, td[:])
+	return
+}
+
+// the double IV scheme computed step by step with the plain block cipher
+func TestTunnelEncryptMatchesDoubleIV(t *testing.T) {
+	var layer_key, iv_key TunnelKey
+	for i := range layer_key {
+		layer_key[i] = byte(i)
+		iv_key[i] = byte(0xff - i)
+	}
+	tunnel, err := NewTunnelCrypto(layer_key, iv_key)
+	if err != nil {
+		t.Fatalf("failed to create tunnel crypto: %s", err.Error())
+	}
+	var td TunnelData
+	for i := range td {
+		td[i] = byte(i * 7)
+	}
+	expected := td
+
+	layer_block, _ := aes.NewCipher(layer_key[:])
+	iv_block, _ := aes.NewCipher(iv_key[:])
+	iv := expected[4:20]
+	iv_block.Encrypt(iv, iv)
+	prev := append([]byte{}, iv...)
+	for i := 20; i < 1028; i += 16 {
+		for j := 0; j < 16; j++ {
+			expected[i+j] ^= prev[j]
+		}
+		layer_block.Encrypt(expected[i:i+16], expected[i:i+16])
+		prev = expected[i : i+16]
+	}
+	iv_block.Encrypt(iv, iv)
+
+	tunnel.Encrypt(&td)
+	if td != expected {
+		t.Fatalf("tunnel encryption does not match the double IV scheme")
+	}
+	tunnel.Decrypt(&td)
+	for i := range td {
+		if td[i] != byte(i*7) {
+			t.Fatalf("tunnel decryption did not restore byte %d", i)
+		}
+	}
+}
+
+// three hops of fixed keys, each layer computed with the IV block cipher and crypto/cipher CBC
+func TestTunnelEncryptMatchesAESCBC(t *testing.T) {
+	var td TunnelData
+	for i := range td {
+		td[i] = byte(i * 7)
+	}
+	expected := td
+	for hop := 0; hop < 3; hop++ {
+		var layer_key, iv_key TunnelKey
+		for i := range layer_key {
+			layer_key[i] = byte(hop*32 + i)
+			iv_key[i] = byte(0xff - hop*32 - i)
+		}
+		tunnel, err := NewTunnelCrypto(layer_key, iv_key)
+		if err != nil {
+			t.Fatalf("failed to create tunnel crypto: %s", err.Error())
+		}
+		tunnel.Encrypt(&td)
+
+		layer_block, _ := aes.NewCipher(layer_key[:])
+		iv_block, _ := aes.NewCipher(iv_key[:])
+		iv := expected[4:20]
+		iv_block.Encrypt(iv, iv)
+		cipher.NewCBCEncrypter(layer_block, iv).CryptBlocks(expected[20:], expected[20:])
+		iv_block.Encrypt(iv, iv)
+		if td != expected {
+			t.Fatalf("tunnel encryption at hop %d does not match AES-256-CBC", hop)
+		}
+	}
+}
+
+func TestTunnelEncryptInPlace(t *testing.T) {
+	tunnel := newTestTunnelHops(t, 1)[0]
+	td := newTestTunnelData()
+	original := td
+
+	tunnel.Encrypt(&td)
+	if !bytes.Equal(td[:4], original[:4]) {
+		t.Fatalf("tunnel id was modified")
+	}
+	if bytes.Equal(td[4:20], original[4:20]) || bytes.Equal(td[20:], original[20:]) {
+		t.Fatalf("tunnel data was not encrypted in place")
+	}
+	tunnel.Decrypt(&td)
+	if td != original {
+		t.Fatalf("decrypted tunnel data does not match")
+	}
+}
+
+func TestTunnelOutboundMultiHop(t *testing.T) {
+	hops := newTestTunnelHops(t, 4)
+	td := newTestTunnelData()
+	plaintext := td
+
+	// the gateway removes every layer in advance, last hop first
+	for i := len(hops) - 1; i >= 0; i-- {
+		hops[i].Decrypt(&td)
+	}
+	for i, hop := range hops {
+		hop.Encrypt(&td)
+		if i < len(hops)-1 && td == plaintext {
+			t.Fatalf("tunnel data is plaintext after hop %d", i)
+		}
+	}
+	if td != plaintext {
+		t.Fatalf("outbound endpoint did not receive the plaintext")
+	}
+}
+
+func TestTunnelInboundMultiHop(t *testing.T) {
+	hops := newTestTunnelHops(t, 4)
+	td := newTestTunnelData()
+	plaintext := td
+
+	// each hop adds a layer and the endpoint peels them off, last hop first
+	for _, hop := range hops {
+		hop.Encrypt(&td)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if td == plaintext {
+			t.Fatalf("tunnel data is plaintext before peeling layer %d", i)
+		}
+		hops[i].Decrypt(&td)
+	}
+	if td != plaintext {
+		t.Fatalf("inbound endpoint did not recover the plaintext")
+	}
+}
@@ -0,0 +1,71 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"golang.org/x/crypto/curve25519"
+	"io"
+)
+
+type X25519PublicKey [32]byte
+type X25519PrivateKey [32]byte
+
+func (k X25519PublicKey) Len() int {
+	return len(k)
+}
+
+func (k X25519PublicKey) Bytes() []byte {
+	return k[:]
+}
+
+// create an encrypter for one-time ECIES-X25519 messages to this public key
+func (k X25519PublicKey) NewEncrypter() (enc Encrypter, err error) {
+	enc = &ECIESEncrypter{
+		k: k,
+	}
+	return
+}
+
+func (k X25519PrivateKey) Len() int {
+	return len(k)
+}
+
+func (k X25519PrivateKey) Bytes() []byte {
+	return k[:]
+}
+
+// create a decrypter for ECIES-X25519 New Session messages to this private key
+func (k X25519PrivateKey) NewDecrypter() (dec Decrypter, err error) {
+	dec = &ECIESDecrypter{
+		k: k,
+	}
+	return
+}
+
+// compute the x25519 shared secret with a public key
+func (k X25519PrivateKey) SharedSecret(pk X25519PublicKey) (secret []byte, err error) {
+	secret, err = curve25519.X25519(k[:], pk[:])
+	if err != nil {
+		err = ErrInvalidKeyFormat
+	}
+	return
+}
+
+// get the public key of this private key
+func (k X25519PrivateKey) Public() (pk X25519PublicKey, err error) {
+	p, err := curve25519.X25519(k[:], curve25519.Basepoint)
+	if err != nil {
+		err = ErrInvalidKeyFormat
+	} else {
+		copy(pk[:], p)
+	}
+	return
+}
+
+// generate a new random x25519 private key
+func (k X25519PrivateKey) Generate() (s X25519PrivateKey, err error) {
+	_, err = io.ReadFull(rand.Reader, k[:])
+	if err == nil {
+		s = k
+	}
+	return
+}
@@ -0,0 +1,27 @@
+package crypto
+
+import (
+	"bytes"
+	"golang.org/x/crypto/curve25519"
+	"testing"
+)
+
+func TestX25519KeyAgreement(t *testing.T) {
+	var a, b X25519PrivateKey
+	a, err := a.Generate()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	b, _ = b.Generate()
+	apk, err := a.Public()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err.Error())
+	}
+	bpk, _ := b.Public()
+	ab, _ := curve25519.X25519(a[:], bpk[:])
+	ba, _ := curve25519.X25519(b[:], apk[:])
+	if !bytes.Equal(ab, ba) {
+		t.Logf("shared secrets do not match")
+		t.Fail()
+	}
+}
@@ -0,0 +1,263 @@
+package i2np
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/hkparker/go-i2p/lib/common"
+	"github.com/hkparker/go-i2p/lib/crypto"
+	"io"
+)
+
+/*
+I2P ElGamal/AES+SessionTag Encryption
+https://geti2p.net/spec/elgamal-aes
+Accurate for version 0.9.28
+
+New Session:
+
++----+----+----+----+----+----+----+----+
+| ElGamal Encrypted Block               |
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+| AES Encrypted Block                   |
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+
+ElGamal Encrypted Block :: 514 bytes, ElGamal encrypted with zero padding
+                           222 bytes of session key, pre-IV and 158 bytes of random padding
+
+AES Encrypted Block :: AES-256-CBC encrypted with the session key
+                       IV is the first 16 bytes of the SHA256 of the pre-IV
+
+Existing Session:
+
++----+----+----+----+----+----+----+----+
+| Session Tag                           |
++                                       +
+|                                       |
++                                       +
+|                                       |
++                                       +
+|                                       |
++----+----+----+----+----+----+----+----+
+| AES Encrypted Block                   |
+~                                       ~
+|                                       |
++----+----+----+----+----+----+----+----+
+
+Session Tag :: 32 bytes, delivered in an earlier message and used once
+
+AES Encrypted Block :: AES-256-CBC encrypted with the session key of the tag
+                       IV is the first 16 bytes of the SHA256 of the session tag
+
+AES Block decrypted:
+
++----+----+----+----+----+----+----+----+
+|tag count|                             |
++----+----+                             +
+|              Session Tags             |
+~                                       ~
+|                                       |
++         +----+----+----+----+----+----+
+|         |    payload size   |         |
++----+----+----+----+----+----+         +
+|                Payload Hash           |
++                                       +
+|                                       |
++                        +----+----+----+
+|                        |flag| Payload |
++----+----+----+----+----+----+         +
+|                                       |
+~                                       ~
+|                                       |
++                             +----//---+
+|                             | Padding |
++----+----+----+----+----+----+----//---+
+
+tag count :: 2 byte Integer, 0-200
+
+Session Tags :: 32 bytes each, new tags for the session key
+
+payload size :: 4 byte Integer
+
+Payload Hash :: SHA256 of the payload
+
+flag :: 1 byte, 0 or 1 if a 32 byte new session key follows, unused
+
+Padding :: random data making the block a multiple of 16 bytes
+*/
+
+const (
+	ELGAMAL_AES_ELGAMAL_BLOCK_SIZE = 514
+	ELGAMAL_AES_ELGAMAL_DATA_SIZE  = 222
+	ELGAMAL_AES_MAX_TAGS           = 200
+	ELGAMAL_AES_BLOCK_MIN_SIZE     = 2 + 4 + 32 + 1
+)
+
+var ERR_ELGAMAL_AES_NOT_ENOUGH_DATA = errors.New("not enough elgamal/aes data")
+var ERR_ELGAMAL_AES_TOO_MANY_TAGS = errors.New("too many session tags in elgamal/aes block")
+var ERR_ELGAMAL_AES_INVALID_BLOCK = errors.New("invalid elgamal/aes block")
+
+//
+// Encrypt a garlic payload to an ElGamal public key as a New Session, delivering the session key
+// and the provided session tags for it.
+//
+func EncryptGarlicNewSession(public_key crypto.ElgPublicKey, session_key common.SessionKey, tags []common.SessionTag, payload []byte) (GarlicElGamal, error) {
+	elgamal_data := make([]byte, ELGAMAL_AES_ELGAMAL_DATA_SIZE)
+	if _, err := io.ReadFull(rand.Reader, elgamal_data); err != nil {
+		return nil, err
+	}
+	copy(elgamal_data, session_key[:])
+	iv := sha256.Sum256(elgamal_data[32:64])
+	aes_block, err := encryptGarlicAESBlock(session_key, iv[:16], tags, payload)
+	if err != nil {
+		return nil, err
+	}
+	encrypter, err := public_key.NewEncrypter()
+	if err != nil {
+		return nil, err
+	}
+	elgamal_block, err := encrypter.Encrypt(elgamal_data)
+	if err != nil {
+		return nil, err
+	}
+	return GarlicElGamal(append(elgamal_block, aes_block...)), nil
+}
+
+//
+// Encrypt a garlic payload with a session key and one of its delivered session tags as an Existing
+// Session, delivering the provided new session tags.
+//
+func EncryptGarlicExistingSession(session_key common.SessionKey, session_tag common.SessionTag, tags []common.SessionTag, payload []byte) (GarlicElGamal, error) {
+	iv := sha256.Sum256(session_tag[:])
+	aes_block, err := encryptGarlicAESBlock(session_key, iv[:16], tags, payload)
+	if err != nil {
+		return nil, err
+	}
+	return GarlicElGamal(append(session_tag[:], aes_block...)), nil
+}
+
+//
+// Decrypt a New Session with an ElGamal private key, returning the session key, the session tags
+// delivered for it and the payload.
+//
+func DecryptGarlicNewSession(private_key crypto.ElgPrivateKey, data GarlicElGamal) (session_key common.SessionKey, tags []common.SessionTag, payload []byte, err error) {
+	if len(data) < ELGAMAL_AES_ELGAMAL_BLOCK_SIZE {
+		err = ERR_ELGAMAL_AES_NOT_ENOUGH_DATA
+		return
+	}
+	decrypter, err := private_key.NewDecrypter()
+	if err != nil {
+		return
+	}
+	elgamal_data, err := decrypter.Decrypt(data[:ELGAMAL_AES_ELGAMAL_BLOCK_SIZE])
+	if err != nil {
+		return
+	}
+	copy(session_key[:], elgamal_data[:32])
+	iv := sha256.Sum256(elgamal_data[32:64])
+	tags, payload, err = decryptGarlicAESBlock(session_key, iv[:16], data[ELGAMAL_AES_ELGAMAL_BLOCK_SIZE:])
+	return
+}
+
+//
+// Decrypt an Existing Session with the session key of its session tag, returning the new session
+// tags delivered and the payload.
+//
+func DecryptGarlicExistingSession(session_key common.SessionKey, data GarlicElGamal) (tags []common.SessionTag, payload []byte, err error) {
+	if len(data) < len(common.SessionTag{}) {
+		err = ERR_ELGAMAL_AES_NOT_ENOUGH_DATA
+		return
+	}
+	iv := sha256.Sum256(data[:32])
+	tags, payload, err = decryptGarlicAESBlock(session_key, iv[:16], data[32:])
+	return
+}
+
+func encryptGarlicAESBlock(session_key common.SessionKey, iv []byte, tags []common.SessionTag, payload []byte) ([]byte, error) {
+	if len(tags) > ELGAMAL_AES_MAX_TAGS {
+		return nil, ERR_ELGAMAL_AES_TOO_MANY_TAGS
+	}
+	size := ELGAMAL_AES_BLOCK_MIN_SIZE + len(tags)*32 + len(payload)
+	padding := make([]byte, (16-size%16)%16)
+	if _, err := io.ReadFull(rand.Reader, padding); err != nil {
+		return nil, err
+	}
+	block := make([]byte, 2, size+len(padding))
+	binary.BigEndian.PutUint16(block, uint16(len(tags)))
+	for _, tag := range tags {
+		block = append(block, tag[:]...)
+	}
+	block = append(block, make([]byte, 4)...)
+	binary.BigEndian.PutUint32(block[len(block)-4:], uint32(len(payload)))
+	payload_hash := sha256.Sum256(payload)
+	block = append(block, payload_hash[:]...)
+	block = append(block, 0x00)
+	block = append(block, payload...)
+	block = append(block, padding...)
+	err := crypto.AESEncryptCBC(session_key[:], iv, block)
+	return block, err
+}
+
+func decryptGarlicAESBlock(session_key common.SessionKey, iv []byte, data []byte) (tags []common.SessionTag, payload []byte, err error) {
+	if len(data) < ELGAMAL_AES_BLOCK_MIN_SIZE {
+		err = ERR_ELGAMAL_AES_NOT_ENOUGH_DATA
+		return
+	}
+	block := make([]byte, len(data))
+	copy(block, data)
+	err = crypto.AESDecryptCBC(session_key[:], iv, block)
+	if err != nil {
+		return
+	}
+	tag_count := common.Integer(block[:2])
+	if tag_count > ELGAMAL_AES_MAX_TAGS {
+		err = ERR_ELGAMAL_AES_TOO_MANY_TAGS
+		return
+	}
+	offset := 2 + tag_count*32
+	if len(block) < offset+ELGAMAL_AES_BLOCK_MIN_SIZE-2 {
+		err = ERR_ELGAMAL_AES_NOT_ENOUGH_DATA
+		return
+	}
+	for i := 2; i < offset; i += 32 {
+		var tag common.SessionTag
+		copy(tag[:], block[i:i+32])
+		tags = append(tags, tag)
+	}
+	payload_size := common.Integer(block[offset : offset+4])
+	payload_hash := block[offset+4 : offset+36]
+	offset += 36
+	if block[offset] == 0x01 {
+		offset += 32
+	}
+	offset += 1
+	if payload_size > len(block)-offset {
+		log.WithFields(log.Fields{
+			"at":           "i2np.decryptGarlicAESBlock",
+			"payload_size": payload_size,
+			"data_len":     len(block) - offset,
+			"reason":       "payload size exceeds block",
+		}).Error("error decrypting garlic")
+		err = ERR_ELGAMAL_AES_INVALID_BLOCK
+		tags = nil
+		return
+	}
+	payload = block[offset : offset+payload_size]
+	hash := sha256.Sum256(payload)
+	if subtle.ConstantTimeCompare(hash[:], payload_hash) != 1 {
+		log.WithFields(log.Fields{
+			"at":     "i2np.decryptGarlicAESBlock",
+			"reason": "payload hash mismatch",
+		}).Error("error decrypting garlic")
+		err = ERR_ELGAMAL_AES_INVALID_BLOCK
+		tags, payload = nil, nil
+	}
+	return
+}
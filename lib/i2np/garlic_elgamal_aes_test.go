@@ -0,0 +1,90 @@
+package i2np
+
+import (
+	"github.com/hkparker/go-i2p/lib/common"
+	"github.com/hkparker/go-i2p/lib/crypto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func generateElgKeys(t *testing.T) (crypto.ElgPrivateKey, crypto.ElgPublicKey) {
+	var private_key crypto.ElgPrivateKey
+	private_key, err := private_key.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	public_key, err := private_key.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return private_key, public_key
+}
+
+func TestGarlicNewSessionRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	private_key, public_key := generateElgKeys(t)
+	session_key := common.SessionKey{0x01, 0x02}
+	tags := []common.SessionTag{{0x03}, {0x04}}
+	payload := []byte("garlic cloves")
+
+	data, err := EncryptGarlicNewSession(public_key, session_key, tags, payload)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal(0, (len(data)-ELGAMAL_AES_ELGAMAL_BLOCK_SIZE)%16)
+	decrypted_key, decrypted_tags, decrypted_payload, err := DecryptGarlicNewSession(private_key, data)
+	assert.Nil(err)
+	assert.Equal(session_key, decrypted_key)
+	assert.Equal(tags, decrypted_tags)
+	assert.Equal(payload, decrypted_payload)
+}
+
+func TestGarlicExistingSessionRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	session_key := common.SessionKey{0x01}
+	session_tag := common.SessionTag{0x02}
+	payload := []byte("more garlic cloves")
+
+	data, err := EncryptGarlicExistingSession(session_key, session_tag, nil, payload)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal(session_tag[:], []byte(data[:32]))
+	tags, decrypted_payload, err := DecryptGarlicExistingSession(session_key, data)
+	assert.Nil(err)
+	assert.Empty(tags)
+	assert.Equal(payload, decrypted_payload)
+
+	_, _, err = DecryptGarlicExistingSession(common.SessionKey{0x09}, data)
+	assert.NotNil(err)
+}
+
+func TestGarlicTooManyTags(t *testing.T) {
+	assert := assert.New(t)
+
+	tags := make([]common.SessionTag, ELGAMAL_AES_MAX_TAGS+1)
+	_, err := EncryptGarlicExistingSession(common.SessionKey{}, common.SessionTag{}, tags, nil)
+	assert.Equal(ERR_ELGAMAL_AES_TOO_MANY_TAGS, err)
+}
+
+func TestGarlicTamperedPayload(t *testing.T) {
+	assert := assert.New(t)
+
+	session_key := common.SessionKey{0x01}
+	data, _ := EncryptGarlicExistingSession(session_key, common.SessionTag{0x02}, nil, make([]byte, 64))
+	data[len(data)-20] ^= 0xff
+	_, _, err := DecryptGarlicExistingSession(session_key, data)
+	assert.Equal(ERR_ELGAMAL_AES_INVALID_BLOCK, err)
+}
+
+func TestGarlicNewSessionNotEnoughData(t *testing.T) {
+	assert := assert.New(t)
+
+	private_key, _ := generateElgKeys(t)
+	_, _, _, err := DecryptGarlicNewSession(private_key, make([]byte, ELGAMAL_AES_ELGAMAL_BLOCK_SIZE-1))
+	assert.Equal(ERR_ELGAMAL_AES_NOT_ENOUGH_DATA, err)
+	_, _, _, err = DecryptGarlicNewSession(private_key, make([]byte, ELGAMAL_AES_ELGAMAL_BLOCK_SIZE+16))
+	assert.Equal(crypto.ElgDecryptFail, err)
+}
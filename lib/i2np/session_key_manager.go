@@ -0,0 +1,234 @@
+package i2np
+
+import (
+	"crypto/rand"
+	log "github.com/sirupsen/logrus"
+	"github.com/hkparker/go-i2p/lib/common"
+	"github.com/hkparker/go-i2p/lib/crypto"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// how long a delivered session tag may be used by the receiver
+	SESSION_TAG_LIFETIME = 15 * time.Minute
+	// how long before expiration the sender stops using a session tag
+	SESSION_TAG_EXPIRATION_MARGIN = 3 * time.Minute
+	// number of new session tags sent when a session runs low
+	SESSION_TAGS_PER_MESSAGE = 40
+	// number of delivered and pending tags below which new tags are sent
+	SESSION_TAGS_LOW_THRESHOLD = 30
+)
+
+type sessionTagEntry struct {
+	tag        common.SessionTag
+	expiration time.Time
+}
+
+// the session key of a remote ElGamal key and the tags sent for it
+type outboundSession struct {
+	key       common.SessionKey
+	delivered []sessionTagEntry
+	pending   map[common.SessionTag]time.Time
+}
+
+type inboundSessionTag struct {
+	key        common.SessionKey
+	expiration time.Time
+}
+
+//
+// SessionKeyManager encrypts and decrypts ElGamal/AES+SessionTag garlic for a local ElGamal key.
+// It keeps a session key for each remote key, sends new session tags when the delivered tags run
+// low, and tracks the session tags delivered to it until they are used or expire.
+//
+type SessionKeyManager struct {
+	mutex       sync.Mutex
+	private_key crypto.ElgPrivateKey
+	outbound    map[crypto.ElgPublicKey]*outboundSession
+	inbound     map[common.SessionTag]inboundSessionTag
+}
+
+//
+// Create a SessionKeyManager for garlic messages encrypted to the public key of the provided
+// ElGamal private key.
+//
+func NewSessionKeyManager(private_key crypto.ElgPrivateKey) *SessionKeyManager {
+	return &SessionKeyManager{
+		private_key: private_key,
+		outbound:    make(map[crypto.ElgPublicKey]*outboundSession),
+		inbound:     make(map[common.SessionTag]inboundSessionTag),
+	}
+}
+
+//
+// Encrypt a garlic payload to a remote ElGamal public key, as an Existing Session if a delivered
+// session tag is available or otherwise as a New Session.  Any new session tags sent with the
+// message are returned and are pending until TagsDelivered or TagsFailed is called for them.
+//
+func (manager *SessionKeyManager) Encrypt(public_key crypto.ElgPublicKey, payload []byte) (data GarlicElGamal, new_tags []common.SessionTag, err error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	now := time.Now()
+	session, ok := manager.outbound[public_key]
+	if !ok {
+		session = &outboundSession{
+			pending: make(map[common.SessionTag]time.Time),
+		}
+		if _, err = io.ReadFull(rand.Reader, session.key[:]); err != nil {
+			return
+		}
+		manager.outbound[public_key] = session
+	}
+	session.expire(now)
+	if len(session.delivered)+len(session.pending) < SESSION_TAGS_LOW_THRESHOLD {
+		new_tags, err = generateSessionTags(SESSION_TAGS_PER_MESSAGE)
+		if err != nil {
+			return
+		}
+	}
+	if len(session.delivered) > 0 {
+		tag := session.delivered[0].tag
+		session.delivered = session.delivered[1:]
+		data, err = EncryptGarlicExistingSession(session.key, tag, new_tags, payload)
+	} else {
+		data, err = EncryptGarlicNewSession(public_key, session.key, new_tags, payload)
+	}
+	if err != nil {
+		new_tags = nil
+		return
+	}
+	for _, tag := range new_tags {
+		session.pending[tag] = now.Add(SESSION_TAG_LIFETIME)
+	}
+	return
+}
+
+//
+// Mark session tags sent to a remote key as delivered, after the message carrying them has been
+// acknowledged, so that they are used for Existing Sessions.
+//
+func (manager *SessionKeyManager) TagsDelivered(public_key crypto.ElgPublicKey, tags []common.SessionTag) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	session, ok := manager.outbound[public_key]
+	if !ok {
+		return
+	}
+	for _, tag := range tags {
+		if expiration, ok := session.pending[tag]; ok {
+			delete(session.pending, tag)
+			session.delivered = append(session.delivered, sessionTagEntry{
+				tag:        tag,
+				expiration: expiration,
+			})
+		}
+	}
+}
+
+//
+// Forget session tags sent to a remote key in a message that was not acknowledged.
+//
+func (manager *SessionKeyManager) TagsFailed(public_key crypto.ElgPublicKey, tags []common.SessionTag) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	session, ok := manager.outbound[public_key]
+	if !ok {
+		return
+	}
+	for _, tag := range tags {
+		delete(session.pending, tag)
+	}
+}
+
+//
+// Decrypt garlic encrypted to this manager's key, as an Existing Session if it begins with a
+// session tag delivered earlier or otherwise as a New Session, and keep the session tags it
+// delivers for later messages.
+//
+func (manager *SessionKeyManager) Decrypt(data GarlicElGamal) (payload []byte, err error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	now := time.Now()
+	var session_key common.SessionKey
+	var tags []common.SessionTag
+	var session_tag common.SessionTag
+	copy(session_tag[:], data)
+	if inbound, ok := manager.inbound[session_tag]; ok && len(data) >= len(session_tag) {
+		delete(manager.inbound, session_tag)
+		if now.After(inbound.expiration) {
+			log.WithFields(log.Fields{
+				"at":     "(SessionKeyManager) Decrypt",
+				"reason": "session tag expired",
+			}).Debug("decrypting garlic as new session")
+		} else {
+			session_key = inbound.key
+			tags, payload, err = DecryptGarlicExistingSession(session_key, data)
+			if err == nil {
+				manager.addInboundTags(session_key, tags, now)
+			}
+			return
+		}
+	}
+	session_key, tags, payload, err = DecryptGarlicNewSession(manager.private_key, data)
+	if err == nil {
+		manager.addInboundTags(session_key, tags, now)
+	}
+	return
+}
+
+// Remove expired session tags, both those delivered to remote keys and those delivered to us.
+func (manager *SessionKeyManager) Expire() {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	now := time.Now()
+	for public_key, session := range manager.outbound {
+		session.expire(now)
+		if len(session.delivered) == 0 && len(session.pending) == 0 {
+			delete(manager.outbound, public_key)
+		}
+	}
+	for tag, inbound := range manager.inbound {
+		if now.After(inbound.expiration) {
+			delete(manager.inbound, tag)
+		}
+	}
+}
+
+func (manager *SessionKeyManager) addInboundTags(session_key common.SessionKey, tags []common.SessionTag, now time.Time) {
+	for _, tag := range tags {
+		manager.inbound[tag] = inboundSessionTag{
+			key:        session_key,
+			expiration: now.Add(SESSION_TAG_LIFETIME),
+		}
+	}
+}
+
+// drop tags the remote may have expired by the time a message using them arrives
+func (session *outboundSession) expire(now time.Time) {
+	cutoff := now.Add(SESSION_TAG_EXPIRATION_MARGIN)
+	delivered := session.delivered[:0]
+	for _, entry := range session.delivered {
+		if entry.expiration.After(cutoff) {
+			delivered = append(delivered, entry)
+		}
+	}
+	session.delivered = delivered
+	for tag, expiration := range session.pending {
+		if !expiration.After(cutoff) {
+			delete(session.pending, tag)
+		}
+	}
+}
+
+func generateSessionTags(count int) (tags []common.SessionTag, err error) {
+	tags = make([]common.SessionTag, count)
+	for i := range tags {
+		if _, err = io.ReadFull(rand.Reader, tags[i][:]); err != nil {
+			tags = nil
+			return
+		}
+	}
+	return
+}
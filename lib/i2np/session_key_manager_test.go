@@ -0,0 +1,129 @@
+package i2np
+
+import (
+	"github.com/hkparker/go-i2p/lib/common"
+	"github.com/hkparker/go-i2p/lib/crypto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSessionKeyManagerNewThenExistingSession(t *testing.T) {
+	assert := assert.New(t)
+
+	alice_private, _ := generateElgKeys(t)
+	bob_private, bob_public := generateElgKeys(t)
+	alice := NewSessionKeyManager(alice_private)
+	bob := NewSessionKeyManager(bob_private)
+
+	data, tags, err := alice.Encrypt(bob_public, []byte("first"))
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal(SESSION_TAGS_PER_MESSAGE, len(tags))
+	assert.True(len(data) > ELGAMAL_AES_ELGAMAL_BLOCK_SIZE)
+	payload, err := bob.Decrypt(data)
+	assert.Nil(err)
+	assert.Equal([]byte("first"), payload)
+
+	// tags are not used until delivery is acknowledged
+	data, tags, err = alice.Encrypt(bob_public, []byte("second"))
+	assert.Nil(err)
+	assert.Empty(tags)
+	_, err = bob.Decrypt(data)
+	assert.Nil(err)
+	assert.True(len(data) > ELGAMAL_AES_ELGAMAL_BLOCK_SIZE)
+
+	alice.TagsDelivered(bob_public, alice.pendingTags(bob_public))
+	data, _, err = alice.Encrypt(bob_public, []byte("third"))
+	assert.Nil(err)
+	assert.True(len(data) < ELGAMAL_AES_ELGAMAL_BLOCK_SIZE)
+	payload, err = bob.Decrypt(data)
+	assert.Nil(err)
+	assert.Equal([]byte("third"), payload)
+
+	// each tag is used once
+	_, err = bob.Decrypt(data)
+	assert.NotNil(err)
+}
+
+func TestSessionKeyManagerSendsMoreTagsWhenLow(t *testing.T) {
+	assert := assert.New(t)
+
+	alice_private, _ := generateElgKeys(t)
+	bob_private, bob_public := generateElgKeys(t)
+	alice := NewSessionKeyManager(alice_private)
+	bob := NewSessionKeyManager(bob_private)
+
+	data, tags, _ := alice.Encrypt(bob_public, []byte("first"))
+	bob.Decrypt(data)
+	alice.TagsDelivered(bob_public, tags)
+	sent := 0
+	for i := 0; i < SESSION_TAGS_PER_MESSAGE; i++ {
+		data, tags, err := alice.Encrypt(bob_public, []byte("existing"))
+		if !assert.Nil(err) {
+			return
+		}
+		sent += len(tags)
+		alice.TagsDelivered(bob_public, tags)
+		payload, err := bob.Decrypt(data)
+		assert.Nil(err)
+		assert.Equal([]byte("existing"), payload)
+		assert.True(len(data) < ELGAMAL_AES_ELGAMAL_BLOCK_SIZE+len(tags)*32)
+	}
+	assert.Equal(SESSION_TAGS_PER_MESSAGE, sent)
+}
+
+func TestSessionKeyManagerFailedTagsAreDropped(t *testing.T) {
+	assert := assert.New(t)
+
+	alice_private, _ := generateElgKeys(t)
+	_, bob_public := generateElgKeys(t)
+	alice := NewSessionKeyManager(alice_private)
+
+	_, tags, _ := alice.Encrypt(bob_public, []byte("lost"))
+	alice.TagsFailed(bob_public, tags)
+	assert.Empty(alice.pendingTags(bob_public))
+	_, tags, _ = alice.Encrypt(bob_public, []byte("retry"))
+	assert.Equal(SESSION_TAGS_PER_MESSAGE, len(tags))
+}
+
+func TestSessionKeyManagerExpiredTags(t *testing.T) {
+	assert := assert.New(t)
+
+	alice_private, _ := generateElgKeys(t)
+	bob_private, bob_public := generateElgKeys(t)
+	alice := NewSessionKeyManager(alice_private)
+	bob := NewSessionKeyManager(bob_private)
+
+	data, tags, _ := alice.Encrypt(bob_public, []byte("first"))
+	bob.Decrypt(data)
+	alice.TagsDelivered(bob_public, tags)
+
+	// tags close to expiration are not used by the sender
+	session := alice.outbound[bob_public]
+	for i := range session.delivered {
+		session.delivered[i].expiration = time.Now().Add(SESSION_TAG_EXPIRATION_MARGIN / 2)
+	}
+	data, _, err := alice.Encrypt(bob_public, []byte("second"))
+	assert.Nil(err)
+	assert.True(len(data) > ELGAMAL_AES_ELGAMAL_BLOCK_SIZE)
+
+	// expired tags are removed by the receiver
+	for tag, inbound := range bob.inbound {
+		inbound.expiration = time.Now().Add(-time.Second)
+		bob.inbound[tag] = inbound
+	}
+	bob.Expire()
+	assert.Empty(bob.inbound)
+	alice.TagsFailed(bob_public, alice.pendingTags(bob_public))
+	alice.Expire()
+	assert.Empty(alice.outbound)
+}
+
+func (manager *SessionKeyManager) pendingTags(public_key crypto.ElgPublicKey) (tags []common.SessionTag) {
+	for tag := range manager.outbound[public_key].pending {
+		tags = append(tags, tag)
+	}
+	return
+}
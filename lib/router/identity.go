@@ -0,0 +1,79 @@
+package router
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/hkparker/go-i2p/lib/common"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// key types used for newly generated router identities
+const (
+	ROUTER_CRYPTO_KEY_TYPE  = common.KEYCERT_CRYPTO_X25519
+	ROUTER_SIGNING_KEY_TYPE = common.KEYCERT_SIGN_ED25519
+)
+
+//
+// load the router's keys from a router.keys.dat file, generating and saving
+// a new identity if the file does not exist yet so that the router keeps the
+// same identity hash across restarts
+//
+func LoadOrCreateRouterKeys(path string) (keys common.PrivateKeyFile, err error) {
+	var data []byte
+	data, err = ioutil.ReadFile(path)
+	if err == nil {
+		keys, err = readRouterKeys(path, data)
+		return
+	}
+	if !os.IsNotExist(err) {
+		return
+	}
+	log.Infof("generating new router identity in %s", path)
+	keys, err = common.GeneratePrivateKeyFile(ROUTER_CRYPTO_KEY_TYPE, ROUTER_SIGNING_KEY_TYPE)
+	if err == nil {
+		err = writeRouterKeys(path, keys)
+	}
+	return
+}
+
+// parse the contents of a router.keys.dat file
+func readRouterKeys(path string, data []byte) (keys common.PrivateKeyFile, err error) {
+	var remainder []byte
+	keys, remainder, err = common.ReadPrivateKeyFile(data)
+	if err != nil {
+		return
+	}
+	if len(remainder) > 0 {
+		log.WithFields(log.Fields{
+			"at":     "readRouterKeys",
+			"path":   path,
+			"reason": "trailing data after private keys",
+		}).Error("error loading router keys")
+		err = errors.New("error loading router keys: trailing data after private keys")
+		return
+	}
+	_, err = keys.NewSigner()
+	return
+}
+
+//
+// write router keys readable only by the current user, replacing the file
+// in one step so a crash can never leave a truncated identity behind
+//
+func writeRouterKeys(path string, keys common.PrivateKeyFile) (err error) {
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, keys, 0600)
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"github.com/hkparker/go-i2p/lib/common"
+	"github.com/hkparker/go-i2p/lib/config"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadOrCreateRouterKeysIsStable(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "state", "router.keys.dat")
+	keys, err := LoadOrCreateRouterKeys(path)
+	if !assert.Nil(err) {
+		return
+	}
+	info, err := os.Stat(path)
+	if assert.Nil(err) {
+		assert.Equal(os.FileMode(0600), info.Mode().Perm())
+	}
+	reloaded, err := LoadOrCreateRouterKeys(path)
+	assert.Nil(err)
+	assert.Equal(keys, reloaded)
+
+	ident, _ := keys.RouterIdentity()
+	cert, _ := ident.Certificate()
+	crypto_key_type, _ := common.KeyCertificate(cert).PublicKeyType()
+	signing_key_type, _ := common.KeyCertificate(cert).SigningPublicKeyType()
+	assert.Equal(common.KEYCERT_CRYPTO_X25519, crypto_key_type)
+	assert.Equal(common.KEYCERT_SIGN_ED25519, signing_key_type)
+}
+
+func TestLoadOrCreateRouterKeysRejectsCorruptFile(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "router.keys.dat")
+	keys, err := LoadOrCreateRouterKeys(path)
+	if !assert.Nil(err) {
+		return
+	}
+	ioutil.WriteFile(path, keys[:len(keys)-1], 0600)
+	_, err = LoadOrCreateRouterKeys(path)
+	assert.NotNil(err)
+	ioutil.WriteFile(path, append(keys, 0x00), 0600)
+	_, err = LoadOrCreateRouterKeys(path)
+	if assert.NotNil(err) {
+		assert.Equal("error loading router keys: trailing data after private keys", err.Error())
+	}
+}
+
+func TestRouterIdentityHashSurvivesRestart(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := &config.RouterConfig{
+		BaseDir:   t.TempDir(),
+		NetDb:     &config.DefaultNetDbConfig,
+		Bootstrap: &config.DefaultBootstrapConfig,
+	}
+	r, err := FromConfig(cfg)
+	if !assert.Nil(err) {
+		return
+	}
+	restarted, err := FromConfig(cfg)
+	assert.Nil(err)
+	assert.Equal(r.IdentHash(), restarted.IdentHash())
+	assert.Equal(common.HashData(r.RouterIdentity()), r.IdentHash())
+}
@@ -1,14 +1,19 @@
 package router
 
 import (
+	log "github.com/sirupsen/logrus"
+	"github.com/hkparker/go-i2p/lib/common"
+	"github.com/hkparker/go-i2p/lib/common/base64"
 	"github.com/hkparker/go-i2p/lib/config"
 	"github.com/hkparker/go-i2p/lib/netdb"
 )
 
 // i2p router type
 type Router struct {
-	cfg *config.RouterConfig
-	ndb netdb.StdNetDB
+	cfg   *config.RouterConfig
+	ndb   netdb.StdNetDB
+	keys  common.PrivateKeyFile
+	ident common.RouterIdentity
 }
 
 // create router with default configuration
@@ -18,13 +23,31 @@ func CreateRouter() (r *Router, err error) {
 	return
 }
 
-// create router from configuration
+// create router from configuration, loading or generating its identity
 func FromConfig(c *config.RouterConfig) (r *Router, err error) {
 	r = new(Router)
 	r.cfg = c
+	r.keys, err = LoadOrCreateRouterKeys(c.RouterKeysPath())
+	if err == nil {
+		r.ident, err = r.keys.RouterIdentity()
+	}
+	if err == nil {
+		h := r.ident.IdentHash()
+		log.Infof("router identity hash is %s", base64.EncodeToString(h[:]))
+	}
 	return
 }
 
+// get this router's identity
+func (r *Router) RouterIdentity() common.RouterIdentity {
+	return r.ident
+}
+
+// get the hash identifying this router to its peers
+func (r *Router) IdentHash() common.Hash {
+	return r.ident.IdentHash()
+}
+
 // run i2p router mainloop
 func (r *Router) Run() {
 	r.ndb = netdb.StdNetDB(r.cfg.NetDb.Path)